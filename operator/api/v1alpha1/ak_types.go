@@ -33,13 +33,64 @@ type AkSpec struct {
 
 	// Blueprints is a field that specifies what blueprints should be loaded into the chart.
 	Blueprints []string `json:"blueprints,omitempty"`
+
+	//+kubebuilder:validation:Enum="Delete";"Retain";"Snapshot"
+	//+kubebuilder:default="Retain"
+	// DeletionPolicy decides what happens to the deployment when this resource is deleted.
+	// Retain leaves the helm release, its persistent volume claims, and its secret in place so that a new Ak
+	// of the same name adopts them. Delete uninstalls the helm release then removes its persistent volume claims
+	// and secret. Snapshot takes a VolumeSnapshot of each persistent volume claim before behaving like Delete.
+	// Retain is the default, so unlike before this field existed deleting an Ak no longer uninstalls its release.
+	// Deletion is blocked while any resources still depend on this instance.
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// VolumeSnapshotClassName is the VolumeSnapshotClass to use when the DeletionPolicy is Snapshot.
+	// Defaults to the clusters default VolumeSnapshotClass.
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
 }
 
+// Deletion policies of the Ak resource
+const (
+	// DeletionPolicyDelete removes the release along with its data
+	DeletionPolicyDelete = "Delete"
+	// DeletionPolicyRetain leaves the release and its data in place
+	DeletionPolicyRetain = "Retain"
+	// DeletionPolicySnapshot snapshots the releases data then removes the release along with its data
+	DeletionPolicySnapshot = "Snapshot"
+)
+
 // AkStatus defines the observed state of Ak
-type AkStatus struct{}
+type AkStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard Ready, Installed, Progressing, and Degraded observations of the helm release
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	//+kubebuilder:validation:Optional
+	// Revision is the helm release revision returned by the last successful install or upgrade
+	Revision int `json:"revision,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// ChartVersion is the version of the ak helm chart that was last installed or upgraded
+	ChartVersion string `json:"chartVersion,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Blueprints lists the blueprint configmaps that were mounted into the release
+	Blueprints []string `json:"blueprints,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the Ak resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Revision",type="integer",JSONPath=".status.revision"
+//+kubebuilder:printcolumn:name="Chart",type="string",JSONPath=".status.chartVersion"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Ak is the Schema for the aks API
 type Ak struct {
@@ -18,12 +18,15 @@ import (
 // AkBlueprintSpec defines the desired state of AkBlueprint
 type AkBlueprintSpec struct {
 
-	//+kubebuilder:validation:Enum="file";"internal"
+	//+kubebuilder:validation:Enum="file";"internal";"api";"oci"
 	//+kubebuilder:validation:Optional
 	//+kubebuilder:default="file"
 	// StorageType (optional) dictates the type of storage to use when submitting the blueprint to authentik.
-	// Due to the nature of OCI storage that is not currently supported but may be in the future.
 	// Note that internal storage does not resolve YAML tags like !KeyOf since it is direct to db.
+	// api storage imports the blueprint through the authentik API which resolves all tags and reports
+	// the result immediately, but authentik does not keep the blueprint so it is only reapplied on change.
+	// oci storage registers the blueprint at OCIRef with authentik which pulls it from the registry itself,
+	// the Blueprint field is ignored.
 	// https://goauthentik.io/developer-docs/blueprints/
 	StorageType string `yaml:"storageType,omitempty" json:"storageType,omitempty"`
 
@@ -32,15 +35,45 @@ type AkBlueprintSpec struct {
 	// The file will overwrite existing configurations underneath it so if it is called the same as
 	// an authentik in built blueprint you will instead use the new one
 	// e.g. /blueprints/default/10-flow-default-authentication-flow.yaml
+	// If unset the defaulting webhook uses /blueprints/operator/<namespace>-<name>.yaml
 	File string `yaml:"file,omitempty" json:"file,omitempty"`
 
-	// Blueprint is a container for a complete single authentik blueprint yaml spec
-	// https://goauthentik.io/developer-docs/blueprints/v1/structure#structure
-	//Blueprint BP `yaml:"blueprint,omitempty" json:"blueprint,omitempty"`
-
 	//+kubebuilder:validation:Type=string
 	//+kubebuilder:validation:Optional
+	// Blueprint (optional) is a complete single authentik blueprint as a yaml string, custom tags like !KeyOf
+	// can be used as is. Ignored if BlueprintSpec is set.
 	Blueprint string `yaml:"blueprint,omitempty" json:"blueprint,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// BlueprintSpec (optional) is a complete single authentik blueprint as structured yaml, used instead of Blueprint
+	// so the blueprint is validated when it is applied. Custom tags must be quoted e.g. provider: "!KeyOf provider"
+	// and are unquoted again when the blueprint is given to authentik.
+	// https://goauthentik.io/developer-docs/blueprints/v1/structure#structure
+	BlueprintSpec *BP `yaml:"blueprintSpec,omitempty" json:"blueprintSpec,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// OCIRef (optional) is the registry/repository:tag or registry/repository@digest of the blueprint
+	// for oci storage e.g. ghcr.io/example/blueprints:v1.2.0
+	OCIRef string `yaml:"ociRef,omitempty" json:"ociRef,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// PullSecret (optional) is the name of a kubernetes.io/dockerconfigjson secret in this namespace with
+	// credentials for the registry of OCIRef. authentik can only be given these as part of the blueprint path,
+	// so they are only handed to authentik when EmbedPullCredentials is set.
+	PullSecret string `yaml:"pullSecret,omitempty" json:"pullSecret,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=false
+	// EmbedPullCredentials allows the credentials of PullSecret to be embedded in the oci:// path of the blueprint
+	// authentik stores, where anyone who can view blueprints in authentik or read its database can see them.
+	EmbedPullCredentials bool `yaml:"embedPullCredentials,omitempty" json:"embedPullCredentials,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=false
+	// Teardown (optional) when true deleting this resource first has authentik apply a copy of the blueprint
+	// with every entry set to absent, so the objects this blueprint created are removed along with it.
+	// Deletion waits for authentik to apply this up to the operators blueprint teardown timeout.
+	Teardown bool `yaml:"teardown,omitempty" json:"teardown,omitempty"`
 }
 
 // BP is a whole blueprint struct containing the full structure of an authentik blueprint
@@ -87,11 +120,12 @@ type BPModel struct {
 	Model string `yaml:"model" json:"model"`
 
 	//+kubebuilder:validation:Optional
-	//+kubebuilder:validation:Enum="present";"create";"absent"
+	//+kubebuilder:validation:Enum="present";"created";"must_created";"absent"
 
-	// State (optional) desired state of this model when loaded from "present", "create", "absent"
+	// State (optional) desired state of this model when loaded from "present", "created", "must_created", "absent"
 	// present: (default) keeps the object in sync with its definition in this blueprint
-	// create: only creates the initial object with its values here
+	// created: only creates the initial object with its values here
+	// must_created: like created but fails if the object already exists
 	// absent: deletes the object
 	State string `yaml:"state,omitempty" json:"state,omitempty"`
 
@@ -116,13 +150,47 @@ type BPModel struct {
 }
 
 // AkBlueprintStatus defines the observed state of AkBlueprint
+// This is mirrored from the authentik_blueprints_blueprintinstance table authentik keeps for each blueprint.
 type AkBlueprintStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this blueprint, Ready reflects authentiks apply status
+	Conditions []metav1.Condition `yaml:"conditions,omitempty" json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	//+kubebuilder:validation:Optional
+	// Status is the apply status authentik reports for this blueprint e.g. "successful", "warning", "error", "orphaned", "unknown"
+	Status string `yaml:"status,omitempty" json:"status,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// LastApplied is when authentik last applied this blueprint
+	LastApplied *metav1.Time `yaml:"lastApplied,omitempty" json:"lastApplied,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// LastAppliedHash is the hash of the blueprint content authentik last applied
+	LastAppliedHash string `yaml:"lastAppliedHash,omitempty" json:"lastAppliedHash,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// ManagedModels lists the models authentik considers managed by this blueprint
+	ManagedModels []string `yaml:"managedModels,omitempty" json:"managedModels,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Logs are the messages authentik returned when it last imported this blueprint, only set for api storage
+	Logs []string `yaml:"logs,omitempty" json:"logs,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the AkBlueprint resource the status was computed from
+	ObservedGeneration int64 `yaml:"observedGeneration,omitempty" json:"observedGeneration,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.status"
+//+kubebuilder:printcolumn:name="Storage",type="string",JSONPath=".spec.storageType"
+//+kubebuilder:printcolumn:name="Last Applied",type="date",JSONPath=".status.lastApplied"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AkBlueprint is the Schema for the akblueprints API
 type AkBlueprint struct {
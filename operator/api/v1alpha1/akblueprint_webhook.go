@@ -0,0 +1,425 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	yaml_v3 "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// BlueprintDir is the directory authentik discovers file based blueprints from
+	BlueprintDir = "/blueprints"
+	// OperatorBlueprintDir is where blueprints without an explicit file are mounted
+	OperatorBlueprintDir = BlueprintDir + "/operator"
+	// BlueprintLabel is the label naming the AkBlueprint a resource belongs to
+	BlueprintLabel = "akm.goauthentik.io/blueprint"
+)
+
+// BlueprintStates are the entry states authentik accepts
+// https://goauthentik.io/developer-docs/blueprints/v1/structure#structure
+var BlueprintStates = []string{"present", "created", "must_created", "absent"}
+
+// BlueprintModels are the models authentik accepts in blueprint entries, others are admitted with a warning
+// as newer authentik versions add models before this list catches up
+// https://goauthentik.io/developer-docs/blueprints/v1/models
+var BlueprintModels = []string{
+	"authentik_blueprints.blueprintinstance",
+	"authentik_blueprints.metaapplyblueprint",
+	"authentik_brands.brand",
+	"authentik_core.application",
+	"authentik_core.group",
+	"authentik_core.token",
+	"authentik_core.user",
+	"authentik_crypto.certificatekeypair",
+	"authentik_enterprise.license",
+	"authentik_events.event",
+	"authentik_events.notification",
+	"authentik_events.notificationrule",
+	"authentik_events.notificationtransport",
+	"authentik_events.notificationwebhookmapping",
+	"authentik_flows.flow",
+	"authentik_flows.flowstagebinding",
+	"authentik_outposts.dockerserviceconnection",
+	"authentik_outposts.kubernetesserviceconnection",
+	"authentik_outposts.outpost",
+	"authentik_policies.policybinding",
+	"authentik_policies_dummy.dummypolicy",
+	"authentik_policies_event_matcher.eventmatcherpolicy",
+	"authentik_policies_expiry.passwordexpirypolicy",
+	"authentik_policies_expression.expressionpolicy",
+	"authentik_policies_geoip.geoippolicy",
+	"authentik_policies_password.passwordpolicy",
+	"authentik_policies_reputation.reputation",
+	"authentik_policies_reputation.reputationpolicy",
+	"authentik_providers_google_workspace.googleworkspaceprovider",
+	"authentik_providers_google_workspace.googleworkspaceprovidermapping",
+	"authentik_providers_ldap.ldapprovider",
+	"authentik_providers_microsoft_entra.microsoftentraprovider",
+	"authentik_providers_microsoft_entra.microsoftentraprovidermapping",
+	"authentik_providers_oauth2.oauth2provider",
+	"authentik_providers_oauth2.scopemapping",
+	"authentik_providers_proxy.proxyprovider",
+	"authentik_providers_rac.endpoint",
+	"authentik_providers_rac.racpropertymapping",
+	"authentik_providers_rac.racprovider",
+	"authentik_providers_radius.radiusprovider",
+	"authentik_providers_radius.radiusproviderpropertymapping",
+	"authentik_providers_saml.samlpropertymapping",
+	"authentik_providers_saml.samlprovider",
+	"authentik_providers_scim.scimmapping",
+	"authentik_providers_scim.scimprovider",
+	"authentik_rbac.role",
+	"authentik_sources_kerberos.kerberossource",
+	"authentik_sources_kerberos.kerberossourcepropertymapping",
+	"authentik_sources_ldap.ldapsource",
+	"authentik_sources_ldap.ldapsourcepropertymapping",
+	"authentik_sources_oauth.oauthsource",
+	"authentik_sources_oauth.oauthsourcepropertymapping",
+	"authentik_sources_oauth.useroauthsourceconnection",
+	"authentik_sources_plex.plexsource",
+	"authentik_sources_plex.plexsourceconnection",
+	"authentik_sources_plex.plexsourcepropertymapping",
+	"authentik_sources_saml.samlsource",
+	"authentik_sources_saml.samlsourcepropertymapping",
+	"authentik_sources_saml.usersamlsourceconnection",
+	"authentik_sources_scim.scimsource",
+	"authentik_sources_scim.scimsourcepropertymapping",
+	"authentik_stages_authenticator_duo.authenticatorduostage",
+	"authentik_stages_authenticator_duo.duodevice",
+	"authentik_stages_authenticator_sms.authenticatorsmsstage",
+	"authentik_stages_authenticator_sms.smsdevice",
+	"authentik_stages_authenticator_static.authenticatorstaticstage",
+	"authentik_stages_authenticator_static.staticdevice",
+	"authentik_stages_authenticator_totp.authenticatortotpstage",
+	"authentik_stages_authenticator_totp.totpdevice",
+	"authentik_stages_authenticator_validate.authenticatorvalidatestage",
+	"authentik_stages_authenticator_webauthn.authenticatewebauthnstage",
+	"authentik_stages_authenticator_webauthn.webauthndevice",
+	"authentik_stages_captcha.captchastage",
+	"authentik_stages_consent.consentstage",
+	"authentik_stages_consent.userconsent",
+	"authentik_stages_deny.denystage",
+	"authentik_stages_dummy.dummystage",
+	"authentik_stages_email.emailstage",
+	"authentik_stages_identification.identificationstage",
+	"authentik_stages_invitation.invitation",
+	"authentik_stages_invitation.invitationstage",
+	"authentik_stages_password.passwordstage",
+	"authentik_stages_prompt.prompt",
+	"authentik_stages_prompt.promptstage",
+	"authentik_stages_redirect.redirectstage",
+	"authentik_stages_source.sourcestage",
+	"authentik_stages_user_delete.userdeletestage",
+	"authentik_stages_user_login.userloginstage",
+	"authentik_stages_user_logout.userlogoutstage",
+	"authentik_stages_user_write.userwritestage",
+	"authentik_tenants.domain",
+}
+
+// SetupWebhookWithManager registers the AkBlueprint webhooks with the manager
+func (r *AkBlueprint) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&akBlueprintDefaulter{Reader: mgr.GetClient()}).
+		WithValidator(&akBlueprintValidator{}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-akm-goauthentik-io-v1alpha1-akblueprint,mutating=true,failurePolicy=fail,sideEffects=None,groups=akm.goauthentik.io,resources=akblueprints,verbs=create;update,versions=v1alpha1,name=makblueprint.kb.io,admissionReviewVersions=v1
+
+//+kubebuilder:object:generate=false
+
+// akBlueprintDefaulter fills in and normalises the file of AkBlueprints. Every file blueprint is mounted into
+// the same authentik deployment from configmaps, so two blueprints with the same file would silently
+// overwrite each other, these are rejected instead.
+type akBlueprintDefaulter struct {
+	client.Reader
+}
+
+var _ admission.CustomDefaulter = &akBlueprintDefaulter{}
+
+// Default implements admission.CustomDefaulter
+func (d *akBlueprintDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	bp, ok := obj.(*AkBlueprint)
+	if !ok {
+		return fmt.Errorf("expected an AkBlueprint but got %T", obj)
+	}
+	if bp.Namespace == "" {
+		// the namespace may only be in the request when it comes from the kubectl context
+		if req, err := admission.RequestFromContext(ctx); err == nil {
+			bp.Namespace = req.Namespace
+		}
+	}
+	if !bp.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	bp.Default()
+	if !bp.Spec.usesFile() {
+		return nil
+	}
+
+	others := &AkBlueprintList{}
+	err := d.List(ctx, others)
+	if err != nil {
+		return err
+	}
+	errs := bp.fileCollisions(others.Items)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.NewInvalid(GroupVersion.WithKind("AkBlueprint").GroupKind(), bp.Name, errs)
+}
+
+// Default sets the file of blueprints that need one to /blueprints/operator/<namespace>-<name>.yaml if it is
+// unset, cleans it otherwise, and labels the blueprint with its own name like its configmap.
+func (r *AkBlueprint) Default() {
+	if r.Labels == nil {
+		r.Labels = map[string]string{}
+	}
+	r.Labels[BlueprintLabel] = r.Name
+	if !r.Spec.usesFile() {
+		return
+	}
+	if r.Spec.File == "" {
+		r.Spec.File = fmt.Sprintf("%v/%v-%v.yaml", OperatorBlueprintDir, r.Namespace, r.Name)
+		return
+	}
+	r.Spec.File = filepath.Clean(r.Spec.File)
+}
+
+// usesFile is whether the blueprint is mounted into authentik at spec.file from a configmap
+func (s *AkBlueprintSpec) usesFile() bool {
+	return s.StorageType == "" || s.StorageType == "file"
+}
+
+// fileCollisions finds other blueprints that would be mounted at the same file as this one
+func (r *AkBlueprint) fileCollisions(others []AkBlueprint) field.ErrorList {
+	errs := field.ErrorList{}
+	for _, other := range others {
+		if other.Namespace == r.Namespace && other.Name == r.Name {
+			continue
+		}
+		if !other.Spec.usesFile() || other.Spec.File == "" {
+			continue
+		}
+		if filepath.Clean(other.Spec.File) == r.Spec.File {
+			errs = append(errs, field.Duplicate(field.NewPath("spec", "file"), fmt.Sprintf("%v is already used by AkBlueprint %v/%v", r.Spec.File, other.Namespace, other.Name)))
+		}
+	}
+	return errs
+}
+
+//+kubebuilder:webhook:path=/validate-akm-goauthentik-io-v1alpha1-akblueprint,mutating=false,failurePolicy=fail,sideEffects=None,groups=akm.goauthentik.io,resources=akblueprints,verbs=create;update,versions=v1alpha1,name=vakblueprint.kb.io,admissionReviewVersions=v1
+
+//+kubebuilder:object:generate=false
+
+// akBlueprintValidator rejects AkBlueprints that authentik would fail to apply, so mistakes show up on kubectl apply
+// rather than in the status or the authentik worker logs later on.
+type akBlueprintValidator struct{}
+
+var _ admission.CustomValidator = &akBlueprintValidator{}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *akBlueprintValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	bp, ok := obj.(*AkBlueprint)
+	if !ok {
+		return nil, fmt.Errorf("expected an AkBlueprint but got %T", obj)
+	}
+	return bp.validateSpec()
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (v *akBlueprintValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	bp, ok := newObj.(*AkBlueprint)
+	if !ok {
+		return nil, fmt.Errorf("expected an AkBlueprint but got %T", newObj)
+	}
+	if !bp.DeletionTimestamp.IsZero() {
+		// let finalizers be removed from blueprints that were admitted before validation was stricter
+		return nil, nil
+	}
+	return bp.validateSpec()
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *akBlueprintValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// Validate checks the spec of the AkBlueprint returning an Invalid error listing every problem found
+func (r *AkBlueprint) Validate() error {
+	_, err := r.validateSpec()
+	return err
+}
+
+// validateSpec checks the spec of the AkBlueprint, warning about anything authentik may not accept
+// and returning an Invalid error for anything it cannot
+func (r *AkBlueprint) validateSpec() (admission.Warnings, error) {
+	errs, warnings := r.Spec.validate(field.NewPath("spec"))
+	if len(errs) == 0 {
+		return warnings, nil
+	}
+	return warnings, errors.NewInvalid(GroupVersion.WithKind("AkBlueprint").GroupKind(), r.Name, errs)
+}
+
+func (s *AkBlueprintSpec) validate(path *field.Path) (field.ErrorList, admission.Warnings) {
+	errs := field.ErrorList{}
+	if s.StorageType == "oci" {
+		if s.OCIRef == "" {
+			errs = append(errs, field.Required(path.Child("ociRef"), "oci storage needs a reference to pull the blueprint from"))
+		}
+		if s.PullSecret != "" && !s.EmbedPullCredentials {
+			errs = append(errs, field.Invalid(path.Child("embedPullCredentials"), false, "authentik can only use the credentials of a pull secret embedded in the blueprint path it stores and shows, set this to allow it"))
+		}
+		return errs, nil
+	}
+
+	if s.usesFile() {
+		errs = append(errs, validateBlueprintFile(s.File, path.Child("file"))...)
+	}
+
+	// the blueprint is checked as rendered yaml so both the string and structured forms get the same checks
+	content := s.Blueprint
+	bpPath := path.Child("blueprint")
+	if s.BlueprintSpec != nil {
+		out, err := yaml_v3.Marshal(s.BlueprintSpec)
+		if err != nil {
+			return append(errs, field.Invalid(path.Child("blueprintSpec"), "", err.Error())), nil
+		}
+		content = string(out)
+		bpPath = path.Child("blueprintSpec")
+	}
+	if strings.TrimSpace(content) == "" {
+		return append(errs, field.Required(bpPath, "either blueprint or blueprintSpec must be set")), nil
+	}
+	contentErrs, warnings := validateBlueprintContent(content, bpPath)
+	return append(errs, contentErrs...), warnings
+}
+
+// validateBlueprintFile checks the file is somewhere authentik will discover it
+func validateBlueprintFile(file string, path *field.Path) field.ErrorList {
+	if file == "" {
+		return field.ErrorList{field.Required(path, fmt.Sprintf("file storage needs a location in %v", BlueprintDir))}
+	}
+	if !filepath.IsAbs(file) {
+		return field.ErrorList{field.Invalid(path, file, "must be an absolute path")}
+	}
+	rel, err := filepath.Rel(BlueprintDir, filepath.Clean(file))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return field.ErrorList{field.Invalid(path, file, fmt.Sprintf("must be a file inside %v where authentik discovers blueprints", BlueprintDir))}
+	}
+	return nil
+}
+
+// validateBlueprintContent parses a blueprint and checks its entries would be accepted by authentik.
+// Models this operator does not know of are only warned about, authentik decides whether it has them.
+func validateBlueprintContent(content string, path *field.Path) (field.ErrorList, admission.Warnings) {
+	bp := &BP{}
+	doc := &yaml_v3.Node{}
+	err := yaml_v3.Unmarshal([]byte(content), doc)
+	if err == nil {
+		err = doc.Decode(bp)
+	}
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, "", fmt.Sprintf("not a valid blueprint: %v", err))}, nil
+	}
+
+	errs := field.ErrorList{}
+	var warnings admission.Warnings
+	if bp.Metadata.Name == "" {
+		errs = append(errs, field.Required(path.Child("metadata", "name"), "authentik needs a name for every blueprint"))
+	}
+	if len(bp.Entries) == 0 {
+		errs = append(errs, field.Required(path.Child("entries"), "blueprint has no entries"))
+	}
+
+	ids := map[string]int{}
+	for i, entry := range bp.Entries {
+		entryPath := path.Child("entries").Index(i)
+		if entry.Model == "" {
+			errs = append(errs, field.Required(entryPath.Child("model"), "every entry needs a model of the form app.model"))
+		} else if !contains(BlueprintModels, entry.Model) {
+			warnings = append(warnings, fmt.Sprintf("%v: unknown model %q, authentik will fail to apply the blueprint if it does not have it", entryPath.Child("model"), entry.Model))
+		}
+		if entry.State != "" && !contains(BlueprintStates, entry.State) {
+			errs = append(errs, field.NotSupported(entryPath.Child("state"), entry.State, BlueprintStates))
+		}
+		if entry.Id != "" {
+			if first, ok := ids[entry.Id]; ok {
+				errs = append(errs, field.Duplicate(entryPath.Child("id"), fmt.Sprintf("%v also used by entries[%v]", entry.Id, first)))
+			} else {
+				ids[entry.Id] = i
+			}
+		}
+	}
+
+	// !KeyOf may refer to entries before or after it so ids are gathered first
+	entries := blueprintEntryNodes(doc)
+	for i, entry := range entries {
+		for _, ref := range keyOfReferences(entry) {
+			if _, ok := ids[ref]; !ok {
+				errs = append(errs, field.Invalid(path.Child("entries").Index(i), "!KeyOf "+ref, fmt.Sprintf("no entry has id `%v`", ref)))
+			}
+		}
+	}
+	return errs, warnings
+}
+
+// blueprintEntryNodes finds the yaml nodes of every blueprint entry
+func blueprintEntryNodes(doc *yaml_v3.Node) []*yaml_v3.Node {
+	if doc.Kind != yaml_v3.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "entries" && root.Content[i+1].Kind == yaml_v3.SequenceNode {
+			return root.Content[i+1].Content
+		}
+	}
+	return nil
+}
+
+// keyOfReferences finds the ids referred to by !KeyOf anywhere under the node, either as a real yaml tag
+// or as a quoted string as used in blueprintSpec
+func keyOfReferences(node *yaml_v3.Node) []string {
+	refs := []string{}
+	if node.Kind == yaml_v3.ScalarNode {
+		if node.Tag == "!KeyOf" {
+			refs = append(refs, strings.TrimSpace(node.Value))
+		} else if ref, ok := strings.CutPrefix(node.Value, "!KeyOf "); ok {
+			refs = append(refs, strings.TrimSpace(ref))
+		}
+	}
+	for _, child := range node.Content {
+		refs = append(refs, keyOfReferences(child)...)
+	}
+	return refs
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const validBlueprint = `version: 1
+metadata:
+  name: grafana
+entries:
+- model: authentik_providers_oauth2.oauth2provider
+  id: provider
+  identifiers:
+    name: grafana
+  attrs:
+    authorization_flow: !Find [authentik_flows.flow, [slug, default-provider-authorization-implicit-consent]]
+- model: authentik_core.application
+  state: present
+  identifiers:
+    slug: grafana
+  attrs:
+    provider: !KeyOf provider
+`
+
+func TestValidateBlueprint(t *testing.T) {
+	tests := map[string]struct {
+		spec AkBlueprintSpec
+		// substrings of the error, empty if the spec is valid
+		want []string
+	}{
+		"valid": {
+			spec: AkBlueprintSpec{File: "/blueprints/operator/grafana.yaml", Blueprint: validBlueprint},
+		},
+		"unknown model": {
+			// only warned about, see TestValidatorWarnsUnknownModels
+			spec: AkBlueprintSpec{File: "/blueprints/operator/grafana.yaml", Blueprint: strings.Replace(validBlueprint, "authentik_core.application", "authentik_core.app", 1)},
+		},
+		"missing model": {
+			spec: AkBlueprintSpec{File: "/blueprints/operator/grafana.yaml", Blueprint: strings.Replace(validBlueprint, "- model: authentik_core.application\n  state", "- state", 1)},
+			want: []string{"spec.blueprint.entries[1].model: Required value"},
+		},
+		"bad state": {
+			spec: AkBlueprintSpec{File: "/blueprints/operator/grafana.yaml", Blueprint: strings.Replace(validBlueprint, "state: present", "state: create", 1)},
+			want: []string{`spec.blueprint.entries[1].state: Unsupported value: "create"`},
+		},
+		"duplicate id": {
+			spec: AkBlueprintSpec{File: "/blueprints/operator/grafana.yaml", Blueprint: strings.Replace(validBlueprint, "  state: present", "  id: provider", 1)},
+			want: []string{"spec.blueprint.entries[1].id: Duplicate value"},
+		},
+		"dangling KeyOf": {
+			spec: AkBlueprintSpec{File: "/blueprints/operator/grafana.yaml", Blueprint: strings.Replace(validBlueprint, "!KeyOf provider", "!KeyOf oauth", 1)},
+			want: []string{"no entry has id `oauth`"},
+		},
+		"missing name and entries": {
+			spec: AkBlueprintSpec{File: "/blueprints/operator/grafana.yaml", Blueprint: "version: 1\n"},
+			want: []string{"spec.blueprint.metadata.name: Required value", "spec.blueprint.entries: Required value"},
+		},
+		"not yaml": {
+			spec: AkBlueprintSpec{File: "/blueprints/operator/grafana.yaml", Blueprint: "entries: [\n"},
+			want: []string{"not a valid blueprint"},
+		},
+		"file outside blueprints": {
+			spec: AkBlueprintSpec{File: "/blueprints/../etc/grafana.yaml", Blueprint: validBlueprint},
+			want: []string{"spec.file: Invalid value", "inside /blueprints"},
+		},
+		"relative file": {
+			spec: AkBlueprintSpec{File: "grafana.yaml", Blueprint: validBlueprint},
+			want: []string{"must be an absolute path"},
+		},
+		"file not needed for api": {
+			spec: AkBlueprintSpec{StorageType: "api", Blueprint: validBlueprint},
+		},
+		"no blueprint": {
+			spec: AkBlueprintSpec{StorageType: "internal"},
+			want: []string{"spec.blueprint: Required value"},
+		},
+		"oci without ref": {
+			spec: AkBlueprintSpec{StorageType: "oci"},
+			want: []string{"spec.ociRef: Required value"},
+		},
+		"oci": {
+			spec: AkBlueprintSpec{StorageType: "oci", OCIRef: "oci://ghcr.io/org/blueprints:v1"},
+		},
+		"oci credentials not embedded": {
+			spec: AkBlueprintSpec{StorageType: "oci", OCIRef: "oci://ghcr.io/org/blueprints:v1", PullSecret: "ghcr-pull"},
+			want: []string{"spec.embedPullCredentials: Invalid value"},
+		},
+		"oci credentials embedded": {
+			spec: AkBlueprintSpec{StorageType: "oci", OCIRef: "oci://ghcr.io/org/blueprints:v1", PullSecret: "ghcr-pull", EmbedPullCredentials: true},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bp := &AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "grafana"}, Spec: tt.spec}
+			err := bp.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if !errors.IsInvalid(err) {
+				t.Fatalf("expected an invalid error, got %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBlueprintSpec(t *testing.T) {
+	// as the api server would hand the resource to us, tags are quoted strings in blueprintSpec
+	data := []byte(`{"metadata": {"name": "sample"}, "spec": {"storageType": "api", "blueprintSpec": {
+		"version": 1,
+		"metadata": {"name": "sample"},
+		"entries": [{
+			"model": "authentik_core.application",
+			"identifiers": {"slug": "sample"},
+			"attrs": {"provider": "!KeyOf provider"}
+		}]
+	}}}`)
+	bp := &AkBlueprint{}
+	if err := json.Unmarshal(data, bp); err != nil {
+		t.Fatal(err)
+	}
+	err := bp.Validate()
+	if err == nil || !strings.Contains(err.Error(), "spec.blueprintSpec.entries[0]") || !strings.Contains(err.Error(), "no entry has id `provider`") {
+		t.Fatalf("expected the dangling !KeyOf in blueprintSpec to be rejected, got %v", err)
+	}
+}
+
+func TestValidatorWarnsUnknownModels(t *testing.T) {
+	v := &akBlueprintValidator{}
+	bp := &AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "grafana"}, Spec: AkBlueprintSpec{
+		File:      "/blueprints/operator/grafana.yaml",
+		Blueprint: strings.Replace(validBlueprint, "authentik_core.application", "authentik_core.app", 1),
+	}}
+	warnings, err := v.ValidateCreate(context.Background(), bp)
+	if err != nil {
+		t.Fatalf("expected an unknown model to be admitted, got %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "spec.blueprint.entries[1].model") || !strings.Contains(warnings[0], `"authentik_core.app"`) {
+		t.Errorf("warnings = %v, want one about authentik_core.app", warnings)
+	}
+
+	bp.Spec.Blueprint = validBlueprint
+	warnings, err = v.ValidateUpdate(context.Background(), bp, bp)
+	if err != nil || len(warnings) != 0 {
+		t.Errorf("expected a known model to be admitted without warnings, got %v, %v", warnings, err)
+	}
+}
+
+func TestValidatorSkipsDeletingBlueprints(t *testing.T) {
+	v := &akBlueprintValidator{}
+	bp := &AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "grafana"}, Spec: AkBlueprintSpec{StorageType: "oci"}}
+	if _, err := v.ValidateCreate(context.Background(), bp); err == nil {
+		t.Fatal("expected create of an invalid blueprint to be rejected")
+	}
+	now := metav1.Now()
+	bp.DeletionTimestamp = &now
+	if _, err := v.ValidateUpdate(context.Background(), bp, bp); err != nil {
+		t.Fatalf("expected updates to a deleting blueprint to be allowed, got %v", err)
+	}
+}
+
+func TestDefaultBlueprint(t *testing.T) {
+	bp := &AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "grafana", Namespace: "auth"}}
+	bp.Default()
+	if bp.Spec.File != "/blueprints/operator/auth-grafana.yaml" {
+		t.Errorf("file = %q, want it defaulted", bp.Spec.File)
+	}
+	if bp.Labels[BlueprintLabel] != "grafana" {
+		t.Errorf("labels = %v, want %v stamped", bp.Labels, BlueprintLabel)
+	}
+
+	bp.Spec.File = "/blueprints//custom/./../custom/grafana.yaml"
+	bp.Default()
+	if bp.Spec.File != "/blueprints/custom/grafana.yaml" {
+		t.Errorf("file = %q, want it cleaned", bp.Spec.File)
+	}
+
+	api := &AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "grafana", Namespace: "auth"}, Spec: AkBlueprintSpec{StorageType: "api"}}
+	api.Default()
+	if api.Spec.File != "" {
+		t.Errorf("file = %q, want api blueprints left without a file", api.Spec.File)
+	}
+}
+
+func TestDefaulterRejectsCollisions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	existing := &AkBlueprint{
+		ObjectMeta: metav1.ObjectMeta{Name: "grafana", Namespace: "auth"},
+		Spec:       AkBlueprintSpec{StorageType: "file", File: "/blueprints/operator/auth-grafana.yaml"},
+	}
+	d := &akBlueprintDefaulter{Reader: fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()}
+	ctx := context.Background()
+
+	// updating the blueprint that owns the file is fine
+	if err := d.Default(ctx, existing.DeepCopy()); err != nil {
+		t.Fatalf("expected the owner of the file to be admitted, got %v", err)
+	}
+	clash := &AkBlueprint{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "apps"},
+		Spec:       AkBlueprintSpec{File: "/blueprints/operator/../operator/auth-grafana.yaml"},
+	}
+	err := d.Default(ctx, clash)
+	if !errors.IsInvalid(err) || !strings.Contains(err.Error(), "auth/grafana") {
+		t.Fatalf("expected a collision with auth/grafana, got %v", err)
+	}
+	oci := &AkBlueprint{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "apps"},
+		Spec:       AkBlueprintSpec{StorageType: "oci", File: "/blueprints/operator/auth-grafana.yaml"},
+	}
+	if err := d.Default(ctx, oci); err != nil {
+		t.Fatalf("expected oci blueprints to ignore files, got %v", err)
+	}
+}
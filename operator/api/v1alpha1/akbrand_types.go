@@ -0,0 +1,146 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/raw"
+)
+
+// AkBrandSpec defines the desired state of an authentik brand, the look and default flows of a domain
+type AkBrandSpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Required
+	// Domain is the domain the brand applies to, including its subdomains
+	Domain string `json:"domain"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=false
+	// Default uses the brand for domains no other brand matches
+	Default bool `json:"default,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Title (optional) is shown in the browser tab and on pages of the brand
+	Title string `json:"title,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Logo (optional) is the image shown on pages of the brand
+	Logo *AkBrandImage `json:"logo,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Favicon (optional) is the icon shown in the browser tab
+	Favicon *AkBrandImage `json:"favicon,omitempty"`
+	//+kubebuilder:validation:Optional
+	// CSS (optional) is custom CSS applied to pages of the brand
+	CSS *AkBrandCSS `json:"css,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Flows (optional) are the slugs of the default flows of the brand
+	Flows AkBrandFlows `json:"flows,omitempty"`
+	//+kubebuilder:validation:Optional
+	// DefaultApplication (optional) is the slug of the application users are sent to instead of the library
+	DefaultApplication string `json:"defaultApplication,omitempty"`
+	//+kubebuilder:validation:Optional
+	// WebCertificate (optional) is the name of the certificate keypair served for the domain, such as that of an AkCertificate
+	WebCertificate string `json:"webCertificate,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:pruning:PreserveUnknownFields
+	//+kubebuilder:validation:Schemaless
+
+	// Attributes (optional) are arbitrary attributes of the brand e.g. settings.theme.base
+	Attributes *raw.Raw `json:"attributes,omitempty"`
+}
+
+// AkBrandImage is an image given by URL or read from a ConfigMap key, which is inlined as a data URI
+type AkBrandImage struct {
+	//+kubebuilder:validation:Optional
+	// URL (optional) is a full URL or a path served by authentik e.g. /static/dist/assets/icons/icon_left_brand.svg
+	URL string `json:"url,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ConfigMap (optional) selects a data or binaryData key of a ConfigMap in this namespace, the key extension gives the type e.g. logo.svg
+	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
+}
+
+// AkBrandCSS is CSS given inline or read from a ConfigMap key
+type AkBrandCSS struct {
+	//+kubebuilder:validation:Optional
+	// Source (optional) is the CSS
+	Source string `json:"source,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ConfigMap (optional) selects a key of a ConfigMap in this namespace holding the CSS
+	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
+}
+
+// AkBrandFlows are the slugs of the default flows of a brand, the authentik defaults are used for any not given
+type AkBrandFlows struct {
+	//+kubebuilder:validation:Optional
+	// Authentication (optional) is the slug of the flow users log in with
+	Authentication string `json:"authentication,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Invalidation (optional) is the slug of the flow users log out with
+	Invalidation string `json:"invalidation,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Recovery (optional) is the slug of the flow users recover their account with
+	Recovery string `json:"recovery,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Unenrollment (optional) is the slug of the flow users delete their account with
+	Unenrollment string `json:"unenrollment,omitempty"`
+	//+kubebuilder:validation:Optional
+	// UserSettings (optional) is the slug of the flow users change their settings with
+	UserSettings string `json:"userSettings,omitempty"`
+	//+kubebuilder:validation:Optional
+	// DeviceCode (optional) is the slug of the flow devices without a browser authorize with
+	DeviceCode string `json:"deviceCode,omitempty"`
+}
+
+// AkBrandStatus defines the observed state of AkBrand
+type AkBrandStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this AkBrand, Ready is true once the generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the AkBrand resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Domain",type="string",JSONPath=".spec.domain"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AkBrand is the Schema for the akbrands API
+type AkBrand struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AkBrandSpec   `json:"spec,omitempty"`
+	Status AkBrandStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AkBrandList contains a list of AkBrand
+type AkBrandList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkBrand `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkBrand{}, &AkBrandList{})
+}
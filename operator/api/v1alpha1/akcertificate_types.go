@@ -0,0 +1,84 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AkCertificateSpec defines the desired state of an authentik certificate keypair kept in sync with a TLS secret
+type AkCertificateSpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Name (optional) is the name of the certificate keypair in authentik, by default the name of this resource
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Required
+	// Secret is a kubernetes.io/tls secret in this namespace e.g. issued by cert-manager
+	Secret corev1.LocalObjectReference `json:"secret"`
+}
+
+// AkCertificateStatus defines the observed state of AkCertificate
+type AkCertificateStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this AkCertificate, Ready is true once the keypair is uploaded
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// Fingerprint is the SHA256 fingerprint of the certificate last synced
+	Fingerprint string `json:"fingerprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// NotAfter is when the certificate last synced expires
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the AkCertificate resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Expires",type="date",JSONPath=".status.notAfter"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AkCertificate is the Schema for the akcertificates API
+type AkCertificate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AkCertificateSpec   `json:"spec,omitempty"`
+	Status AkCertificateStatus `json:"status,omitempty"`
+}
+
+// KeypairName is the name of the certificate keypair in authentik
+func (r *AkCertificate) KeypairName() string {
+	if r.Spec.Name != "" {
+		return r.Spec.Name
+	}
+	return r.Name
+}
+
+//+kubebuilder:object:root=true
+
+// AkCertificateList contains a list of AkCertificate
+type AkCertificateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkCertificate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkCertificate{}, &AkCertificateList{})
+}
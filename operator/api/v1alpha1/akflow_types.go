@@ -0,0 +1,110 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AkFlowSpec defines the desired state of an authentik flow and the stages bound to it
+type AkFlowSpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Slug (optional) identifies the flow in authentik and urls, by default the name of this resource
+	Slug string `json:"slug,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Name (optional) is the display name of the flow, by default the slug
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Required
+	// Title is shown to users at the top of the flow
+	Title string `json:"title"`
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum="authentication";"authorization";"enrollment";"invalidation";"recovery";"stage_configuration";"unenrollment"
+	// Designation is what the flow is used for
+	Designation string `json:"designation"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="any"
+	//+kubebuilder:validation:Enum="all";"any"
+	// PolicyEngineMode is whether all or any of the policies bound to the flow must pass
+	PolicyEngineMode string `json:"policyEngineMode,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Stages (optional) are bound to the flow in the order given
+	Stages []AkFlowStageBinding `json:"stages,omitempty"`
+}
+
+// AkFlowStageBinding binds an AkStage to a flow
+type AkFlowStageBinding struct {
+	//+kubebuilder:validation:Required
+	// Name is the name of the AkStage resource in the namespace of the flow
+	Name string `json:"name"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=false
+	// EvaluateOnPlan evaluates the policies of the binding when the flow is planned rather than when the stage is reached
+	EvaluateOnPlan bool `json:"evaluateOnPlan,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=true
+	// ReEvaluatePolicies evaluates the policies of the binding again when the stage is reached
+	ReEvaluatePolicies bool `json:"reEvaluatePolicies,omitempty"`
+}
+
+// AkFlowStatus defines the observed state of AkFlow
+type AkFlowStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this AkFlow, Ready is true once the generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the AkFlow resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Designation",type="string",JSONPath=".spec.designation"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AkFlow is the Schema for the akflows API
+type AkFlow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AkFlowSpec   `json:"spec,omitempty"`
+	Status AkFlowStatus `json:"status,omitempty"`
+}
+
+// FlowSlug is the slug of the flow in authentik
+func (r *AkFlow) FlowSlug() string {
+	if r.Spec.Slug != "" {
+		return r.Spec.Slug
+	}
+	return r.Name
+}
+
+//+kubebuilder:object:root=true
+
+// AkFlowList contains a list of AkFlow
+type AkFlowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkFlow `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkFlow{}, &AkFlowList{})
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/raw"
+)
+
+// AkGroupSpec defines the desired state of an authentik group
+type AkGroupSpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Name (optional) is the name of the group in authentik, by default the name of this resource
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Parent (optional) is the name of the group in authentik this group inherits from
+	Parent string `json:"parent,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=false
+	// IsSuperuser grants members of the group superuser access to authentik
+	IsSuperuser bool `json:"isSuperuser,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:pruning:PreserveUnknownFields
+	//+kubebuilder:validation:Schemaless
+
+	// Attributes (optional) are arbitrary attributes of the group, merged into the attributes of its members
+	Attributes *raw.Raw `json:"attributes,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Roles (optional) are the names of authentik roles bound to the group
+	Roles []string `json:"roles,omitempty"`
+}
+
+// AkGroupReference refers to an AkGroup resource
+type AkGroupReference struct {
+	//+kubebuilder:validation:Required
+	// Name is the name of the AkGroup resource
+	Name string `json:"name"`
+	//+kubebuilder:validation:Optional
+	// Namespace (optional) is the namespace of the AkGroup resource, by default the namespace of the referrer
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// AkGroupStatus defines the observed state of AkGroup
+type AkGroupStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this AkGroup, Ready is true once the generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the AkGroup resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AkGroup is the Schema for the akgroups API
+type AkGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AkGroupSpec   `json:"spec,omitempty"`
+	Status AkGroupStatus `json:"status,omitempty"`
+}
+
+// GroupName is the name of the group in authentik
+func (r *AkGroup) GroupName() string {
+	if r.Spec.Name != "" {
+		return r.Spec.Name
+	}
+	return r.Name
+}
+
+//+kubebuilder:object:root=true
+
+// AkGroupList contains a list of AkGroup
+type AkGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkGroup{}, &AkGroupList{})
+}
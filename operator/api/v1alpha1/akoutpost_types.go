@@ -0,0 +1,148 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AkOutpostSpec defines the desired state of an authentik outpost and the deployment running it
+type AkOutpostSpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Name (optional) is the name of the outpost in authentik, by default the name of this resource
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum="proxy";"ldap";"radius"
+	// Type is the kind of outpost, which must match the type of its providers
+	Type string `json:"type"`
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinItems=1
+	// Providers are the names of the providers of the outpost type in authentik served by the outpost. The providers
+	// of the outpost are managed by this resource so any bound elsewhere e.g. by a Proxy resource must be listed here.
+	Providers []string `json:"providers"`
+	//+kubebuilder:validation:Optional
+	// AuthentikHost (optional) is the URL browsers reach authentik on, which proxy outposts redirect to for logins
+	AuthentikHost string `json:"authentikHost,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Image (optional) is the outpost image, by default the image of the outpost type at the version of authentik
+	Image string `json:"image,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=1
+	//+kubebuilder:validation:Minimum=0
+	// Replicas is how many pods run the outpost
+	Replicas *int32 `json:"replicas,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Resources (optional) are the compute resources of the outpost container
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Service (optional) configures the service in front of the outpost
+	Service AkOutpostService `json:"service,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Ingress (optional) exposes a proxy outpost through an ingress
+	Ingress *AkOutpostIngress `json:"ingress,omitempty"`
+}
+
+// AkOutpostService configures the service in front of an outpost
+type AkOutpostService struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="ClusterIP"
+	//+kubebuilder:validation:Enum="ClusterIP";"NodePort";"LoadBalancer"
+	// Type is the type of the service
+	Type corev1.ServiceType `json:"type,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Annotations (optional) are added to the service e.g. for load balancer controllers
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// AkOutpostIngress exposes the http port of a proxy outpost on the given hosts
+type AkOutpostIngress struct {
+	//+kubebuilder:validation:Optional
+	// ClassName (optional) is the ingress class to use, by default the cluster default
+	ClassName *string `json:"className,omitempty"`
+	//+kubebuilder:validation:Required
+	// Hosts are the hosts routed to the outpost
+	Hosts []string `json:"hosts"`
+	//+kubebuilder:validation:Optional
+	// TLSSecret (optional) is the name of a secret in this namespace with the certificate of the hosts
+	TLSSecret string `json:"tlsSecret,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Annotations (optional) are added to the ingress e.g. for cert-manager
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// AkOutpostStatus defines the observed state of AkOutpost
+type AkOutpostStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this AkOutpost, Ready is true once the generated blueprint is applied and every replica of the outpost is available
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// PK is the uuid of the outpost in authentik
+	PK string `json:"pk,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Secret is the name of the secret in this namespace holding the token the outpost connects with
+	Secret string `json:"secret,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Deployment is the name of the deployment in this namespace running the outpost
+	Deployment string `json:"deployment,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AvailableReplicas is how many pods of the outpost are available
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the AkOutpost resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
+//+kubebuilder:printcolumn:name="Available",type="integer",JSONPath=".status.availableReplicas"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AkOutpost is the Schema for the akoutposts API
+type AkOutpost struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AkOutpostSpec   `json:"spec,omitempty"`
+	Status AkOutpostStatus `json:"status,omitempty"`
+}
+
+// OutpostName is the name of the outpost in authentik
+func (r *AkOutpost) OutpostName() string {
+	if r.Spec.Name != "" {
+		return r.Spec.Name
+	}
+	return r.Name
+}
+
+//+kubebuilder:object:root=true
+
+// AkOutpostList contains a list of AkOutpost
+type AkOutpostList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkOutpost `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkOutpost{}, &AkOutpostList{})
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AkPolicySpec defines the desired state of an authentik policy and where it is bound, only the settings of its
+// type are used
+type AkPolicySpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Name (optional) is the name of the policy in authentik, by default the name of this resource
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum="expression";"group_membership";"password";"reputation";"event_matcher"
+	// Type is the kind of policy, group_membership binds a group rather than a policy so passes for its members
+	Type string `json:"type"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=false
+	// ExecutionLogging logs every execution of the policy, not only failures
+	ExecutionLogging bool `json:"executionLogging,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Expression (optional) are the settings of an expression policy
+	Expression *AkPolicyExpression `json:"expression,omitempty"`
+	//+kubebuilder:validation:Optional
+	// GroupMembership (optional) are the settings of a group membership policy
+	GroupMembership *AkPolicyGroupMembership `json:"groupMembership,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Password (optional) are the settings of a password policy
+	Password *AkPolicyPassword `json:"password,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Reputation (optional) are the settings of a reputation policy
+	Reputation *AkPolicyReputation `json:"reputation,omitempty"`
+	//+kubebuilder:validation:Optional
+	// EventMatcher (optional) are the settings of an event matcher policy
+	EventMatcher *AkPolicyEventMatcher `json:"eventMatcher,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Bindings (optional) are the applications, flows, and stages the policy is bound to
+	Bindings []AkPolicyBinding `json:"bindings,omitempty"`
+}
+
+// AkPolicyExpression passes when the python expression returns true, the expression is given inline or from a ConfigMap
+type AkPolicyExpression struct {
+	//+kubebuilder:validation:Optional
+	// Source (optional) is the python source of the expression
+	Source string `json:"source,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ConfigMap (optional) selects a key of a ConfigMap in this namespace holding the python source of the expression
+	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
+}
+
+// AkPolicyGroupMembership passes for members of the group
+type AkPolicyGroupMembership struct {
+	//+kubebuilder:validation:Required
+	// Group is the name of the group in authentik
+	Group string `json:"group"`
+}
+
+// AkPolicyPassword checks a password entered in a prompt against static rules, Have I Been Pwned, and zxcvbn
+type AkPolicyPassword struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="password"
+	// PasswordField is the prompt field holding the password
+	PasswordField string `json:"passwordField,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=8
+	// LengthMin is the minimum length of the password
+	LengthMin int `json:"lengthMin,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AmountUppercase is the minimum number of uppercase characters
+	AmountUppercase int `json:"amountUppercase,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AmountLowercase is the minimum number of lowercase characters
+	AmountLowercase int `json:"amountLowercase,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AmountDigits is the minimum number of digits
+	AmountDigits int `json:"amountDigits,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AmountSymbols is the minimum number of symbols
+	AmountSymbols int `json:"amountSymbols,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ErrorMessage (optional) is shown to the user when the static rules fail
+	ErrorMessage string `json:"errorMessage,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=false
+	// CheckHaveIBeenPwned fails passwords found in data breaches more than HIBPAllowedCount times
+	CheckHaveIBeenPwned bool `json:"checkHaveIBeenPwned,omitempty"`
+	//+kubebuilder:validation:Optional
+	// HIBPAllowedCount is how many times a password may appear in breaches
+	HIBPAllowedCount int `json:"hibpAllowedCount,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=false
+	// CheckZxcvbn fails passwords zxcvbn scores at or below ZxcvbnScoreThreshold
+	CheckZxcvbn bool `json:"checkZxcvbn,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=2
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=4
+	// ZxcvbnScoreThreshold is the highest zxcvbn score still failed
+	ZxcvbnScoreThreshold int `json:"zxcvbnScoreThreshold,omitempty"`
+}
+
+// AkPolicyReputation fails when the reputation of the client IP or username drops below the threshold
+type AkPolicyReputation struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=true
+	// CheckIP checks the reputation of the client IP
+	CheckIP bool `json:"checkIP,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=true
+	// CheckUsername checks the reputation of the username
+	CheckUsername bool `json:"checkUsername,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=-5
+	// Threshold is the reputation below which the policy fails
+	Threshold int `json:"threshold,omitempty"`
+}
+
+// AkPolicyEventMatcher passes for events matching all the given fields, used with notification rules
+type AkPolicyEventMatcher struct {
+	//+kubebuilder:validation:Optional
+	// Action (optional) is the event action to match e.g. login_failed
+	Action string `json:"action,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ClientIP (optional) is the client IP to match
+	ClientIP string `json:"clientIP,omitempty"`
+	//+kubebuilder:validation:Optional
+	// App (optional) is the authentik app the event came from e.g. authentik.core
+	App string `json:"app,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Model (optional) is the model the event is about e.g. authentik_core.user
+	Model string `json:"model,omitempty"`
+}
+
+// AkPolicyBinding binds the policy to exactly one of an application, a flow, or a stage in a flow
+type AkPolicyBinding struct {
+	//+kubebuilder:validation:Optional
+	// Application (optional) is the slug of the application to bind to
+	Application string `json:"application,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Flow (optional) is the slug of the flow to bind to, or of the flow the stage is bound in
+	Flow string `json:"flow,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Stage (optional) is the name of the stage in authentik to bind to where it is bound in Flow
+	Stage string `json:"stage,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=0
+	// Order of the binding among the other bindings of the target, lowest first
+	Order int `json:"order,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=false
+	// Negate inverts the result of the policy
+	Negate bool `json:"negate,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=30
+	// Timeout is how many seconds the policy may run before it fails
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// AkPolicyStatus defines the observed state of AkPolicy
+type AkPolicyStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this AkPolicy, Ready is true once the generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the AkPolicy resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AkPolicy is the Schema for the akpolicies API
+type AkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AkPolicySpec   `json:"spec,omitempty"`
+	Status AkPolicyStatus `json:"status,omitempty"`
+}
+
+// PolicyName is the name of the policy in authentik
+func (r *AkPolicy) PolicyName() string {
+	if r.Spec.Name != "" {
+		return r.Spec.Name
+	}
+	return r.Name
+}
+
+//+kubebuilder:object:root=true
+
+// AkPolicyList contains a list of AkPolicy
+type AkPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkPolicy{}, &AkPolicyList{})
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AkPropertyMappingSpec defines the desired state of an authentik property mapping, which fills the claims of an
+// OIDC scope or an attribute of SAML assertions from a python expression
+type AkPropertyMappingSpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Name (optional) is the name of the mapping in authentik, by default the name of this resource
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum="scope";"saml"
+	// Type is the kind of mapping, the settings of which are under the key of the same name
+	Type string `json:"type"`
+	//+kubebuilder:validation:Required
+	// Expression is the python expression returning the value of the mapping
+	Expression AkPropertyMappingExpression `json:"expression"`
+	//+kubebuilder:validation:Optional
+	// Scope (optional) are the settings of an OIDC scope mapping
+	Scope *AkPropertyMappingScope `json:"scope,omitempty"`
+	//+kubebuilder:validation:Optional
+	// SAML (optional) are the settings of a SAML property mapping
+	SAML *AkPropertyMappingSAML `json:"saml,omitempty"`
+}
+
+// AkPropertyMappingExpression is the python expression of a mapping, given inline or from a ConfigMap
+type AkPropertyMappingExpression struct {
+	//+kubebuilder:validation:Optional
+	// Source (optional) is the python source of the expression
+	Source string `json:"source,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ConfigMap (optional) selects a key of a ConfigMap in this namespace holding the source, used instead of source
+	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
+}
+
+// AkPropertyMappingScope is an OIDC scope whose claims are the dictionary the expression returns
+type AkPropertyMappingScope struct {
+	//+kubebuilder:validation:Required
+	// ScopeName is the scope applications request to get the claims e.g. groups
+	ScopeName string `json:"scopeName"`
+	//+kubebuilder:validation:Optional
+	// Description (optional) is shown to users on the consent page, the scope is not shown when empty
+	Description string `json:"description,omitempty"`
+}
+
+// AkPropertyMappingSAML is a SAML attribute whose value the expression returns
+type AkPropertyMappingSAML struct {
+	//+kubebuilder:validation:Required
+	// AttributeName is the name of the attribute in assertions e.g. http://schemas.xmlsoap.org/claims/Group
+	AttributeName string `json:"attributeName"`
+	//+kubebuilder:validation:Optional
+	// FriendlyName (optional) is the friendly name of the attribute in assertions
+	FriendlyName string `json:"friendlyName,omitempty"`
+}
+
+// AkPropertyMappingStatus defines the observed state of AkPropertyMapping
+type AkPropertyMappingStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this AkPropertyMapping, Ready is true once the generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the AkPropertyMapping resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AkPropertyMapping is the Schema for the akpropertymappings API
+type AkPropertyMapping struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AkPropertyMappingSpec   `json:"spec,omitempty"`
+	Status AkPropertyMappingStatus `json:"status,omitempty"`
+}
+
+// MappingName is the name of the property mapping in authentik
+func (r *AkPropertyMapping) MappingName() string {
+	if r.Spec.Name != "" {
+		return r.Spec.Name
+	}
+	return r.Name
+}
+
+//+kubebuilder:object:root=true
+
+// AkPropertyMappingList contains a list of AkPropertyMapping
+type AkPropertyMappingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkPropertyMapping `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkPropertyMapping{}, &AkPropertyMappingList{})
+}
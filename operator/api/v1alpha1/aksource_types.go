@@ -0,0 +1,174 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AkSourceSpec defines the desired state of an authentik federation source users can log in through
+type AkSourceSpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Required
+	// Name is the name of the source shown to users e.g. on the login page
+	Name string `json:"name"`
+	//+kubebuilder:validation:Required
+	// Slug is the unique name of the source used internally and in its callback urls
+	Slug string `json:"slug"`
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum="oauth";"saml"
+	// Type is the protocol of the source, the settings of which are under the key of the same name
+	Type string `json:"type"`
+	//+kubebuilder:validation:Optional
+	// AuthenticationFlow (optional) is the slug of the flow users who already exist log in with
+	AuthenticationFlow string `json:"authenticationFlow,omitempty"`
+	//+kubebuilder:validation:Optional
+	// EnrollmentFlow (optional) is the slug of the flow new users are created with
+	EnrollmentFlow string `json:"enrollmentFlow,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum="identifier";"email_link";"email_deny";"username_link";"username_deny"
+	// UserMatchingMode (optional) is how users from the source are matched to existing users, identifier by default
+	UserMatchingMode string `json:"userMatchingMode,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Icon (optional) is the url of the icon shown for the source on the login page
+	Icon string `json:"icon,omitempty"`
+	//+kubebuilder:validation:Optional
+	// OAuth (optional) are the settings of an oauth source
+	OAuth *AkSourceOAuth `json:"oauth,omitempty"`
+	//+kubebuilder:validation:Optional
+	// SAML (optional) are the settings of a saml source
+	SAML *AkSourceSAML `json:"saml,omitempty"`
+}
+
+// AkSourceOAuth are the settings of an OAuth or OpenID Connect source
+type AkSourceOAuth struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum="apple";"azuread";"discord";"facebook";"github";"google";"mailcow";"okta";"openidconnect";"patreon";"reddit";"twitch";"twitter"
+	// ProviderType is the identity provider, openidconnect for any other OpenID Connect provider e.g. Keycloak
+	ProviderType string `json:"providerType"`
+	//+kubebuilder:validation:Required
+	// Credentials selects the secret in this namespace holding the client id and secret registered with the provider
+	Credentials AkSourceCredentials `json:"credentials"`
+	//+kubebuilder:validation:Optional
+	// AdditionalScopes (optional) are scopes requested on top of those of the provider type, separated by spaces
+	AdditionalScopes string `json:"additionalScopes,omitempty"`
+	//+kubebuilder:validation:Optional
+	// OIDCWellKnownURL (optional) is the discovery url of an openidconnect provider, which sets the other urls
+	OIDCWellKnownURL string `json:"oidcWellKnownURL,omitempty"`
+	//+kubebuilder:validation:Optional
+	// OIDCJWKSURL (optional) is the url of the keys of an openidconnect provider
+	OIDCJWKSURL string `json:"oidcJWKSURL,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AuthorizationURL (optional) overrides the url users are sent to for authorization
+	AuthorizationURL string `json:"authorizationURL,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AccessTokenURL (optional) overrides the url the code is exchanged for a token at
+	AccessTokenURL string `json:"accessTokenURL,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ProfileURL (optional) overrides the url the user profile is fetched from
+	ProfileURL string `json:"profileURL,omitempty"`
+}
+
+// AkSourceCredentials selects the keys of a secret holding OAuth client credentials
+type AkSourceCredentials struct {
+	//+kubebuilder:validation:Required
+	// Name is the name of the secret in this namespace
+	Name string `json:"name"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="clientID"
+	// ClientIDKey is the key of the client id in the secret
+	ClientIDKey string `json:"clientIDKey,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="clientSecret"
+	// ClientSecretKey is the key of the client secret in the secret
+	ClientSecretKey string `json:"clientSecretKey,omitempty"`
+}
+
+// AkSourceSAML are the settings of a SAML source
+type AkSourceSAML struct {
+	//+kubebuilder:validation:Required
+	// SSOURL is the url of the identity provider users are sent to for logins
+	SSOURL string `json:"ssoURL"`
+	//+kubebuilder:validation:Optional
+	// SLOURL (optional) is the url of the identity provider users are sent to for logouts
+	SLOURL string `json:"sloURL,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Issuer (optional) is the issuer authentik presents to the identity provider, by default its metadata url
+	Issuer string `json:"issuer,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum="REDIRECT";"POST";"POST_AUTO"
+	// BindingType (optional) is how requests are sent to the identity provider, REDIRECT by default
+	BindingType string `json:"bindingType,omitempty"`
+	//+kubebuilder:validation:Optional
+	// SigningKeypair (optional) is the name of the certificate keypair requests are signed with, such as that of an AkCertificate
+	SigningKeypair string `json:"signingKeypair,omitempty"`
+	//+kubebuilder:validation:Optional
+	// VerificationKeypair (optional) is the name of the certificate keypair responses are verified with
+	VerificationKeypair string `json:"verificationKeypair,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=false
+	// AllowIDPInitiated allows logins started by the identity provider, which cannot be protected against replay
+	AllowIDPInitiated bool `json:"allowIDPInitiated,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="default-source-pre-authentication"
+	// PreAuthenticationFlow is the slug of the flow run before users are sent to the identity provider
+	PreAuthenticationFlow string `json:"preAuthenticationFlow,omitempty"`
+}
+
+// AkSourceStatus defines the observed state of AkSource
+type AkSourceStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this AkSource, Ready is true once the generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// CredentialsHash is a hash of the client credentials last given to authentik, to notice when they rotate
+	CredentialsHash string `json:"credentialsHash,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the AkSource resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Slug",type="string",JSONPath=".spec.slug"
+//+kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AkSource is the Schema for the aksources API
+type AkSource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AkSourceSpec   `json:"spec,omitempty"`
+	Status AkSourceStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AkSourceList contains a list of AkSource
+type AkSourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkSource `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkSource{}, &AkSourceList{})
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AkStageSpec defines the desired state of an authentik stage, only the settings of its type are used
+type AkStageSpec struct {
+	//+kubebuilder:validation:Optional
+	// Name (optional) is the name of the stage in authentik, by default the name of this resource
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum="identification";"password";"authenticator_validate";"user_login";"consent"
+	// Type is the kind of stage
+	Type string `json:"type"`
+	//+kubebuilder:validation:Optional
+	// Identification (optional) are the settings of an identification stage
+	Identification *AkStageIdentification `json:"identification,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Password (optional) are the settings of a password stage
+	Password *AkStagePassword `json:"password,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AuthenticatorValidate (optional) are the settings of an authenticator validation stage
+	AuthenticatorValidate *AkStageAuthenticatorValidate `json:"authenticatorValidate,omitempty"`
+	//+kubebuilder:validation:Optional
+	// UserLogin (optional) are the settings of a user login stage
+	UserLogin *AkStageUserLogin `json:"userLogin,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Consent (optional) are the settings of a consent stage
+	Consent *AkStageConsent `json:"consent,omitempty"`
+}
+
+// AkStageIdentification asks the user to identify themselves
+type AkStageIdentification struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default={"username","email"}
+	// UserFields are the fields a user can identify themselves with, any of email, username, or upn
+	UserFields []string `json:"userFields,omitempty"`
+	//+kubebuilder:validation:Optional
+	// PasswordStage (optional) is the name of a password stage in authentik to ask for the password on the same page
+	PasswordStage string `json:"passwordStage,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=true
+	// CaseInsensitiveMatching matches the user fields regardless of case
+	CaseInsensitiveMatching bool `json:"caseInsensitiveMatching,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=true
+	// ShowMatchedUser shows the username and avatar of a matched user, otherwise only the entered text
+	ShowMatchedUser bool `json:"showMatchedUser,omitempty"`
+}
+
+// AkStagePassword asks the user for their password
+type AkStagePassword struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default={"authentik.core.auth.InbuiltBackend","authentik.core.auth.TokenBackend"}
+	// Backends are the python paths of the backends to check the password against
+	Backends []string `json:"backends,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=5
+	// FailedAttemptsBeforeCancel is how many wrong passwords are allowed before the flow is cancelled
+	FailedAttemptsBeforeCancel int `json:"failedAttemptsBeforeCancel,omitempty"`
+}
+
+// AkStageAuthenticatorValidate asks the user for one of their authenticators e.g. TOTP or WebAuthn
+type AkStageAuthenticatorValidate struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default={"static","totp","webauthn","duo","sms"}
+	// DeviceClasses are the classes of authenticator the user may validate with
+	DeviceClasses []string `json:"deviceClasses,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="skip"
+	//+kubebuilder:validation:Enum="skip";"deny";"configure"
+	// NotConfiguredAction is what to do when the user has no authenticator
+	NotConfiguredAction string `json:"notConfiguredAction,omitempty"`
+}
+
+// AkStageUserLogin logs the identified user in
+type AkStageUserLogin struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="seconds=0"
+	// SessionDuration is how long the session lasts in format hours=1;minutes=2, seconds=0 lasts until the browser is closed
+	SessionDuration string `json:"sessionDuration,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="seconds=0"
+	// RememberMeOffset offers the user to extend the session by this long in format days=30, seconds=0 disables it
+	RememberMeOffset string `json:"rememberMeOffset,omitempty"`
+}
+
+// AkStageConsent asks the user to consent to sharing their data with an application
+type AkStageConsent struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="always_require"
+	//+kubebuilder:validation:Enum="always_require";"permanent";"expiring"
+	// Mode is whether consent is asked every time, remembered forever, or remembered until it expires
+	Mode string `json:"mode,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="weeks=4"
+	// ConsentExpireIn is how long expiring consent is remembered in format weeks=4;days=2
+	ConsentExpireIn string `json:"consentExpireIn,omitempty"`
+}
+
+// AkStageStatus defines the observed state of AkStage, stages are rendered into the blueprints of the
+// AkFlows that bind them so their progress is reported there.
+type AkStageStatus struct {
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AkStage is the Schema for the akstages API
+type AkStage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AkStageSpec   `json:"spec,omitempty"`
+	Status AkStageStatus `json:"status,omitempty"`
+}
+
+// StageName is the name of the stage in authentik
+func (r *AkStage) StageName() string {
+	if r.Spec.Name != "" {
+		return r.Spec.Name
+	}
+	return r.Name
+}
+
+//+kubebuilder:object:root=true
+
+// AkStageList contains a list of AkStage
+type AkStageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkStage `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkStage{}, &AkStageList{})
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/raw"
+)
+
+// AkUserSpec defines the desired state of an authentik user or service account
+type AkUserSpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Username (optional) is the username in authentik, by default the name of this resource
+	Username string `json:"username,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Name (optional) is the display name of the user
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Email (optional) is the email address of the user
+	Email string `json:"email,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="internal"
+	//+kubebuilder:validation:Enum="internal";"service_account"
+	// Type is whether this is a person (internal) or a machine (service_account)
+	Type string `json:"type,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Path (optional) is the path the user is organised under in authentik e.g. goauthentik.io/service-accounts
+	Path string `json:"path,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Groups (optional) are the names of the authentik groups the user is a member of
+	Groups []string `json:"groups,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:pruning:PreserveUnknownFields
+	//+kubebuilder:validation:Schemaless
+
+	// Attributes (optional) are arbitrary attributes of the user
+	Attributes *raw.Raw `json:"attributes,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Token (optional) generates a token for a service account into a secret in this namespace
+	Token *AkUserToken `json:"token,omitempty"`
+}
+
+// AkUserToken defines a token of a service account and the secret to write it to
+type AkUserToken struct {
+	//+kubebuilder:validation:Required
+	// Secret is an object reference in this namespace to generate with the username and token
+	Secret corev1.LocalObjectReference `json:"secret"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="api"
+	//+kubebuilder:validation:Enum="api";"app_password"
+	// Intent is whether the token is for the authentik API (api) or used as a password to log in (app_password)
+	Intent string `json:"intent,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Rotation (optional) is how often the token is replaced e.g. 720h, by default it is never replaced
+	Rotation *metav1.Duration `json:"rotation,omitempty"`
+}
+
+// AkUserStatus defines the observed state of AkUser
+type AkUserStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this AkUser, Ready is true once the generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Secret is the name of the secret in this namespace holding the token
+	Secret string `json:"secret,omitempty"`
+	//+kubebuilder:validation:Optional
+	// TokenRotatedAt is when the token in the secret was last generated
+	TokenRotatedAt *metav1.Time `json:"tokenRotatedAt,omitempty"`
+	//+kubebuilder:validation:Optional
+	// TokenHash is a hash of the token last given to authentik, to notice when it rotates
+	TokenHash string `json:"tokenHash,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the AkUser resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AkUser is the Schema for the akusers API
+type AkUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AkUserSpec   `json:"spec,omitempty"`
+	Status AkUserStatus `json:"status,omitempty"`
+}
+
+// UserName is the username of the user in authentik
+func (r *AkUser) UserName() string {
+	if r.Spec.Username != "" {
+		return r.Spec.Username
+	}
+	return r.Name
+}
+
+//+kubebuilder:object:root=true
+
+// AkUserList contains a list of AkUser
+type AkUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkUser{}, &AkUserList{})
+}
@@ -0,0 +1,25 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+// Condition types used in the status of our resources.
+// These follow the metav1.Condition conventions so they can be consumed by
+// standard tooling like `kubectl wait --for=condition=Ready`.
+const (
+	// ConditionReady is true when the resource is fully reconciled and usable
+	ConditionReady = "Ready"
+	// ConditionInstalled is true when the helm release has been installed or upgraded
+	ConditionInstalled = "Installed"
+	// ConditionProgressing is true while the operator is working towards the desired state
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded is true when the last reconciliation failed
+	ConditionDegraded = "Degraded"
+)
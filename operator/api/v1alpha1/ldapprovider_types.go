@@ -0,0 +1,141 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LDAPProviderSpec defines an authentik LDAP provider served by an LDAP outpost, so applications that only
+// speak LDAP can authenticate and look up users against authentik.
+type LDAPProviderSpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Required
+	// BaseDN is the root of the directory served by the provider e.g. DC=ldap,DC=org,DC=example
+	BaseDN string `json:"baseDN"`
+	//+kubebuilder:validation:Optional
+	// SearchGroup (optional) is the name of the group whose members may search the full directory,
+	// the generated bind user is made a member of it
+	SearchGroup string `json:"searchGroup,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="default-authentication-flow"
+	// BindFlow is the slug of the flow used to authenticate binds
+	BindFlow string `json:"bindFlow,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="direct"
+	//+kubebuilder:validation:Enum="direct";"cached"
+	// BindMode is whether every bind runs the bind flow (direct) or sessions are cached in the outpost (cached)
+	BindMode string `json:"bindMode,omitempty"`
+	//+kubebuilder:validation:Optional
+	// TLSServerName (optional) is the server name the outpost presents for LDAPS
+	TLSServerName string `json:"tlsServerName,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Certificate (optional) is the name of the certificate keypair the outpost uses for LDAPS
+	Certificate string `json:"certificate,omitempty"`
+	//+kubebuilder:validation:Required
+	// Secret is an object reference in this namespace to generate with the bind DN, bind password and base DN
+	Secret corev1.LocalObjectReference `json:"secret"`
+	//+kubebuilder:validation:Required
+	// Application defines the application the provider authenticates for
+	Application LDAPApplication `json:"application"`
+	//+kubebuilder:validation:Optional
+	// Outpost defines the LDAP outpost that serves the provider
+	Outpost LDAPOutpost `json:"outpost,omitempty"`
+}
+
+type LDAPApplication struct {
+	//+kubebuilder:validation:Required
+	// Name is the name of the application to display
+	Name string `json:"name"`
+	//+kubebuilder:validation:Required
+	// Slug is the unique name of the application used internally
+	Slug string `json:"slug"`
+	//+kubebuilder:validation:Optional
+	// Group is a string that is used to group applications with the idential group
+	Group string `json:"group,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="any"
+	//+kubebuilder:validation:Enum="any";"all"
+	// PolicyEngineMode determines if all or any policy engine should match to grant access
+	PolicyEngineMode string `json:"policyEngineMode,omitempty"`
+}
+
+type LDAPOutpost struct {
+	//+kubebuilder:validation:Optional
+	// Name (optional) is the name of the outpost, by default ldap-<namespace>-<name>. The providers of the outpost
+	// are managed by this resource so it should not be shared.
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ServiceConnection (optional) is the name of the outpost integration authentik deploys the outpost with
+	// e.g. Local Kubernetes Cluster, without one the outpost must be deployed by hand
+	ServiceConnection string `json:"serviceConnection,omitempty"`
+}
+
+// LDAPProviderStatus defines the observed state of LDAPProvider
+type LDAPProviderStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this LDAPProvider, Ready is true once every generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// Secret is the name of the secret in this namespace holding the bind credentials
+	Secret string `json:"secret,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Outpost is the name of the outpost serving the provider
+	Outpost string `json:"outpost,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Blueprints is the observed state of each generated blueprint
+	Blueprints []LDAPBlueprintStatus `json:"blueprints,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the LDAPProvider resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// LDAPBlueprintStatus lists a blueprint generated for the provider and whether authentik has applied it
+type LDAPBlueprintStatus struct {
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint"`
+	//+kubebuilder:validation:Optional
+	// BlueprintApplied is true when authentik reports the generated AkBlueprint as applied
+	BlueprintApplied bool `json:"blueprintApplied,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// LDAPProvider is the Schema for the ldapproviders API
+type LDAPProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LDAPProviderSpec   `json:"spec,omitempty"`
+	Status LDAPProviderStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// LDAPProviderList contains a list of LDAPProvider
+type LDAPProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LDAPProvider `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LDAPProvider{}, &LDAPProviderList{})
+}
@@ -51,12 +51,15 @@ type OIDCApplication struct {
 	// Provider is the primary applications provider to use
 	Provider string `json:"provider,omitempty"`
 	//+kubebuilder:validation:Optional
-	// BackChannelProviders is a list of providers that should be used to augment the main providers functionality
+	// BackChannelProviders is a list of names of providers that should be used to augment the main providers functionality
 	BackChannelProviders []string `json:"backChannelProviders,omitempty"`
 
 	//+kubebuilder:validation:Enum="any";"all"
 	// PolicyEngineMode determines if all or any policy engine should match to grant access
 	PolicyEngineMode string `json:"policyEngineMode,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AccessGroups (optional) restricts access to the application to members of the referenced AkGroups
+	AccessGroups []AkGroupReference `json:"accessGroups,omitempty"`
 
 	//+kubebuilder:validation:Required
 	// OIDCApplicationUISettings defines the behaviour of the application displayed or clicked
@@ -122,7 +125,7 @@ type OIDCProviderProtocolSettings struct {
 	RedirectURIs string `json:"redirectURIs,omitempty"`
 	//+kubebuilder:validation:Optional
 	//+kubebuilder:default="authentik Self-signed Certificate"
-	// The name of the signing key to use for signing authenticated users tokens
+	// The name of the certificate keypair to sign authenticated users tokens with, such as that of an AkCertificate
 	SigningKey string `json:"signingKey,omitempty"`
 	//+kubebuilder:validation:Optional
 	//+kubebuilder:default="minutes=1"
@@ -138,8 +141,13 @@ type OIDCProviderProtocolSettings struct {
 	RefreshTokenValidity string `json:"refreshTokenValidity,omitempty"`
 	//+kubebuilder:validation:Optional
 	//+kubebuilder:default={"email","openid","profile"}
+	// Scopes are the names of the scopes applications may request, each granted by the scope mapping of the same scope name
 	Scopes []string `json:"scopes,omitempty"`
 	//+kubebuilder:validation:Optional
+	// PropertyMappings (optional) are the names of scope mappings giving the provider custom scopes and claims,
+	// such as those of AkPropertyMappings
+	PropertyMappings []string `json:"propertyMappings,omitempty"`
+	//+kubebuilder:validation:Optional
 	//+kubebuilder:default="hashedId"
 	//+kubebuilder:validation:Enum="hashedId";"id";"uuid";"username";"email";"upn"
 	// SubjectMode how you know the same user between applications of this provider
@@ -156,6 +164,8 @@ type OIDCProviderProtocolSettings struct {
 
 type OIDCProviderMachineToMachineSettings struct {
 	//+kubebuilder:validation:Optional
+	// TrustedOIDCSources (optional) are the slugs of OAuth sources, such as those of AkSources, whose JWTs machines
+	// may use to authenticate against this provider
 	TrustedOIDCSources []string `json:"trustedOIDCSources,omitempty"`
 }
 
@@ -173,12 +183,57 @@ type SecretSettings struct {
 
 // OIDCStatus defines the observed state of OIDC
 type OIDCStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this OIDC, Ready is true once every generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// Providers is the observed state of each provider in the spec
+	Providers []OIDCProviderStatus `json:"providers,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Applications is the observed state of each application in the spec
+	Applications []OIDCApplicationStatus `json:"applications,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the OIDC resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// OIDCProviderStatus lists the artifacts generated for a single provider and whether authentik has applied them
+type OIDCProviderStatus struct {
+	// Name is the name of the provider this status belongs to
+	Name string `json:"name"`
+	//+kubebuilder:validation:Optional
+	// Secret is the name of the secret in this namespace holding the clientID and clientSecret
+	Secret string `json:"secret,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// BlueprintApplied is true when authentik reports the generated AkBlueprint as applied
+	BlueprintApplied bool `json:"blueprintApplied,omitempty"`
+}
+
+// OIDCApplicationStatus lists the artifacts generated for a single application and whether authentik has applied them
+type OIDCApplicationStatus struct {
+	// Slug is the slug of the application this status belongs to
+	Slug string `json:"slug"`
+	//+kubebuilder:validation:Optional
+	// ConfigMap is the name of the configmap in this namespace holding the OIDC endpoint URLs
+	ConfigMap string `json:"configMap,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// BlueprintApplied is true when authentik reports the generated AkBlueprint as applied
+	BlueprintApplied bool `json:"blueprintApplied,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // OIDC is the Schema for the oidcs API
 type OIDC struct {
@@ -0,0 +1,193 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProxySpec defines abstract and safe interfaces to put applications behind an authentik outpost
+// either as a reverse proxy or with forward auth from an ingress controller.
+type ProxySpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Required
+	// Provider which defines how and where the proxy takes place
+	Providers []ProxyProvider `json:"providers,omitempty"`
+	//+kubebuilder:validation:Required
+	// Applications define what the provider authenticates for
+	Applications []ProxyApplication `json:"applications,omitempty"`
+}
+
+type ProxyApplication struct {
+	//+kubebuilder:validation:Required
+	// Name is the name of the application to display
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ConfigMap (optional) references a configmap to generate with ingress-nginx annotations for forward auth
+	ConfigMap *corev1.LocalObjectReference `json:"configMap,omitempty"`
+	//+kubebuilder:validation:Required
+	// Slug is the unique name of the application used internally
+	Slug string `json:"slug,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Group is a string that is used to group applications with the idential group
+	Group string `json:"group,omitempty"`
+	// Provider is the name of the proxy provider of this application
+	Provider string `json:"provider,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="any"
+	//+kubebuilder:validation:Enum="any";"all"
+	// PolicyEngineMode determines if all or any policy engine should match to grant access
+	PolicyEngineMode string `json:"policyEngineMode,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// ProxyApplicationUISettings defines the behaviour of the application displayed or clicked
+	ProxyApplicationUISettings OIDCApplicationUISettings `json:"proxyApplicationUISettings,omitempty"`
+}
+
+type ProxyProvider struct {
+	//+kubebuilder:validation:Required
+	// Name is the name of the provider
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Optional
+	// AuthenticationFlow is the name of the authentication flow to authenticate users with
+	AuthenticationFlow string `json:"authenticationFlow,omitempty"`
+	//+kubebuilder:validation:Required
+	// AuthorizationFlow is the name of the authorization flow to authorize this provider
+	AuthorizationFlow string `json:"authorizationFlow"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="authentik Embedded Outpost"
+	// Outpost is the name of the outpost that serves this provider, by default the outpost embedded in authentik
+	Outpost string `json:"outpost,omitempty"`
+	//+kubebuilder:validation:Required
+	// ProtocolSettings is the settings for the proxy to use
+	ProtocolSettings ProxyProviderProtocolSettings `json:"protocolSettings"`
+}
+
+type ProxyProviderProtocolSettings struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="forward_single"
+	//+kubebuilder:validation:Enum="proxy";"forward_single";"forward_domain"
+	// Mode is how the outpost protects the application
+	// proxy: the outpost is a reverse proxy in front of InternalHost
+	// forward_single: an ingress asks the outpost to authenticate requests to a single application
+	// forward_domain: an ingress asks the outpost to authenticate requests to every application on CookieDomain
+	Mode string `json:"mode,omitempty"`
+	//+kubebuilder:validation:Required
+	// ExternalHost is the URL users reach the application at, or authentik at for forward_domain
+	ExternalHost string `json:"externalHost"`
+	//+kubebuilder:validation:Optional
+	// InternalHost is the URL the outpost proxies requests to in proxy mode
+	InternalHost string `json:"internalHost,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=true
+	// InternalHostSSLValidation whether the outpost checks the certificate of InternalHost
+	InternalHostSSLValidation bool `json:"internalHostSSLValidation,omitempty"`
+	//+kubebuilder:validation:Optional
+	// CookieDomain is the domain sessions are valid for in forward_domain mode e.g. org.example
+	CookieDomain string `json:"cookieDomain,omitempty"`
+	//+kubebuilder:validation:Optional
+	// SkipPathRegex (optional) newline separated regular expressions of paths that do not need authentication
+	SkipPathRegex string `json:"skipPathRegex,omitempty"`
+	//+kubebuilder:validation:Optional
+	// BasicAuth (optional) sends the user to the application with HTTP basic auth built from their attributes
+	BasicAuth *ProxyBasicAuth `json:"basicAuth,omitempty"`
+}
+
+// ProxyBasicAuth maps user or group attributes onto the HTTP basic auth header sent to the application
+type ProxyBasicAuth struct {
+	//+kubebuilder:validation:Optional
+	// UserAttribute is the attribute used as the username, by default the users email
+	UserAttribute string `json:"userAttribute,omitempty"`
+	//+kubebuilder:validation:Required
+	// PasswordAttribute is the attribute used as the password
+	PasswordAttribute string `json:"passwordAttribute"`
+}
+
+// ProxyStatus defines the observed state of Proxy
+type ProxyStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this Proxy, Ready is true once every generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// Providers is the observed state of each provider in the spec
+	Providers []ProxyProviderStatus `json:"providers,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Applications is the observed state of each application in the spec
+	Applications []ProxyApplicationStatus `json:"applications,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the Proxy resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// ProxyProviderStatus lists the artifacts generated for a single provider and whether authentik has applied them
+type ProxyProviderStatus struct {
+	// Name is the name of the provider this status belongs to
+	Name string `json:"name"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// BlueprintApplied is true when authentik reports the generated AkBlueprint as applied
+	BlueprintApplied bool `json:"blueprintApplied,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Outpost is the name of the outpost the provider has been bound to
+	Outpost string `json:"outpost,omitempty"`
+}
+
+// ProxyApplicationStatus lists the artifacts generated for a single application and whether authentik has applied them
+type ProxyApplicationStatus struct {
+	// Slug is the slug of the application this status belongs to
+	Slug string `json:"slug"`
+	//+kubebuilder:validation:Optional
+	// ConfigMap is the name of the configmap in this namespace holding ingress-nginx annotations
+	ConfigMap string `json:"configMap,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// BlueprintApplied is true when authentik reports the generated AkBlueprint as applied
+	BlueprintApplied bool `json:"blueprintApplied,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Proxy is the Schema for the proxies API
+type Proxy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProxySpec   `json:"spec,omitempty"`
+	Status ProxyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ProxyList contains a list of Proxy
+type ProxyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Proxy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Proxy{}, &ProxyList{})
+}
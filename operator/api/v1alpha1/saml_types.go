@@ -0,0 +1,177 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SAMLSpec defines abstract and safe interfaces to provision an authentik SAML authentication stack
+// this is meant to be deployed with applications that only speak SAML so that SSO can be provisioned for them.
+type SAMLSpec struct {
+	//+kubebuilder:validation:Required
+	// Authentik Instance
+	Instance AuthentikInstance `json:"instance,omitempty"`
+	//+kubebuilder:validation:Required
+	// Provider which defines how and where SAML takes place
+	Providers []SAMLProvider `json:"providers,omitempty"`
+	//+kubebuilder:validation:Required
+	// Applications define what the provider authenticates for
+	Applications []SAMLApplication `json:"applications,omitempty"`
+}
+
+type SAMLApplication struct {
+	//+kubebuilder:validation:Required
+	// Name is the name of the application to display
+	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Required
+	// ConfigMap references the configmap that will contain the IdP metadata, SSO and SLO URLs
+	ConfigMap corev1.LocalObjectReference `json:"configMap,omitempty"`
+	//+kubebuilder:validation:Required
+	// Slug is the unique name of the application used internally
+	Slug string `json:"slug,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Group is a string that is used to group applications with the idential group
+	Group string `json:"group,omitempty"`
+	// Provider is the name of the SAML provider of this application
+	Provider string `json:"provider,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="any"
+	//+kubebuilder:validation:Enum="any";"all"
+	// PolicyEngineMode determines if all or any policy engine should match to grant access
+	PolicyEngineMode string `json:"policyEngineMode,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// SAMLApplicationUISettings defines the behaviour of the application displayed or clicked
+	SAMLApplicationUISettings OIDCApplicationUISettings `json:"samlApplicationUISettings,omitempty"`
+}
+
+type SAMLProvider struct {
+	//+kubebuilder:validation:Required
+	// Name is the name of the provider
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Optional
+	// AuthenticationFlow is the name of the authentication flow to authenticate users with
+	AuthenticationFlow string `json:"authenticationFlow,omitempty"`
+	//+kubebuilder:validation:Required
+	// AuthorizationFlow is the name of the authorization flow to authorize this provider
+	AuthorizationFlow string `json:"authorizationFlow"`
+	//+kubebuilder:validation:Required
+	// ProtocolSettings is the settings for the SAML protocol to use
+	ProtocolSettings SAMLProviderProtocolSettings `json:"protocolSettings"`
+}
+
+type SAMLProviderProtocolSettings struct {
+	//+kubebuilder:validation:Required
+	// ACSURL is the assertion consumer service URL of the application that authentik sends responses to
+	ACSURL string `json:"acsURL"`
+	//+kubebuilder:validation:Optional
+	// Audience (optional) is the value the application expects in the audience restriction of assertions
+	Audience string `json:"audience,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="authentik"
+	// Issuer is the issuer of assertions as the application knows authentik
+	Issuer string `json:"issuer,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="redirect"
+	//+kubebuilder:validation:Enum="redirect";"post"
+	// SPBinding is how authentik sends the response back to the application
+	SPBinding string `json:"spBinding,omitempty"`
+	//+kubebuilder:validation:Optional
+	// SigningCertificate (optional) is the name of the certificate keypair to sign assertions with, such as that of an AkCertificate
+	SigningCertificate string `json:"signingCertificate,omitempty"`
+	//+kubebuilder:validation:Optional
+	// VerificationCertificate (optional) is the name of the certificate keypair to verify signed requests from the application with
+	VerificationCertificate string `json:"verificationCertificate,omitempty"`
+	//+kubebuilder:validation:Optional
+	// NameIDPropertyMapping (optional) is the name of the SAML property mapping that fills the NameID, by default authentik uses the hashed user ID
+	NameIDPropertyMapping string `json:"nameIDPropertyMapping,omitempty"`
+	//+kubebuilder:validation:Optional
+	// PropertyMappings (optional) are the names of the SAML property mappings filling the attributes of assertions,
+	// such as those of AkPropertyMappings
+	PropertyMappings []string `json:"propertyMappings,omitempty"`
+}
+
+// SAMLStatus defines the observed state of SAML
+type SAMLStatus struct {
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	// Conditions are the standard observations of this SAML, Ready is true once every generated blueprint is applied
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	//+kubebuilder:validation:Optional
+	// Providers is the observed state of each provider in the spec
+	Providers []SAMLProviderStatus `json:"providers,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Applications is the observed state of each application in the spec
+	Applications []SAMLApplicationStatus `json:"applications,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation of the SAML resource the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// SAMLProviderStatus lists the artifacts generated for a single provider and whether authentik has applied them
+type SAMLProviderStatus struct {
+	// Name is the name of the provider this status belongs to
+	Name string `json:"name"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// BlueprintApplied is true when authentik reports the generated AkBlueprint as applied
+	BlueprintApplied bool `json:"blueprintApplied,omitempty"`
+}
+
+// SAMLApplicationStatus lists the artifacts generated for a single application and whether authentik has applied them
+type SAMLApplicationStatus struct {
+	// Slug is the slug of the application this status belongs to
+	Slug string `json:"slug"`
+	//+kubebuilder:validation:Optional
+	// ConfigMap is the name of the configmap in this namespace holding the SAML endpoint URLs
+	ConfigMap string `json:"configMap,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AkBlueprint is the namespaced name of the generated AkBlueprint in the authentik namespace
+	AkBlueprint string `json:"akBlueprint,omitempty"`
+	//+kubebuilder:validation:Optional
+	// BlueprintApplied is true when authentik reports the generated AkBlueprint as applied
+	BlueprintApplied bool `json:"blueprintApplied,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// SAML is the Schema for the samls API
+type SAML struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SAMLSpec   `json:"spec,omitempty"`
+	Status SAMLStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SAMLList contains a list of SAML
+type SAMLList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SAML `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SAML{}, &SAMLList{})
+}
@@ -17,6 +17,8 @@ package v1alpha1
 
 import (
 	"encoding/json"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -26,7 +28,7 @@ func (in *Ak) DeepCopyInto(out *Ak) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ak.
@@ -52,8 +54,8 @@ func (in *AkBlueprint) DeepCopyInto(out *AkBlueprint) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkBlueprint.
@@ -109,6 +111,11 @@ func (in *AkBlueprintList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AkBlueprintSpec) DeepCopyInto(out *AkBlueprintSpec) {
 	*out = *in
+	if in.BlueprintSpec != nil {
+		in, out := &in.BlueprintSpec, &out.BlueprintSpec
+		*out = new(BP)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkBlueprintSpec.
@@ -124,6 +131,27 @@ func (in *AkBlueprintSpec) DeepCopy() *AkBlueprintSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AkBlueprintStatus) DeepCopyInto(out *AkBlueprintStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastApplied != nil {
+		in, out := &in.LastApplied, &out.LastApplied
+		*out = (*in).DeepCopy()
+	}
+	if in.ManagedModels != nil {
+		in, out := &in.ManagedModels, &out.ManagedModels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Logs != nil {
+		in, out := &in.Logs, &out.Logs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkBlueprintStatus.
@@ -137,31 +165,26 @@ func (in *AkBlueprintStatus) DeepCopy() *AkBlueprintStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AkList) DeepCopyInto(out *AkList) {
+func (in *AkBrand) DeepCopyInto(out *AkBrand) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Ak, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkList.
-func (in *AkList) DeepCopy() *AkList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkBrand.
+func (in *AkBrand) DeepCopy() *AkBrand {
 	if in == nil {
 		return nil
 	}
-	out := new(AkList)
+	out := new(AkBrand)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *AkList) DeepCopyObject() runtime.Object {
+func (in *AkBrand) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -169,170 +192,201 @@ func (in *AkList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AkSpec) DeepCopyInto(out *AkSpec) {
+func (in *AkBrandCSS) DeepCopyInto(out *AkBrandCSS) {
 	*out = *in
-	if in.Values != nil {
-		in, out := &in.Values, &out.Values
-		*out = make(json.RawMessage, len(*in))
-		copy(*out, *in)
-	}
-	if in.Blueprints != nil {
-		in, out := &in.Blueprints, &out.Blueprints
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkSpec.
-func (in *AkSpec) DeepCopy() *AkSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkBrandCSS.
+func (in *AkBrandCSS) DeepCopy() *AkBrandCSS {
 	if in == nil {
 		return nil
 	}
-	out := new(AkSpec)
+	out := new(AkBrandCSS)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AkStatus) DeepCopyInto(out *AkStatus) {
+func (in *AkBrandFlows) DeepCopyInto(out *AkBrandFlows) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStatus.
-func (in *AkStatus) DeepCopy() *AkStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkBrandFlows.
+func (in *AkBrandFlows) DeepCopy() *AkBrandFlows {
 	if in == nil {
 		return nil
 	}
-	out := new(AkStatus)
+	out := new(AkBrandFlows)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AuthentikInstance) DeepCopyInto(out *AuthentikInstance) {
+func (in *AkBrandImage) DeepCopyInto(out *AkBrandImage) {
 	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthentikInstance.
-func (in *AuthentikInstance) DeepCopy() *AuthentikInstance {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkBrandImage.
+func (in *AkBrandImage) DeepCopy() *AkBrandImage {
 	if in == nil {
 		return nil
 	}
-	out := new(AuthentikInstance)
+	out := new(AkBrandImage)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BP) DeepCopyInto(out *BP) {
+func (in *AkBrandList) DeepCopyInto(out *AkBrandList) {
 	*out = *in
-	in.Metadata.DeepCopyInto(&out.Metadata)
-	if in.Context != nil {
-		in, out := &in.Context, &out.Context
-		*out = (*in).DeepCopy()
-	}
-	if in.Entries != nil {
-		in, out := &in.Entries, &out.Entries
-		*out = make([]BPModel, len(*in))
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkBrand, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BP.
-func (in *BP) DeepCopy() *BP {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkBrandList.
+func (in *AkBrandList) DeepCopy() *AkBrandList {
 	if in == nil {
 		return nil
 	}
-	out := new(BP)
+	out := new(AkBrandList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkBrandList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BPMeta) DeepCopyInto(out *BPMeta) {
+func (in *AkBrandSpec) DeepCopyInto(out *AkBrandSpec) {
 	*out = *in
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
+	out.Instance = in.Instance
+	if in.Logo != nil {
+		in, out := &in.Logo, &out.Logo
+		*out = new(AkBrandImage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Favicon != nil {
+		in, out := &in.Favicon, &out.Favicon
+		*out = new(AkBrandImage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CSS != nil {
+		in, out := &in.CSS, &out.CSS
+		*out = new(AkBrandCSS)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Flows = in.Flows
+	if in.Attributes != nil {
+		in, out := &in.Attributes, &out.Attributes
 		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BPMeta.
-func (in *BPMeta) DeepCopy() *BPMeta {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkBrandSpec.
+func (in *AkBrandSpec) DeepCopy() *AkBrandSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(BPMeta)
+	out := new(AkBrandSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BPModel) DeepCopyInto(out *BPModel) {
+func (in *AkBrandStatus) DeepCopyInto(out *AkBrandStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.Identifiers != nil {
-		in, out := &in.Identifiers, &out.Identifiers
-		*out = (*in).DeepCopy()
-	}
-	if in.Attrs != nil {
-		in, out := &in.Attrs, &out.Attrs
-		*out = (*in).DeepCopy()
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BPModel.
-func (in *BPModel) DeepCopy() *BPModel {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkBrandStatus.
+func (in *AkBrandStatus) DeepCopy() *AkBrandStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(BPModel)
+	out := new(AkBrandStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ConfigmapSettings) DeepCopyInto(out *ConfigmapSettings) {
+func (in *AkCertificate) DeepCopyInto(out *AkCertificate) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigmapSettings.
-func (in *ConfigmapSettings) DeepCopy() *ConfigmapSettings {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkCertificate.
+func (in *AkCertificate) DeepCopy() *AkCertificate {
 	if in == nil {
 		return nil
 	}
-	out := new(ConfigmapSettings)
+	out := new(AkCertificate)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkCertificate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDC) DeepCopyInto(out *OIDC) {
+func (in *AkCertificateList) DeepCopyInto(out *AkCertificateList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkCertificate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDC.
-func (in *OIDC) DeepCopy() *OIDC {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkCertificateList.
+func (in *AkCertificateList) DeepCopy() *AkCertificateList {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDC)
+	out := new(AkCertificateList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *OIDC) DeepCopyObject() runtime.Object {
+func (in *AkCertificateList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -340,68 +394,69 @@ func (in *OIDC) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDCApplication) DeepCopyInto(out *OIDCApplication) {
+func (in *AkCertificateSpec) DeepCopyInto(out *AkCertificateSpec) {
 	*out = *in
-	out.ConfigMap = in.ConfigMap
-	if in.BackChannelProviders != nil {
-		in, out := &in.BackChannelProviders, &out.BackChannelProviders
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	out.OIDCApplicationUISettings = in.OIDCApplicationUISettings
+	out.Instance = in.Instance
+	out.Secret = in.Secret
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCApplication.
-func (in *OIDCApplication) DeepCopy() *OIDCApplication {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkCertificateSpec.
+func (in *AkCertificateSpec) DeepCopy() *AkCertificateSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDCApplication)
+	out := new(AkCertificateSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDCApplicationUISettings) DeepCopyInto(out *OIDCApplicationUISettings) {
+func (in *AkCertificateStatus) DeepCopyInto(out *AkCertificateStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NotAfter != nil {
+		in, out := &in.NotAfter, &out.NotAfter
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCApplicationUISettings.
-func (in *OIDCApplicationUISettings) DeepCopy() *OIDCApplicationUISettings {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkCertificateStatus.
+func (in *AkCertificateStatus) DeepCopy() *AkCertificateStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDCApplicationUISettings)
+	out := new(AkCertificateStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDCList) DeepCopyInto(out *OIDCList) {
+func (in *AkFlow) DeepCopyInto(out *AkFlow) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]OIDC, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCList.
-func (in *OIDCList) DeepCopy() *OIDCList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkFlow.
+func (in *AkFlow) DeepCopy() *AkFlow {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDCList)
+	out := new(AkFlow)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *OIDCList) DeepCopyObject() runtime.Object {
+func (in *AkFlow) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -409,104 +464,2271 @@ func (in *OIDCList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDCProvider) DeepCopyInto(out *OIDCProvider) {
+func (in *AkFlowList) DeepCopyInto(out *AkFlowList) {
 	*out = *in
-	out.Secret = in.Secret
-	in.ProtocolSettings.DeepCopyInto(&out.ProtocolSettings)
-	in.MachineToMachineSettings.DeepCopyInto(&out.MachineToMachineSettings)
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkFlow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProvider.
-func (in *OIDCProvider) DeepCopy() *OIDCProvider {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkFlowList.
+func (in *AkFlowList) DeepCopy() *AkFlowList {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDCProvider)
+	out := new(AkFlowList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkFlowList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDCProviderMachineToMachineSettings) DeepCopyInto(out *OIDCProviderMachineToMachineSettings) {
+func (in *AkFlowSpec) DeepCopyInto(out *AkFlowSpec) {
 	*out = *in
-	if in.TrustedOIDCSources != nil {
-		in, out := &in.TrustedOIDCSources, &out.TrustedOIDCSources
-		*out = make([]string, len(*in))
+	out.Instance = in.Instance
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]AkFlowStageBinding, len(*in))
 		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProviderMachineToMachineSettings.
-func (in *OIDCProviderMachineToMachineSettings) DeepCopy() *OIDCProviderMachineToMachineSettings {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkFlowSpec.
+func (in *AkFlowSpec) DeepCopy() *AkFlowSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDCProviderMachineToMachineSettings)
+	out := new(AkFlowSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDCProviderProtocolSettings) DeepCopyInto(out *OIDCProviderProtocolSettings) {
+func (in *AkFlowStageBinding) DeepCopyInto(out *AkFlowStageBinding) {
 	*out = *in
-	if in.Scopes != nil {
-		in, out := &in.Scopes, &out.Scopes
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProviderProtocolSettings.
-func (in *OIDCProviderProtocolSettings) DeepCopy() *OIDCProviderProtocolSettings {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkFlowStageBinding.
+func (in *AkFlowStageBinding) DeepCopy() *AkFlowStageBinding {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDCProviderProtocolSettings)
+	out := new(AkFlowStageBinding)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDCSpec) DeepCopyInto(out *OIDCSpec) {
+func (in *AkFlowStatus) DeepCopyInto(out *AkFlowStatus) {
 	*out = *in
-	out.Instance = in.Instance
-	if in.Providers != nil {
-		in, out := &in.Providers, &out.Providers
-		*out = make([]OIDCProvider, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.Applications != nil {
-		in, out := &in.Applications, &out.Applications
-		*out = make([]OIDCApplication, len(*in))
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCSpec.
-func (in *OIDCSpec) DeepCopy() *OIDCSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkFlowStatus.
+func (in *AkFlowStatus) DeepCopy() *AkFlowStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDCSpec)
+	out := new(AkFlowStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDCStatus) DeepCopyInto(out *OIDCStatus) {
+func (in *AkGroup) DeepCopyInto(out *AkGroup) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCStatus.
-func (in *OIDCStatus) DeepCopy() *OIDCStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkGroup.
+func (in *AkGroup) DeepCopy() *AkGroup {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDCStatus)
+	out := new(AkGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkGroup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkGroupList) DeepCopyInto(out *AkGroupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkGroupList.
+func (in *AkGroupList) DeepCopy() *AkGroupList {
+	if in == nil {
+		return nil
+	}
+	out := new(AkGroupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkGroupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkGroupReference) DeepCopyInto(out *AkGroupReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkGroupReference.
+func (in *AkGroupReference) DeepCopy() *AkGroupReference {
+	if in == nil {
+		return nil
+	}
+	out := new(AkGroupReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkGroupSpec) DeepCopyInto(out *AkGroupSpec) {
+	*out = *in
+	out.Instance = in.Instance
+	if in.Attributes != nil {
+		in, out := &in.Attributes, &out.Attributes
+		*out = (*in).DeepCopy()
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkGroupSpec.
+func (in *AkGroupSpec) DeepCopy() *AkGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkGroupStatus) DeepCopyInto(out *AkGroupStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkGroupStatus.
+func (in *AkGroupStatus) DeepCopy() *AkGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkList) DeepCopyInto(out *AkList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Ak, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkList.
+func (in *AkList) DeepCopy() *AkList {
+	if in == nil {
+		return nil
+	}
+	out := new(AkList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkOutpost) DeepCopyInto(out *AkOutpost) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkOutpost.
+func (in *AkOutpost) DeepCopy() *AkOutpost {
+	if in == nil {
+		return nil
+	}
+	out := new(AkOutpost)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkOutpost) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkOutpostIngress) DeepCopyInto(out *AkOutpostIngress) {
+	*out = *in
+	if in.ClassName != nil {
+		in, out := &in.ClassName, &out.ClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkOutpostIngress.
+func (in *AkOutpostIngress) DeepCopy() *AkOutpostIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(AkOutpostIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkOutpostList) DeepCopyInto(out *AkOutpostList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkOutpost, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkOutpostList.
+func (in *AkOutpostList) DeepCopy() *AkOutpostList {
+	if in == nil {
+		return nil
+	}
+	out := new(AkOutpostList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkOutpostList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkOutpostService) DeepCopyInto(out *AkOutpostService) {
+	*out = *in
+	out.Type = in.Type
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkOutpostService.
+func (in *AkOutpostService) DeepCopy() *AkOutpostService {
+	if in == nil {
+		return nil
+	}
+	out := new(AkOutpostService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkOutpostSpec) DeepCopyInto(out *AkOutpostSpec) {
+	*out = *in
+	out.Instance = in.Instance
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	in.Service.DeepCopyInto(&out.Service)
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(AkOutpostIngress)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkOutpostSpec.
+func (in *AkOutpostSpec) DeepCopy() *AkOutpostSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkOutpostSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkOutpostStatus) DeepCopyInto(out *AkOutpostStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkOutpostStatus.
+func (in *AkOutpostStatus) DeepCopy() *AkOutpostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkOutpostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPolicy) DeepCopyInto(out *AkPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPolicy.
+func (in *AkPolicy) DeepCopy() *AkPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPolicyBinding) DeepCopyInto(out *AkPolicyBinding) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPolicyBinding.
+func (in *AkPolicyBinding) DeepCopy() *AkPolicyBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPolicyBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPolicyEventMatcher) DeepCopyInto(out *AkPolicyEventMatcher) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPolicyEventMatcher.
+func (in *AkPolicyEventMatcher) DeepCopy() *AkPolicyEventMatcher {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPolicyEventMatcher)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPolicyExpression) DeepCopyInto(out *AkPolicyExpression) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPolicyExpression.
+func (in *AkPolicyExpression) DeepCopy() *AkPolicyExpression {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPolicyExpression)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPolicyGroupMembership) DeepCopyInto(out *AkPolicyGroupMembership) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPolicyGroupMembership.
+func (in *AkPolicyGroupMembership) DeepCopy() *AkPolicyGroupMembership {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPolicyGroupMembership)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPolicyList) DeepCopyInto(out *AkPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPolicyList.
+func (in *AkPolicyList) DeepCopy() *AkPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPolicyPassword) DeepCopyInto(out *AkPolicyPassword) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPolicyPassword.
+func (in *AkPolicyPassword) DeepCopy() *AkPolicyPassword {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPolicyPassword)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPolicyReputation) DeepCopyInto(out *AkPolicyReputation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPolicyReputation.
+func (in *AkPolicyReputation) DeepCopy() *AkPolicyReputation {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPolicyReputation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPolicySpec) DeepCopyInto(out *AkPolicySpec) {
+	*out = *in
+	out.Instance = in.Instance
+	if in.Expression != nil {
+		in, out := &in.Expression, &out.Expression
+		*out = new(AkPolicyExpression)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GroupMembership != nil {
+		in, out := &in.GroupMembership, &out.GroupMembership
+		*out = new(AkPolicyGroupMembership)
+		**out = **in
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(AkPolicyPassword)
+		**out = **in
+	}
+	if in.Reputation != nil {
+		in, out := &in.Reputation, &out.Reputation
+		*out = new(AkPolicyReputation)
+		**out = **in
+	}
+	if in.EventMatcher != nil {
+		in, out := &in.EventMatcher, &out.EventMatcher
+		*out = new(AkPolicyEventMatcher)
+		**out = **in
+	}
+	if in.Bindings != nil {
+		in, out := &in.Bindings, &out.Bindings
+		*out = make([]AkPolicyBinding, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPolicySpec.
+func (in *AkPolicySpec) DeepCopy() *AkPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPolicyStatus) DeepCopyInto(out *AkPolicyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPolicyStatus.
+func (in *AkPolicyStatus) DeepCopy() *AkPolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPropertyMapping) DeepCopyInto(out *AkPropertyMapping) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPropertyMapping.
+func (in *AkPropertyMapping) DeepCopy() *AkPropertyMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPropertyMapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkPropertyMapping) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPropertyMappingExpression) DeepCopyInto(out *AkPropertyMappingExpression) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPropertyMappingExpression.
+func (in *AkPropertyMappingExpression) DeepCopy() *AkPropertyMappingExpression {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPropertyMappingExpression)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPropertyMappingList) DeepCopyInto(out *AkPropertyMappingList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkPropertyMapping, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPropertyMappingList.
+func (in *AkPropertyMappingList) DeepCopy() *AkPropertyMappingList {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPropertyMappingList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkPropertyMappingList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPropertyMappingSAML) DeepCopyInto(out *AkPropertyMappingSAML) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPropertyMappingSAML.
+func (in *AkPropertyMappingSAML) DeepCopy() *AkPropertyMappingSAML {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPropertyMappingSAML)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPropertyMappingScope) DeepCopyInto(out *AkPropertyMappingScope) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPropertyMappingScope.
+func (in *AkPropertyMappingScope) DeepCopy() *AkPropertyMappingScope {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPropertyMappingScope)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPropertyMappingSpec) DeepCopyInto(out *AkPropertyMappingSpec) {
+	*out = *in
+	out.Instance = in.Instance
+	in.Expression.DeepCopyInto(&out.Expression)
+	if in.Scope != nil {
+		in, out := &in.Scope, &out.Scope
+		*out = new(AkPropertyMappingScope)
+		**out = **in
+	}
+	if in.SAML != nil {
+		in, out := &in.SAML, &out.SAML
+		*out = new(AkPropertyMappingSAML)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPropertyMappingSpec.
+func (in *AkPropertyMappingSpec) DeepCopy() *AkPropertyMappingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPropertyMappingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkPropertyMappingStatus) DeepCopyInto(out *AkPropertyMappingStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkPropertyMappingStatus.
+func (in *AkPropertyMappingStatus) DeepCopy() *AkPropertyMappingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkPropertyMappingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkSource) DeepCopyInto(out *AkSource) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkSource.
+func (in *AkSource) DeepCopy() *AkSource {
+	if in == nil {
+		return nil
+	}
+	out := new(AkSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkSource) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkSourceCredentials) DeepCopyInto(out *AkSourceCredentials) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkSourceCredentials.
+func (in *AkSourceCredentials) DeepCopy() *AkSourceCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(AkSourceCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkSourceList) DeepCopyInto(out *AkSourceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkSourceList.
+func (in *AkSourceList) DeepCopy() *AkSourceList {
+	if in == nil {
+		return nil
+	}
+	out := new(AkSourceList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkSourceList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkSourceOAuth) DeepCopyInto(out *AkSourceOAuth) {
+	*out = *in
+	out.Credentials = in.Credentials
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkSourceOAuth.
+func (in *AkSourceOAuth) DeepCopy() *AkSourceOAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(AkSourceOAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkSourceSAML) DeepCopyInto(out *AkSourceSAML) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkSourceSAML.
+func (in *AkSourceSAML) DeepCopy() *AkSourceSAML {
+	if in == nil {
+		return nil
+	}
+	out := new(AkSourceSAML)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkSourceSpec) DeepCopyInto(out *AkSourceSpec) {
+	*out = *in
+	out.Instance = in.Instance
+	if in.OAuth != nil {
+		in, out := &in.OAuth, &out.OAuth
+		*out = new(AkSourceOAuth)
+		**out = **in
+	}
+	if in.SAML != nil {
+		in, out := &in.SAML, &out.SAML
+		*out = new(AkSourceSAML)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkSourceSpec.
+func (in *AkSourceSpec) DeepCopy() *AkSourceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkSourceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkSourceStatus) DeepCopyInto(out *AkSourceStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkSourceStatus.
+func (in *AkSourceStatus) DeepCopy() *AkSourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkSourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkSpec) DeepCopyInto(out *AkSpec) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make(json.RawMessage, len(*in))
+		copy(*out, *in)
+	}
+	if in.Blueprints != nil {
+		in, out := &in.Blueprints, &out.Blueprints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkSpec.
+func (in *AkSpec) DeepCopy() *AkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkStage) DeepCopyInto(out *AkStage) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStage.
+func (in *AkStage) DeepCopy() *AkStage {
+	if in == nil {
+		return nil
+	}
+	out := new(AkStage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkStage) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkStageAuthenticatorValidate) DeepCopyInto(out *AkStageAuthenticatorValidate) {
+	*out = *in
+	if in.DeviceClasses != nil {
+		in, out := &in.DeviceClasses, &out.DeviceClasses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStageAuthenticatorValidate.
+func (in *AkStageAuthenticatorValidate) DeepCopy() *AkStageAuthenticatorValidate {
+	if in == nil {
+		return nil
+	}
+	out := new(AkStageAuthenticatorValidate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkStageConsent) DeepCopyInto(out *AkStageConsent) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStageConsent.
+func (in *AkStageConsent) DeepCopy() *AkStageConsent {
+	if in == nil {
+		return nil
+	}
+	out := new(AkStageConsent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkStageIdentification) DeepCopyInto(out *AkStageIdentification) {
+	*out = *in
+	if in.UserFields != nil {
+		in, out := &in.UserFields, &out.UserFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStageIdentification.
+func (in *AkStageIdentification) DeepCopy() *AkStageIdentification {
+	if in == nil {
+		return nil
+	}
+	out := new(AkStageIdentification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkStageList) DeepCopyInto(out *AkStageList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStageList.
+func (in *AkStageList) DeepCopy() *AkStageList {
+	if in == nil {
+		return nil
+	}
+	out := new(AkStageList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkStageList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkStagePassword) DeepCopyInto(out *AkStagePassword) {
+	*out = *in
+	if in.Backends != nil {
+		in, out := &in.Backends, &out.Backends
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStagePassword.
+func (in *AkStagePassword) DeepCopy() *AkStagePassword {
+	if in == nil {
+		return nil
+	}
+	out := new(AkStagePassword)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkStageSpec) DeepCopyInto(out *AkStageSpec) {
+	*out = *in
+	if in.Identification != nil {
+		in, out := &in.Identification, &out.Identification
+		*out = new(AkStageIdentification)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(AkStagePassword)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AuthenticatorValidate != nil {
+		in, out := &in.AuthenticatorValidate, &out.AuthenticatorValidate
+		*out = new(AkStageAuthenticatorValidate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UserLogin != nil {
+		in, out := &in.UserLogin, &out.UserLogin
+		*out = new(AkStageUserLogin)
+		**out = **in
+	}
+	if in.Consent != nil {
+		in, out := &in.Consent, &out.Consent
+		*out = new(AkStageConsent)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStageSpec.
+func (in *AkStageSpec) DeepCopy() *AkStageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkStageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkStageStatus) DeepCopyInto(out *AkStageStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStageStatus.
+func (in *AkStageStatus) DeepCopy() *AkStageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkStageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkStageUserLogin) DeepCopyInto(out *AkStageUserLogin) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStageUserLogin.
+func (in *AkStageUserLogin) DeepCopy() *AkStageUserLogin {
+	if in == nil {
+		return nil
+	}
+	out := new(AkStageUserLogin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkStatus) DeepCopyInto(out *AkStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Blueprints != nil {
+		in, out := &in.Blueprints, &out.Blueprints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkStatus.
+func (in *AkStatus) DeepCopy() *AkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkUser) DeepCopyInto(out *AkUser) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkUser.
+func (in *AkUser) DeepCopy() *AkUser {
+	if in == nil {
+		return nil
+	}
+	out := new(AkUser)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkUser) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkUserList) DeepCopyInto(out *AkUserList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkUser, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkUserList.
+func (in *AkUserList) DeepCopy() *AkUserList {
+	if in == nil {
+		return nil
+	}
+	out := new(AkUserList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkUserList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkUserSpec) DeepCopyInto(out *AkUserSpec) {
+	*out = *in
+	out.Instance = in.Instance
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Attributes != nil {
+		in, out := &in.Attributes, &out.Attributes
+		*out = (*in).DeepCopy()
+	}
+	if in.Token != nil {
+		in, out := &in.Token, &out.Token
+		*out = new(AkUserToken)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkUserSpec.
+func (in *AkUserSpec) DeepCopy() *AkUserSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkUserSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkUserStatus) DeepCopyInto(out *AkUserStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TokenRotatedAt != nil {
+		in, out := &in.TokenRotatedAt, &out.TokenRotatedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkUserStatus.
+func (in *AkUserStatus) DeepCopy() *AkUserStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkUserStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkUserToken) DeepCopyInto(out *AkUserToken) {
+	*out = *in
+	out.Secret = in.Secret
+	if in.Rotation != nil {
+		in, out := &in.Rotation, &out.Rotation
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkUserToken.
+func (in *AkUserToken) DeepCopy() *AkUserToken {
+	if in == nil {
+		return nil
+	}
+	out := new(AkUserToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthentikInstance) DeepCopyInto(out *AuthentikInstance) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthentikInstance.
+func (in *AuthentikInstance) DeepCopy() *AuthentikInstance {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthentikInstance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BP) DeepCopyInto(out *BP) {
+	*out = *in
+	in.Metadata.DeepCopyInto(&out.Metadata)
+	if in.Context != nil {
+		in, out := &in.Context, &out.Context
+		*out = (*in).DeepCopy()
+	}
+	if in.Entries != nil {
+		in, out := &in.Entries, &out.Entries
+		*out = make([]BPModel, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BP.
+func (in *BP) DeepCopy() *BP {
+	if in == nil {
+		return nil
+	}
+	out := new(BP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BPMeta) DeepCopyInto(out *BPMeta) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BPMeta.
+func (in *BPMeta) DeepCopy() *BPMeta {
+	if in == nil {
+		return nil
+	}
+	out := new(BPMeta)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BPModel) DeepCopyInto(out *BPModel) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Identifiers != nil {
+		in, out := &in.Identifiers, &out.Identifiers
+		*out = (*in).DeepCopy()
+	}
+	if in.Attrs != nil {
+		in, out := &in.Attrs, &out.Attrs
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BPModel.
+func (in *BPModel) DeepCopy() *BPModel {
+	if in == nil {
+		return nil
+	}
+	out := new(BPModel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigmapSettings) DeepCopyInto(out *ConfigmapSettings) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigmapSettings.
+func (in *ConfigmapSettings) DeepCopy() *ConfigmapSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigmapSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPApplication) DeepCopyInto(out *LDAPApplication) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPApplication.
+func (in *LDAPApplication) DeepCopy() *LDAPApplication {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPApplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPBlueprintStatus) DeepCopyInto(out *LDAPBlueprintStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPBlueprintStatus.
+func (in *LDAPBlueprintStatus) DeepCopy() *LDAPBlueprintStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPBlueprintStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPOutpost) DeepCopyInto(out *LDAPOutpost) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPOutpost.
+func (in *LDAPOutpost) DeepCopy() *LDAPOutpost {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPOutpost)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPProvider) DeepCopyInto(out *LDAPProvider) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPProvider.
+func (in *LDAPProvider) DeepCopy() *LDAPProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LDAPProvider) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPProviderList) DeepCopyInto(out *LDAPProviderList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LDAPProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPProviderList.
+func (in *LDAPProviderList) DeepCopy() *LDAPProviderList {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPProviderList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LDAPProviderList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPProviderSpec) DeepCopyInto(out *LDAPProviderSpec) {
+	*out = *in
+	out.Instance = in.Instance
+	out.Secret = in.Secret
+	out.Application = in.Application
+	out.Outpost = in.Outpost
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPProviderSpec.
+func (in *LDAPProviderSpec) DeepCopy() *LDAPProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPProviderStatus) DeepCopyInto(out *LDAPProviderStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Blueprints != nil {
+		in, out := &in.Blueprints, &out.Blueprints
+		*out = make([]LDAPBlueprintStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPProviderStatus.
+func (in *LDAPProviderStatus) DeepCopy() *LDAPProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDC) DeepCopyInto(out *OIDC) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDC.
+func (in *OIDC) DeepCopy() *OIDC {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDC)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OIDC) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCApplication) DeepCopyInto(out *OIDCApplication) {
+	*out = *in
+	out.ConfigMap = in.ConfigMap
+	if in.BackChannelProviders != nil {
+		in, out := &in.BackChannelProviders, &out.BackChannelProviders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AccessGroups != nil {
+		in, out := &in.AccessGroups, &out.AccessGroups
+		*out = make([]AkGroupReference, len(*in))
+		copy(*out, *in)
+	}
+	out.OIDCApplicationUISettings = in.OIDCApplicationUISettings
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCApplication.
+func (in *OIDCApplication) DeepCopy() *OIDCApplication {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCApplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCApplicationStatus) DeepCopyInto(out *OIDCApplicationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCApplicationStatus.
+func (in *OIDCApplicationStatus) DeepCopy() *OIDCApplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCApplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCApplicationUISettings) DeepCopyInto(out *OIDCApplicationUISettings) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCApplicationUISettings.
+func (in *OIDCApplicationUISettings) DeepCopy() *OIDCApplicationUISettings {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCApplicationUISettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCList) DeepCopyInto(out *OIDCList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OIDC, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCList.
+func (in *OIDCList) DeepCopy() *OIDCList {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OIDCList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCProvider) DeepCopyInto(out *OIDCProvider) {
+	*out = *in
+	out.Secret = in.Secret
+	in.ProtocolSettings.DeepCopyInto(&out.ProtocolSettings)
+	in.MachineToMachineSettings.DeepCopyInto(&out.MachineToMachineSettings)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProvider.
+func (in *OIDCProvider) DeepCopy() *OIDCProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCProviderMachineToMachineSettings) DeepCopyInto(out *OIDCProviderMachineToMachineSettings) {
+	*out = *in
+	if in.TrustedOIDCSources != nil {
+		in, out := &in.TrustedOIDCSources, &out.TrustedOIDCSources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProviderMachineToMachineSettings.
+func (in *OIDCProviderMachineToMachineSettings) DeepCopy() *OIDCProviderMachineToMachineSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCProviderMachineToMachineSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCProviderProtocolSettings) DeepCopyInto(out *OIDCProviderProtocolSettings) {
+	*out = *in
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PropertyMappings != nil {
+		in, out := &in.PropertyMappings, &out.PropertyMappings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProviderProtocolSettings.
+func (in *OIDCProviderProtocolSettings) DeepCopy() *OIDCProviderProtocolSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCProviderProtocolSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCProviderStatus) DeepCopyInto(out *OIDCProviderStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProviderStatus.
+func (in *OIDCProviderStatus) DeepCopy() *OIDCProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCSpec) DeepCopyInto(out *OIDCSpec) {
+	*out = *in
+	out.Instance = in.Instance
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]OIDCProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Applications != nil {
+		in, out := &in.Applications, &out.Applications
+		*out = make([]OIDCApplication, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCSpec.
+func (in *OIDCSpec) DeepCopy() *OIDCSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCStatus) DeepCopyInto(out *OIDCStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]OIDCProviderStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Applications != nil {
+		in, out := &in.Applications, &out.Applications
+		*out = make([]OIDCApplicationStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCStatus.
+func (in *OIDCStatus) DeepCopy() *OIDCStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Proxy) DeepCopyInto(out *Proxy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Proxy.
+func (in *Proxy) DeepCopy() *Proxy {
+	if in == nil {
+		return nil
+	}
+	out := new(Proxy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Proxy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyApplication) DeepCopyInto(out *ProxyApplication) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	out.ProxyApplicationUISettings = in.ProxyApplicationUISettings
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyApplication.
+func (in *ProxyApplication) DeepCopy() *ProxyApplication {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyApplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyApplicationStatus) DeepCopyInto(out *ProxyApplicationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyApplicationStatus.
+func (in *ProxyApplicationStatus) DeepCopy() *ProxyApplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyApplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyBasicAuth) DeepCopyInto(out *ProxyBasicAuth) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyBasicAuth.
+func (in *ProxyBasicAuth) DeepCopy() *ProxyBasicAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyBasicAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyList) DeepCopyInto(out *ProxyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Proxy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyList.
+func (in *ProxyList) DeepCopy() *ProxyList {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProxyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyProvider) DeepCopyInto(out *ProxyProvider) {
+	*out = *in
+	in.ProtocolSettings.DeepCopyInto(&out.ProtocolSettings)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyProvider.
+func (in *ProxyProvider) DeepCopy() *ProxyProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyProviderProtocolSettings) DeepCopyInto(out *ProxyProviderProtocolSettings) {
+	*out = *in
+	if in.BasicAuth != nil {
+		in, out := &in.BasicAuth, &out.BasicAuth
+		*out = new(ProxyBasicAuth)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyProviderProtocolSettings.
+func (in *ProxyProviderProtocolSettings) DeepCopy() *ProxyProviderProtocolSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyProviderProtocolSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyProviderStatus) DeepCopyInto(out *ProxyProviderStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyProviderStatus.
+func (in *ProxyProviderStatus) DeepCopy() *ProxyProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxySpec) DeepCopyInto(out *ProxySpec) {
+	*out = *in
+	out.Instance = in.Instance
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]ProxyProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Applications != nil {
+		in, out := &in.Applications, &out.Applications
+		*out = make([]ProxyApplication, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxySpec.
+func (in *ProxySpec) DeepCopy() *ProxySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyStatus) DeepCopyInto(out *ProxyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]ProxyProviderStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Applications != nil {
+		in, out := &in.Applications, &out.Applications
+		*out = make([]ProxyApplicationStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyStatus.
+func (in *ProxyStatus) DeepCopy() *ProxyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAML) DeepCopyInto(out *SAML) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAML.
+func (in *SAML) DeepCopy() *SAML {
+	if in == nil {
+		return nil
+	}
+	out := new(SAML)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SAML) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLApplication) DeepCopyInto(out *SAMLApplication) {
+	*out = *in
+	out.ConfigMap = in.ConfigMap
+	out.SAMLApplicationUISettings = in.SAMLApplicationUISettings
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLApplication.
+func (in *SAMLApplication) DeepCopy() *SAMLApplication {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLApplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLApplicationStatus) DeepCopyInto(out *SAMLApplicationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLApplicationStatus.
+func (in *SAMLApplicationStatus) DeepCopy() *SAMLApplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLApplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLList) DeepCopyInto(out *SAMLList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SAML, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLList.
+func (in *SAMLList) DeepCopy() *SAMLList {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SAMLList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLProvider) DeepCopyInto(out *SAMLProvider) {
+	*out = *in
+	in.ProtocolSettings.DeepCopyInto(&out.ProtocolSettings)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLProvider.
+func (in *SAMLProvider) DeepCopy() *SAMLProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLProviderProtocolSettings) DeepCopyInto(out *SAMLProviderProtocolSettings) {
+	*out = *in
+	if in.PropertyMappings != nil {
+		in, out := &in.PropertyMappings, &out.PropertyMappings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLProviderProtocolSettings.
+func (in *SAMLProviderProtocolSettings) DeepCopy() *SAMLProviderProtocolSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLProviderProtocolSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLProviderStatus) DeepCopyInto(out *SAMLProviderStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLProviderStatus.
+func (in *SAMLProviderStatus) DeepCopy() *SAMLProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLSpec) DeepCopyInto(out *SAMLSpec) {
+	*out = *in
+	out.Instance = in.Instance
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]SAMLProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Applications != nil {
+		in, out := &in.Applications, &out.Applications
+		*out = make([]SAMLApplication, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLSpec.
+func (in *SAMLSpec) DeepCopy() *SAMLSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLStatus) DeepCopyInto(out *SAMLStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]SAMLProviderStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Applications != nil {
+		in, out := &in.Applications, &out.Applications
+		*out = make([]SAMLApplicationStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLStatus.
+func (in *SAMLStatus) DeepCopy() *SAMLStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLStatus)
 	in.DeepCopyInto(out)
 	return out
 }
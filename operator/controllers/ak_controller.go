@@ -12,18 +12,27 @@ package controllers
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/alexflint/go-arg"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/builder"   // Required for watching
-	"sigs.k8s.io/controller-runtime/pkg/client"    // Required for watching
+	"sigs.k8s.io/controller-runtime/pkg/builder" // Required for watching
+	"sigs.k8s.io/controller-runtime/pkg/client"  // Required for watching
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"   // Required for watching
 	klog "sigs.k8s.io/controller-runtime/pkg/log"  // Required for watching
 	"sigs.k8s.io/controller-runtime/pkg/predicate" // Required for watching
@@ -43,6 +52,9 @@ type AkReconciler struct {
 //+kubebuilder:rbac:groups=akm.goauthentik.io,resources=aks,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=akm.goauthentik.io,resources=aks/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=akm.goauthentik.io,resources=aks/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -67,13 +79,9 @@ func (r *AkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Re
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
-			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Cleanup according to the deletion policy is handled by our finalizer before the object disappears.
 			// Return and don't requeue
-			l.Info("Ak resource reconciliation triggered but disappeared. Checking for residual chart for uninstall then ignoring since object must have been deleted.")
-			_, err := r.UninstallChart(req.NamespacedName, actionConfig)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
+			l.Info("Ak resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -82,10 +90,34 @@ func (r *AkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Re
 	}
 	l.Info(fmt.Sprintf("Found Ak resource `%v` in `%v`.", crd.Name, crd.Namespace))
 
+	// FINALIZER
+	if !crd.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(crd, finalizerName) {
+			return ctrl.Result{}, nil
+		}
+		return r.finalizeAk(ctx, crd, actionConfig)
+	}
+	if !controllerutil.ContainsFinalizer(crd, finalizerName) {
+		controllerutil.AddFinalizer(crd, finalizerName)
+		err = r.Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// STATUS PROGRESSING
+	// only announce progress for generations we have not yet observed to avoid needless status writes
+	if setProgressingStatus(crd) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Helm Chart Identification
 	u, err := url.Parse(fmt.Sprintf("file://workspace/helm-charts/ak-%v.tgz", o.SrcVersion))
 	if err != nil {
-		return ctrl.Result{}, err
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "ChartNotFound", err)
 	}
 
 	// GET FILE-BASED BLUEPRINTS LIST
@@ -102,7 +134,7 @@ func (r *AkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Re
 	var vals map[string]interface{}
 	err = yaml.Unmarshal(crd.Spec.Values, &vals)
 	if err != nil {
-		return ctrl.Result{}, err
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "InvalidValues", err)
 	}
 
 	// OVERRIDE FILE-BASED BLUEPRINTS TO HELM
@@ -113,13 +145,15 @@ func (r *AkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Re
 	//     name: example-custom-blueprint-configmap
 	//     key: my-default-blueprint
 	var configBps []map[string]interface{}
+	var mounted []string
 	for i, config := range configs.Items {
 		count := 0
+		mounted = append(mounted, config.Name)
 		for j, data := range config.Data {
 			bp := &akmv1a1.BP{}
 			err = yaml.Unmarshal([]byte(data), bp)
 			if err != nil {
-				return ctrl.Result{}, err
+				return ctrl.Result{}, r.setFailedStatus(ctx, crd, "InvalidBlueprint", err)
 			}
 			l.Info(fmt.Sprintf("Capturing bpConfig: `%v`(%v), `%v` at `%v`)", config.Name, i, bp.Metadata.Name, j))
 			// TODO: add checks to ensure things like annotation path actually exists
@@ -146,15 +180,366 @@ func (r *AkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Re
 	fmt.Println(utils.PrettyPrint(vals))
 
 	// HELM INSTALL OR UPGRADE
-	_, err = r.UpgradeOrInstallChart(req.NamespacedName, u, actionConfig, vals)
+	rel, err := r.UpgradeOrInstallChart(req.NamespacedName, u, actionConfig, vals)
 	if err != nil {
+		meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+			Type:               akmv1a1.ConditionInstalled,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InstallFailed",
+			Message:            err.Error(),
+			ObservedGeneration: crd.Generation,
+		})
+		_ = r.setFailedStatus(ctx, crd, "InstallFailed", err)
 		t, _ := time.ParseDuration("10s")
 		return ctrl.Result{Requeue: true, RequeueAfter: t}, err
 	}
 
+	// STATUS UPDATE
+	// record what helm handed back to us so users do not need to read our logs
+	setInstalledStatus(crd, rel, mounted)
+	err = r.Status().Update(ctx, crd)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// setProgressingStatus marks a generation of the Ak resource we have not yet observed as progressing,
+// returning whether the status changed and so needs writing back.
+func setProgressingStatus(crd *akmv1a1.Ak) bool {
+	if crd.Status.ObservedGeneration == crd.Generation {
+		return false
+	}
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionProgressing,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Reconciling",
+		Message:            fmt.Sprintf("Reconciling generation %v.", crd.Generation),
+		ObservedGeneration: crd.Generation,
+	})
+	return true
+}
+
+// setInstalledStatus records the release helm installed or upgraded, and the blueprints mounted into it,
+// in the status of the Ak resource.
+func setInstalledStatus(crd *akmv1a1.Ak, rel *release.Release, mounted []string) {
+	crd.Status.Revision = rel.Version
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		crd.Status.ChartVersion = rel.Chart.Metadata.Version
+	}
+	crd.Status.Blueprints = mounted
+	crd.Status.ObservedGeneration = crd.Generation
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionInstalled,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Installed",
+		Message:            fmt.Sprintf("Release `%v` revision %v of chart version %v installed.", rel.Name, crd.Status.Revision, crd.Status.ChartVersion),
+		ObservedGeneration: crd.Generation,
+	})
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionProgressing,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Reconciled",
+		Message:            fmt.Sprintf("Generation %v reconciled.", crd.Generation),
+		ObservedGeneration: crd.Generation,
+	})
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionDegraded,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Reconciled",
+		Message:            "Last reconciliation succeeded.",
+		ObservedGeneration: crd.Generation,
+	})
+	ready := metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Deployed",
+		Message:            fmt.Sprintf("Release `%v` is deployed.", rel.Name),
+		ObservedGeneration: crd.Generation,
+	}
+	if rel.Info != nil && rel.Info.Status != release.StatusDeployed {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "NotDeployed"
+		ready.Message = fmt.Sprintf("Release `%v` is in state `%v`.", rel.Name, rel.Info.Status)
+	}
+	meta.SetStatusCondition(&crd.Status.Conditions, ready)
+}
+
+// setFailedStatus marks the Ak resource as degraded and not ready for the given reason and error,
+// then writes the status back to the cluster. The original error is returned so that it can
+// be handed back to the reconciler for a retry.
+func (r *AkReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.Ak, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	for _, t := range []string{akmv1a1.ConditionDegraded, akmv1a1.ConditionReady, akmv1a1.ConditionProgressing} {
+		status := metav1.ConditionFalse
+		if t == akmv1a1.ConditionDegraded {
+			status = metav1.ConditionTrue
+		}
+		meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+			Type:               t,
+			Status:             status,
+			Reason:             reason,
+			Message:            err.Error(),
+			ObservedGeneration: crd.Generation,
+		})
+	}
+	crd.Status.ObservedGeneration = crd.Generation
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, "Failed to update Ak status.")
+	}
+	return err
+}
+
+// finalizeAk applies the deletion policy of the Ak resource then releases our finalizer.
+// Deletion waits for any resources that depend on this instance to be removed first, since
+// they need authentik to still be running to clean up after themselves.
+func (r *AkReconciler) finalizeAk(ctx context.Context, crd *akmv1a1.Ak, actionConfig *action.Configuration) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	// BLOCK ON DEPENDANTS
+	dependants, err := r.findAkDependants(ctx, crd)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(dependants) > 0 {
+		l.Info(fmt.Sprintf("Ak `%v` deletion blocked by dependants %v", crd.Name, dependants))
+		meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+			Type:               akmv1a1.ConditionReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             "DeletionBlocked",
+			Message:            fmt.Sprintf("Deletion is waiting for dependants to be removed %v.", dependants),
+			ObservedGeneration: crd.Generation,
+		})
+		if uerr := r.Status().Update(ctx, crd); uerr != nil {
+			l.Error(uerr, "Failed to update Ak status.")
+		}
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+
+	// DELETION POLICY
+	switch crd.Spec.DeletionPolicy {
+	case akmv1a1.DeletionPolicySnapshot:
+		done, err := r.snapshotReleaseVolumes(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !done {
+			l.Info(fmt.Sprintf("Waiting for snapshots of Ak `%v` volumes to be ready...", crd.Name))
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+		err = r.deleteRelease(ctx, crd, actionConfig)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	case akmv1a1.DeletionPolicyDelete:
+		err = r.deleteRelease(ctx, crd, actionConfig)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	default:
+		l.Info(fmt.Sprintf("Retaining release, volumes, and secret of Ak `%v`.", crd.Name))
+	}
+
+	// RELEASE FINALIZER
+	controllerutil.RemoveFinalizer(crd, finalizerName)
+	err = r.Update(ctx, crd)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// akDependantKinds are the kinds of resources that select an Ak instance by the namespace in their spec.instance
+var akDependantKinds = []string{
+	"OIDC", "SAML", "Proxy", "LDAPProvider", "AkGroup", "AkUser", "AkFlow", "AkPolicy",
+	"AkCertificate", "AkBrand", "AkOutpost", "AkSource", "AkPropertyMapping",
+}
+
+// findAkDependants lists the resources that still rely on the given Ak instance to exist. AkBlueprints generated
+// by a resource that no longer exists are left out, they are only waiting on that resource to clean them up.
+func (r *AkReconciler) findAkDependants(ctx context.Context, crd *akmv1a1.Ak) ([]string, error) {
+	dependants := []string{}
+	// every resource of the dependant kinds, whichever instance it selects, so generated blueprints can be traced
+	existing := map[string]bool{}
+	for _, kind := range akDependantKinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(akmv1a1.GroupVersion.WithKind(kind + "List"))
+		err := r.List(ctx, list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			name := fmt.Sprintf("%v %v/%v", kind, item.GetNamespace(), item.GetName())
+			existing[name] = true
+			instance, _, _ := unstructured.NestedString(item.Object, "spec", "instance", "namespace")
+			if instance == crd.Namespace {
+				dependants = append(dependants, name)
+			}
+		}
+	}
+
+	bps := &akmv1a1.AkBlueprintList{}
+	err := r.List(ctx, bps, client.InNamespace(crd.Namespace))
+	if err != nil {
+		return nil, err
+	}
+	blueprints := []string{}
+	for _, bp := range bps.Items {
+		if source, ok := generatedBlueprintSource(&bp); ok && !existing[source] {
+			continue
+		}
+		blueprints = append(blueprints, fmt.Sprintf("AkBlueprint %v/%v", bp.Namespace, bp.Name))
+	}
+	return append(blueprints, dependants...), nil
+}
+
+// generatedBlueprintSource is the kind, namespace, and name of the resource that generated an AkBlueprint, from
+// the akm.goauthentik.io/<kind> and akm.goauthentik.io/<kind>-namespace labels generating controllers place on it
+func generatedBlueprintSource(bp *akmv1a1.AkBlueprint) (string, bool) {
+	for _, kind := range akDependantKinds {
+		label := "akm.goauthentik.io/" + strings.ToLower(kind)
+		name, ok := bp.Labels[label]
+		namespace := bp.Labels[label+"-namespace"]
+		if ok && namespace != "" {
+			return fmt.Sprintf("%v %v/%v", kind, namespace, name), true
+		}
+	}
+	return "", false
+}
+
+// deleteRelease uninstalls the helm release of the Ak resource along with the volumes and secret helm leaves behind.
+func (r *AkReconciler) deleteRelease(ctx context.Context, crd *akmv1a1.Ak, actionConfig *action.Configuration) error {
+	l := klog.FromContext(ctx)
+	nn := types.NamespacedName{Name: crd.Name, Namespace: crd.Namespace}
+
+	// the secret name lives in the release so must be found before it is uninstalled
+	secretName := ""
+	values, err := r.GetReleasedValues(crd.Namespace, crd.Name)
+	if err != nil && !goerrors.Is(err, driver.ErrReleaseNotFound) {
+		return err
+	}
+	if section, ok := values["secret"].(map[string]interface{}); ok {
+		secretName, _ = section["name"].(string)
+	}
+	pvcs, err := r.releasePVCs(ctx, crd)
+	if err != nil {
+		return err
+	}
+
+	// UNINSTALL
+	l.Info(fmt.Sprintf("Uninstalling release `%v` in `%v`", crd.Name, crd.Namespace))
+	_, err = r.UninstallChart(nn, actionConfig)
+	if err != nil && !goerrors.Is(err, driver.ErrReleaseNotFound) {
+		return err
+	}
+
+	// DELETE VOLUMES
+	// volumes from statefulsets in dependency charts are not removed by helm
+	for i := range pvcs {
+		l.Info(fmt.Sprintf("Deleting persistent volume claim `%v` in `%v`", pvcs[i].Name, pvcs[i].Namespace))
+		err = r.Delete(ctx, &pvcs[i])
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	// DELETE SECRET
+	if secretName != "" {
+		secret := &corev1.Secret{}
+		err = r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: crd.Namespace}, secret)
+		if err == nil {
+			l.Info(fmt.Sprintf("Deleting secret `%v` in `%v`", secretName, crd.Namespace))
+			err = r.Delete(ctx, secret)
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// releasePVCs lists the persistent volume claims belonging to the helm release of the Ak resource.
+// Our chart labels its resources with the instance override while dependency charts use the release name.
+func (r *AkReconciler) releasePVCs(ctx context.Context, crd *akmv1a1.Ak) ([]corev1.PersistentVolumeClaim, error) {
+	instances := []string{crd.Name}
+	if override, ok := crd.Labels["app.kubernetes.io/instance"]; ok && override != crd.Name {
+		instances = append(instances, override)
+	}
+	pvcs := []corev1.PersistentVolumeClaim{}
+	for _, instance := range instances {
+		list := &corev1.PersistentVolumeClaimList{}
+		err := r.List(ctx, list,
+			client.InNamespace(crd.Namespace),
+			client.MatchingLabels{"app.kubernetes.io/instance": instance})
+		if err != nil {
+			return nil, err
+		}
+		pvcs = append(pvcs, list.Items...)
+	}
+	return pvcs, nil
+}
+
+// volumeSnapshotGVK is the kind of the external snapshotter VolumeSnapshot which we use unstructured
+// to avoid depending on the snapshotter client for a single resource
+var volumeSnapshotGVK = schema.GroupVersionKind{
+	Group:   "snapshot.storage.k8s.io",
+	Version: "v1",
+	Kind:    "VolumeSnapshot",
+}
+
+// snapshotReleaseVolumes ensures a VolumeSnapshot exists for each persistent volume claim of the release
+// and reports whether all of them are ready to use. Snapshots are not owned by the Ak so they outlive it.
+func (r *AkReconciler) snapshotReleaseVolumes(ctx context.Context, crd *akmv1a1.Ak) (bool, error) {
+	l := klog.FromContext(ctx)
+	pvcs, err := r.releasePVCs(ctx, crd)
+	if err != nil {
+		return false, err
+	}
+	ready := true
+	for _, pvc := range pvcs {
+		name := fmt.Sprintf("%v-%v", pvc.Name, crd.DeletionTimestamp.Unix())
+		snapshot := &unstructured.Unstructured{}
+		snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+		err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: crd.Namespace}, snapshot)
+		if err != nil && errors.IsNotFound(err) {
+			l.Info(fmt.Sprintf("Creating volume snapshot `%v` of `%v` in `%v`", name, pvc.Name, crd.Namespace))
+			snapshot = volumeSnapshotForPVC(crd, &pvc, name)
+			err = r.Create(ctx, snapshot)
+			if err != nil {
+				return false, err
+			}
+			ready = false
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		readyToUse, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+		ready = ready && readyToUse
+	}
+	return ready, nil
+}
+
+// volumeSnapshotForPVC generates the VolumeSnapshot specification for a single persistent volume claim
+func volumeSnapshotForPVC(crd *akmv1a1.Ak, pvc *corev1.PersistentVolumeClaim, name string) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvc.Name,
+		},
+	}
+	if crd.Spec.VolumeSnapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = crd.Spec.VolumeSnapshotClassName
+	}
+	snapshot := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetName(name)
+	snapshot.SetNamespace(crd.Namespace)
+	snapshot.SetLabels(map[string]string{
+		"akm.goauthentik.io/ak": crd.Name,
+	})
+	return snapshot
+}
+
 // findAkForConfigMap finds the specific Ak resource context that needs to be passed to the reconciler
 // when the reconciliation is triggered by a configmap change rather than Ak resource directly.
 func (r *AkReconciler) findAkForConfigMap(ctx context.Context, configMap client.Object) []reconcile.Request {
@@ -199,7 +584,9 @@ func (r *AkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&akmv1a1.Ak{}).
+		// only reconcile on spec changes, status updates would otherwise trigger a helm upgrade each time
+		// label changes are also needed since the instance label is handed to the chart
+		For(&akmv1a1.Ak{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}))).
 		// dont let the docs lie to you about what Watches supports
 		//WatchesRawSource(
 		Watches(
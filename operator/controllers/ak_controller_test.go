@@ -0,0 +1,239 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+)
+
+func newTestAkReconciler(t *testing.T, objs ...runtime.Object) *AkReconciler {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).WithStatusSubresource(&akmv1a1.Ak{}).Build()
+	return &AkReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+}
+
+func TestFindAkDependants(t *testing.T) {
+	ak := &akmv1a1.Ak{ObjectMeta: metav1.ObjectMeta{Name: "ak", Namespace: "auth"}}
+	r := newTestAkReconciler(t,
+		&akmv1a1.AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "bp", Namespace: "auth"}},
+		&akmv1a1.AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "elsewhere", Namespace: "other"}},
+		&akmv1a1.OIDC{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "app"},
+			Spec:       akmv1a1.OIDCSpec{Instance: akmv1a1.AuthentikInstance{Namespace: "auth"}},
+		},
+		&akmv1a1.OIDC{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "app"},
+			Spec:       akmv1a1.OIDCSpec{Instance: akmv1a1.AuthentikInstance{Namespace: "other"}},
+		},
+		&akmv1a1.AkPropertyMapping{
+			ObjectMeta: metav1.ObjectMeta{Name: "groups", Namespace: "app"},
+			Spec:       akmv1a1.AkPropertyMappingSpec{Instance: akmv1a1.AuthentikInstance{Namespace: "auth"}},
+		},
+	)
+	got, err := r.findAkDependants(context.TODO(), ak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"AkBlueprint auth/bp", "OIDC app/app", "AkPropertyMapping app/groups"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findAkDependants() = %v, want %v", got, want)
+	}
+}
+
+func TestFinalizeAkAfterGeneratorRemoved(t *testing.T) {
+	now := metav1.Now()
+	ak := &akmv1a1.Ak{ObjectMeta: metav1.ObjectMeta{
+		Name:              "ak",
+		Namespace:         "auth",
+		Finalizers:        []string{finalizerName},
+		DeletionTimestamp: &now,
+	}}
+	generated := func(name, group string) *akmv1a1.AkBlueprint {
+		return &akmv1a1.AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "auth", Labels: map[string]string{
+			akGroupNameLabel:      group,
+			akGroupNamespaceLabel: "wiki",
+		}}}
+	}
+	editors := &akmv1a1.AkGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: "editors", Namespace: "wiki"},
+		Spec:       akmv1a1.AkGroupSpec{Instance: akmv1a1.AuthentikInstance{Namespace: "auth"}},
+	}
+	r := newTestAkReconciler(t, ak, editors, generated("wiki-group-editors", "editors"), generated("wiki-group-admins", "admins"))
+	ctx := context.TODO()
+
+	// the blueprint of the removed admins group does not hold up deletion, the editors group still does
+	got, err := r.findAkDependants(ctx, ak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"AkBlueprint auth/wiki-group-editors", "AkGroup wiki/editors"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findAkDependants() = %v, want %v", got, want)
+	}
+
+	if err := r.Delete(ctx, editors); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(ctx, generated("wiki-group-editors", "editors")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.finalizeAk(ctx, ak, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "ak", Namespace: "auth"}, &akmv1a1.Ak{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the retained Ak to be deleted despite the orphaned blueprint, got %v", err)
+	}
+}
+
+func TestReleasePVCs(t *testing.T) {
+	ak := &akmv1a1.Ak{ObjectMeta: metav1.ObjectMeta{
+		Name:      "ak",
+		Namespace: "auth",
+		Labels:    map[string]string{"app.kubernetes.io/instance": "auth"},
+	}}
+	pvc := func(name, instance string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "auth",
+			Labels:    map[string]string{"app.kubernetes.io/instance": instance},
+		}}
+	}
+	r := newTestAkReconciler(t, pvc("data-postgres-0", "ak"), pvc("ldap", "auth"), pvc("other", "other"))
+	pvcs, err := r.releasePVCs(context.TODO(), ak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, p := range pvcs {
+		names = append(names, p.Name)
+	}
+	want := []string{"data-postgres-0", "ldap"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("releasePVCs() = %v, want %v", names, want)
+	}
+}
+
+func TestVolumeSnapshotForPVC(t *testing.T) {
+	ak := &akmv1a1.Ak{
+		ObjectMeta: metav1.ObjectMeta{Name: "ak", Namespace: "auth"},
+		Spec:       akmv1a1.AkSpec{VolumeSnapshotClassName: "csi-snapclass"},
+	}
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-postgres-0", Namespace: "auth"}}
+	snapshot := volumeSnapshotForPVC(ak, pvc, "data-postgres-0-1")
+	if snapshot.GetKind() != "VolumeSnapshot" || snapshot.GetNamespace() != "auth" {
+		t.Errorf("unexpected snapshot metadata %v %v", snapshot.GetKind(), snapshot.GetNamespace())
+	}
+	source, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName")
+	class, _, _ := unstructured.NestedString(snapshot.Object, "spec", "volumeSnapshotClassName")
+	if source != "data-postgres-0" || class != "csi-snapclass" {
+		t.Errorf("unexpected snapshot spec %v", snapshot.Object["spec"])
+	}
+}
+
+func TestAkStatusConditions(t *testing.T) {
+	ak := &akmv1a1.Ak{ObjectMeta: metav1.ObjectMeta{Name: "ak", Namespace: "auth", Generation: 2}}
+	ak.Status.ObservedGeneration = 1
+
+	// a new generation is announced once
+	if !setProgressingStatus(ak) {
+		t.Fatal("expected an unobserved generation to be progressing")
+	}
+	progressing := meta.FindStatusCondition(ak.Status.Conditions, akmv1a1.ConditionProgressing)
+	if progressing == nil || progressing.Status != metav1.ConditionTrue || progressing.ObservedGeneration != 2 {
+		t.Errorf("progressing = %+v", progressing)
+	}
+
+	rel := &release.Release{
+		Name:    "ak",
+		Version: 3,
+		Chart:   &chart.Chart{Metadata: &chart.Metadata{Version: "1.2.3"}},
+		Info:    &release.Info{Status: release.StatusDeployed},
+	}
+	setInstalledStatus(ak, rel, []string{"bp-sample"})
+	if ak.Status.Revision != 3 || ak.Status.ChartVersion != "1.2.3" || ak.Status.ObservedGeneration != 2 {
+		t.Errorf("status = %+v", ak.Status)
+	}
+	if !reflect.DeepEqual(ak.Status.Blueprints, []string{"bp-sample"}) {
+		t.Errorf("blueprints = %v", ak.Status.Blueprints)
+	}
+	for condition, want := range map[string]metav1.ConditionStatus{
+		akmv1a1.ConditionReady:       metav1.ConditionTrue,
+		akmv1a1.ConditionInstalled:   metav1.ConditionTrue,
+		akmv1a1.ConditionProgressing: metav1.ConditionFalse,
+		akmv1a1.ConditionDegraded:    metav1.ConditionFalse,
+	} {
+		if got := meta.FindStatusCondition(ak.Status.Conditions, condition); got == nil || got.Status != want || got.ObservedGeneration != 2 {
+			t.Errorf("%v = %+v, want %v", condition, got, want)
+		}
+	}
+	if setProgressingStatus(ak) {
+		t.Error("expected an observed generation not to be progressing again")
+	}
+
+	// a release helm could not finish deploying is installed but not ready
+	rel.Info.Status = release.StatusFailed
+	setInstalledStatus(ak, rel, nil)
+	if ready := meta.FindStatusCondition(ak.Status.Conditions, akmv1a1.ConditionReady); ready.Status != metav1.ConditionFalse || ready.Reason != "NotDeployed" {
+		t.Errorf("ready = %+v", ready)
+	}
+}
+
+func TestAkFailedStatus(t *testing.T) {
+	ctx := context.TODO()
+	ak := &akmv1a1.Ak{ObjectMeta: metav1.ObjectMeta{Name: "ak", Namespace: "auth", Generation: 4}}
+	r := newTestAkReconciler(t, ak)
+
+	failure := errors.New("values are not yaml")
+	if err := r.setFailedStatus(ctx, ak, "InvalidValues", failure); err != failure {
+		t.Errorf("setFailedStatus() = %v, want the original error", err)
+	}
+	got := &akmv1a1.Ak{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "ak", Namespace: "auth"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.ObservedGeneration != 4 {
+		t.Errorf("observedGeneration = %v, want 4", got.Status.ObservedGeneration)
+	}
+	for condition, want := range map[string]metav1.ConditionStatus{
+		akmv1a1.ConditionReady:       metav1.ConditionFalse,
+		akmv1a1.ConditionProgressing: metav1.ConditionFalse,
+		akmv1a1.ConditionDegraded:    metav1.ConditionTrue,
+	} {
+		c := meta.FindStatusCondition(got.Status.Conditions, condition)
+		if c == nil || c.Status != want || c.Reason != "InvalidValues" || c.Message != failure.Error() {
+			t.Errorf("%v = %+v, want %v", condition, c, want)
+		}
+	}
+}
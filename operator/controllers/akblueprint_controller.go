@@ -13,10 +13,12 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 
@@ -30,16 +32,24 @@ import (
 	"github.com/alexflint/go-arg"
 	yaml_v3 "gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/yaml"
 
 	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
 	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/oci"
 )
 
 type AuthentikBlueprintInstance struct {
@@ -59,9 +69,14 @@ type AuthentikBlueprintInstance struct {
 	Content         string          `json:"content"`
 }
 
+// finalizerName is the finalizer we place on our resources so we can clean up outside of kubernetes before they are removed
+const finalizerName = "akm.goauthentik.io/finalizer"
+
 // AkBlueprintReconciler reconciles a AkBlueprint object
 type AkBlueprintReconciler struct {
 	utils.ControlBase
+	// OCIClient (optional) pulls oci blueprints, a default client is used if nil
+	OCIClient *oci.Client
 }
 
 //+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akblueprints,verbs=get;list;watch;create;update;patch;delete
@@ -79,7 +94,6 @@ func (r *AkBlueprintReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	arg.MustParse(&o)
 	//l.Info(utils.PrettyPrint(o))
 	tableName := "authentik_blueprints_blueprintinstance"
-	markedForDeletion := false
 
 	//// GET CRD WORKAROUND / MONKEY PATCH
 	//// currently the controller-runtime does not support yaml.v3 unmarshalling of custom YAML tags
@@ -125,20 +139,29 @@ func (r *AkBlueprintReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
-			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Cleanup is handled by our finalizer before the object disappears so there is nothing left to do.
 			// Return and don't requeue
-			l.Info("AkBlueprint trigger deletion...")
-			// I prefer early return but we actually cant do that here as we need the CRD and the DB to be ready
-			// the DB requires the CRD but I also dont want to open the DB twice.
-			markedForDeletion = true
-		} else {
-			// Error reading the object - requeue the request.
-			l.Error(err, "AkBlueprint trigger irretrievable, Retrying.")
-			return ctrl.Result{}, err
+			l.Info("AkBlueprint trigger but disappeared, already finalized.")
+			return ctrl.Result{}, nil
 		}
-	} else {
+		// Error reading the object - requeue the request.
+		l.Error(err, "AkBlueprint trigger irretrievable, Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info("AkBlueprint trigger.")
 
-		l.Info("AkBlueprint trigger.")
+	// FINALIZER
+	// we need the CRD and the DB to clean up so we hold deletion until we have done so
+	markedForDeletion := !crd.DeletionTimestamp.IsZero()
+	if markedForDeletion && !controllerutil.ContainsFinalizer(crd, finalizerName) {
+		return ctrl.Result{}, nil
+	}
+	if !markedForDeletion && !controllerutil.ContainsFinalizer(crd, finalizerName) {
+		controllerutil.AddFinalizer(crd, finalizerName)
+		err = r.Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
 	// FIND RELEVANT Ak resources
@@ -158,6 +181,11 @@ func (r *AkBlueprintReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			fmt.Sprintf("Too many Ak resources, cant decide between them `%v`.", list),
 			fmt.Errorf("Too many relevant Ak resources"))
 		return ctrl.Result{}, err
+	} else if len(list) == 0 && markedForDeletion {
+		// without authentik there is nothing for us to clean up so let the deletion go ahead
+		l.Info("No relevant Ak resource found, releasing finalizer.")
+		controllerutil.RemoveFinalizer(crd, finalizerName)
+		return ctrl.Result{}, r.Update(ctx, crd)
 	} else if len(list) == 0 {
 		err = errors.NewNotFound(
 			schema.GroupResource{
@@ -170,6 +198,24 @@ func (r *AkBlueprintReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	ak := list[0]
 	l.Info(fmt.Sprintf("Found relevant Ak resource."))
 
+	// API AND OCI STORAGE
+	// blueprints sent through the authentik API need neither the DB nor a configmap
+	if crd.Spec.StorageType == "api" || crd.Spec.StorageType == "oci" {
+		akc, err := r.NewAuthentikClient(ctx, ak)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		switch {
+		case crd.Spec.StorageType == "oci" && markedForDeletion:
+			return r.finalizeOCIBlueprint(ctx, akc, crd, o)
+		case crd.Spec.StorageType == "oci":
+			return r.reconcileOCIBlueprint(ctx, akc, crd, o)
+		case markedForDeletion:
+			return r.finalizeAPIBlueprint(ctx, akc, crd, o)
+		}
+		return r.reconcileAPIBlueprint(ctx, akc, crd, o)
+	}
+
 	// FIND AK RESOURCES RELEASED VALUES
 	// We find the Ak resources values so we can ensure we are searching for the correct
 	// secret
@@ -216,39 +262,25 @@ func (r *AkBlueprintReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	defer db.Close()
 	l.Info("Connected")
 
-	// DELETING DB ROW FOR REMOVED CRD
+	// CLEANING UP FOR REMOVED CRD
 	if markedForDeletion {
-		deleteColumnValues := map[string]interface{}{
-			//"path": "",
-			"name": req.NamespacedName.Name,
-		}
-		l.Info("Deleting...")
-		result, err := deleteRowsByColumnValues(db, tableName, deleteColumnValues)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
-		count, err := (*result).RowsAffected()
-		if count == 0 {
-			// no rows deleted
-			l.Info("Nothing deleted")
-		} else if count == 1 {
-			l.Info("Deleted")
-		} else {
-			l.Info("Multiple deleted")
-		}
-		return ctrl.Result{}, nil
+		return r.finalizeBlueprint(ctx, db, tableName, crd, o)
 	}
 
-	// DECODE CRD INTO STRUCTURED BLUEPRINT
+	// RENDER AND DECODE CRD INTO STRUCTURED BLUEPRINT
+	content, err := blueprintContent(crd)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 	bp := &akmv1a1.BP{}
-	decoder := yaml_v3.NewDecoder(bytes.NewReader([]byte(crd.Spec.Blueprint)))
+	decoder := yaml_v3.NewDecoder(bytes.NewReader([]byte(content)))
 	if err := decoder.Decode(bp); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	// CREATE CONFIGMAP
 	if crd.Spec.StorageType == "file" {
-		name := fmt.Sprintf("bp-%v-%v", crd.Namespace, crd.Name)
+		name := blueprintConfigMapName(crd)
 		cmWant, err := r.configForBlueprint(crd, name, crd.Namespace)
 		if err != nil {
 			return ctrl.Result{}, err
@@ -285,7 +317,7 @@ func (r *AkBlueprintReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
-	crdyml, err := yaml.Marshal(crd.Spec.Blueprint)
+	crdyml, err := yaml.Marshal(content)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -363,9 +395,552 @@ func (r *AkBlueprintReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			return ctrl.Result{}, err
 		}
 	}
+
+	// MIRROR AUTHENTIK STATUS
+	// authentik applies blueprints asynchronously so we read its view of the blueprint back
+	// on every reconcile, and poll so changes authentik makes on its own schedule are picked up
+	row, err := queryBlueprintInstance(db, tableName, crd)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	oldStatus := crd.Status.DeepCopy()
+	setStatusFromBlueprintInstance(&crd.Status, row, crd.Generation)
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		l.Info(fmt.Sprintf("Updating status of `%v` to `%v`", crd.Name, crd.Status.Status))
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: o.BlueprintPollInterval}, nil
+}
+
+// reconcileAPIBlueprint imports the blueprint through the authentik API and records the result in status.
+// authentik does not keep imported blueprints, so they are only imported again when their content changes
+// or the last import failed.
+func (r *AkBlueprintReconciler) reconcileAPIBlueprint(ctx context.Context, akc *authentik.Client, crd *akmv1a1.AkBlueprint, o utils.Opts) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+	oldStatus := crd.Status.DeepCopy()
+	content, err := blueprintContent(crd)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	hash := blueprintHash(content)
+	if crd.Status.LastAppliedHash == hash && meta.IsStatusConditionTrue(crd.Status.Conditions, akmv1a1.ConditionReady) {
+		l.Info(fmt.Sprintf("Blueprint `%v` already imported", crd.Name))
+		crd.Status.ObservedGeneration = crd.Generation
+		meta.FindStatusCondition(crd.Status.Conditions, akmv1a1.ConditionReady).ObservedGeneration = crd.Generation
+	} else {
+		l.Info(fmt.Sprintf("Importing blueprint `%v` through the authentik API...", crd.Name))
+		result, err := akc.ImportBlueprint(ctx, content)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		setStatusFromImportResult(&crd.Status, result, hash, metav1.Now(), crd.Generation)
+	}
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		l.Info(fmt.Sprintf("Updating status of `%v` to `%v`", crd.Name, crd.Status.Status))
+		err := r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if !meta.IsStatusConditionTrue(crd.Status.Conditions, akmv1a1.ConditionReady) {
+		// the blueprint may depend on objects that do not exist yet so keep trying
+		return ctrl.Result{RequeueAfter: o.BlueprintPollInterval}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
+// finalizeAPIBlueprint tears down an api blueprint if requested and releases our finalizer.
+// There is nothing else to clean up since authentik never stored the blueprint itself.
+func (r *AkBlueprintReconciler) finalizeAPIBlueprint(ctx context.Context, akc *authentik.Client, crd *akmv1a1.AkBlueprint, o utils.Opts) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+	if crd.Spec.Teardown {
+		content, err := blueprintContent(crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		absent, err := absentBlueprint(content)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		l.Info(fmt.Sprintf("Importing teardown of `%v` through the authentik API...", crd.Name))
+		result, err := akc.ImportBlueprint(ctx, absent)
+		if err != nil || !result.Success {
+			if time.Since(crd.DeletionTimestamp.Time) < o.BlueprintTeardownTimeout {
+				l.Info(fmt.Sprintf("Teardown of `%v` failed, retrying: %v", crd.Name, err))
+				return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+			}
+			l.Info(fmt.Sprintf("Timed out after %v trying to tear down `%v`, continuing.", o.BlueprintTeardownTimeout, crd.Name))
+		}
+	}
+	controllerutil.RemoveFinalizer(crd, finalizerName)
+	return ctrl.Result{}, r.Update(ctx, crd)
+}
+
+// reconcileOCIBlueprint registers the blueprint at the OCI reference with authentik and mirrors its status.
+// The blueprint is pulled first so that bad references or credentials show up in our status rather than
+// only in the authentik worker logs.
+func (r *AkBlueprintReconciler) reconcileOCIBlueprint(ctx context.Context, akc *authentik.Client, crd *akmv1a1.AkBlueprint, o utils.Opts) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+	oldStatus := crd.Status.DeepCopy()
+	ref, creds, _, err := r.pullOCIBlueprint(ctx, crd)
+	if err == nil && creds != nil && !crd.Spec.EmbedPullCredentials {
+		// webhooks may be off so this is checked here too, the credentials must never reach authentik unasked
+		err = fmt.Errorf("authentik can only pull from `%v` with the credentials of pull secret `%v` embedded in the blueprint path it stores and shows, set embedPullCredentials to allow this", ref.Registry, crd.Spec.PullSecret)
+	}
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to pull blueprint `%v` from `%v`", crd.Name, crd.Spec.OCIRef))
+		crd.Status.ObservedGeneration = crd.Generation
+		meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+			Type:               akmv1a1.ConditionReady,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: crd.Generation,
+			Reason:             "PullFailed",
+			Message:            err.Error(),
+		})
+		if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+			err = r.Status().Update(ctx, crd)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: o.BlueprintPollInterval}, nil
+	}
+
+	// REGISTER WITH AUTHENTIK
+	path := ref.Path(creds)
+	instances, err := akc.ListBlueprints(ctx, url.Values{"name": {crd.Name}})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var instance *authentik.BlueprintInstance
+	changed := true
+	switch len(instances) {
+	case 0:
+		l.Info(fmt.Sprintf("Registering blueprint `%v` from `%v`", crd.Name, ref))
+		instance, err = akc.CreateBlueprint(ctx, &authentik.BlueprintInstance{
+			Name:    crd.Name,
+			Path:    path,
+			Context: map[string]interface{}{},
+			Enabled: true,
+		})
+	case 1:
+		instance = &instances[0]
+		changed = instance.Path != path || !instance.Enabled
+		if changed {
+			l.Info(fmt.Sprintf("Updating blueprint `%v` to `%v`", crd.Name, ref))
+			instance.Path = path
+			instance.Enabled = true
+			instance, err = akc.UpdateBlueprint(ctx, instance)
+		}
+	default:
+		return ctrl.Result{}, errors.NewConflict(
+			schema.GroupResource{
+				Group:    "akm.goauthentik.io",
+				Resource: "AkBlueprint",
+			},
+			fmt.Sprintf("Too many (%v) authentik blueprints named `%v` cannot reconcile", len(instances), crd.Name),
+			nil,
+		)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if changed {
+		// apply straight away rather than waiting for authentiks next discovery
+		_, err = akc.ApplyBlueprint(ctx, instance.PK)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// MIRROR AUTHENTIK STATUS
+	setStatusFromBlueprintInstance(&crd.Status, blueprintInstanceFromAPI(instance), crd.Generation)
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		l.Info(fmt.Sprintf("Updating status of `%v` to `%v`", crd.Name, crd.Status.Status))
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: o.BlueprintPollInterval}, nil
+}
+
+// finalizeOCIBlueprint tears down an oci blueprint if requested, unregisters it from authentik and releases our finalizer.
+func (r *AkBlueprintReconciler) finalizeOCIBlueprint(ctx context.Context, akc *authentik.Client, crd *akmv1a1.AkBlueprint, o utils.Opts) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+	if crd.Spec.Teardown {
+		// authentik only applies what it pulls so the absent copy is imported through the API instead
+		_, _, content, err := r.pullOCIBlueprint(ctx, crd)
+		var result *authentik.BlueprintImportResult
+		if err == nil {
+			var absent string
+			absent, err = absentBlueprint(content)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			l.Info(fmt.Sprintf("Importing teardown of `%v` through the authentik API...", crd.Name))
+			result, err = akc.ImportBlueprint(ctx, absent)
+		}
+		if err != nil || !result.Success {
+			if time.Since(crd.DeletionTimestamp.Time) < o.BlueprintTeardownTimeout {
+				l.Info(fmt.Sprintf("Teardown of `%v` failed, retrying: %v", crd.Name, err))
+				return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+			}
+			l.Info(fmt.Sprintf("Timed out after %v trying to tear down `%v`, continuing.", o.BlueprintTeardownTimeout, crd.Name))
+		}
+	}
+
+	instances, err := akc.ListBlueprints(ctx, url.Values{"name": {crd.Name}})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for _, instance := range instances {
+		l.Info(fmt.Sprintf("Deleting authentik blueprint `%v`", instance.Name))
+		err = akc.DeleteBlueprint(ctx, instance.PK)
+		if err != nil && !authentik.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	}
+	controllerutil.RemoveFinalizer(crd, finalizerName)
+	return ctrl.Result{}, r.Update(ctx, crd)
+}
+
+// pullOCIBlueprint pulls the blueprint at the OCIRef of the AkBlueprint using its pull secret if any.
+func (r *AkBlueprintReconciler) pullOCIBlueprint(ctx context.Context, crd *akmv1a1.AkBlueprint) (*oci.Reference, *oci.Credentials, string, error) {
+	ref, err := oci.ParseReference(crd.Spec.OCIRef)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	var creds *oci.Credentials
+	if crd.Spec.PullSecret != "" {
+		secret := &corev1.Secret{}
+		err = r.Get(ctx, types.NamespacedName{Name: crd.Spec.PullSecret, Namespace: crd.Namespace}, secret)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		creds, err = oci.CredentialsFromDockerConfig(secret.Data[corev1.DockerConfigJsonKey], ref.Registry)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		if creds == nil {
+			return nil, nil, "", fmt.Errorf("pull secret `%v` has no credentials for `%v`", crd.Spec.PullSecret, ref.Registry)
+		}
+	}
+	oc := r.OCIClient
+	if oc == nil {
+		oc = &oci.Client{}
+	}
+	content, _, err := oc.Pull(ctx, ref, creds)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return ref, creds, content, nil
+}
+
+// blueprintInstanceFromAPI converts authentiks API view of a blueprint instance into our row representation
+func blueprintInstanceFromAPI(instance *authentik.BlueprintInstance) *AuthentikBlueprintInstance {
+	return &AuthentikBlueprintInstance{
+		Name:            instance.Name,
+		Path:            instance.Path,
+		LastApplied:     instance.LastApplied,
+		LastAppliedHash: instance.LastAppliedHash,
+		Status:          instance.Status,
+		Enabled:         instance.Enabled,
+		ManagedModels:   instance.ManagedModels,
+		Content:         instance.Content,
+	}
+}
+
+// blueprintHash hashes blueprint content the same way authentik does for last_applied_hash
+func blueprintHash(content string) string {
+	sum := sha512.Sum512([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// setStatusFromImportResult records the outcome of importing a blueprint through the authentik API.
+// Only successful imports update the applied time and hash so failed imports are retried.
+func setStatusFromImportResult(status *akmv1a1.AkBlueprintStatus, result *authentik.BlueprintImportResult, hash string, now metav1.Time, generation int64) {
+	status.ObservedGeneration = generation
+	status.Logs = []string{}
+	warned := false
+	for _, log := range result.Logs {
+		msg := fmt.Sprintf("%v: %v", log.LogLevel, log.Event)
+		if len(log.Attributes) > 0 {
+			attrs, err := json.Marshal(log.Attributes)
+			if err == nil {
+				msg = fmt.Sprintf("%v %v", msg, string(attrs))
+			}
+		}
+		status.Logs = append(status.Logs, msg)
+		warned = warned || log.LogLevel == "warning" || log.LogLevel == "error"
+	}
+	ready := metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		ObservedGeneration: generation,
+	}
+	if result.Success {
+		status.Status = "successful"
+		if warned {
+			status.Status = "warning"
+		}
+		status.LastApplied = &now
+		status.LastAppliedHash = hash
+		ready.Status = metav1.ConditionTrue
+		ready.Reason = "Imported"
+		ready.Message = fmt.Sprintf("Blueprint imported by authentik with status `%v`.", status.Status)
+	} else {
+		status.Status = "error"
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "ImportFailed"
+		ready.Message = "Authentik failed to import the blueprint, see the status logs."
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+}
+
+// finalizeBlueprint removes everything this AkBlueprint put into authentik and releases our finalizer.
+// If teardown is requested the objects the blueprint created are first removed by authentik, which we wait for
+// up to the configured timeout before removing the blueprint regardless.
+func (r *AkBlueprintReconciler) finalizeBlueprint(ctx context.Context, db *sql.DB, tableName string, crd *akmv1a1.AkBlueprint, o utils.Opts) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	// TEARDOWN AUTHENTIK OBJECTS
+	if crd.Spec.Teardown {
+		done, err := r.teardownBlueprint(ctx, db, tableName, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !done && time.Since(crd.DeletionTimestamp.Time) < o.BlueprintTeardownTimeout {
+			l.Info(fmt.Sprintf("Waiting for authentik to apply teardown of `%v`...", crd.Name))
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		} else if !done {
+			l.Info(fmt.Sprintf("Timed out after %v waiting for authentik to apply teardown of `%v`, continuing.", o.BlueprintTeardownTimeout, crd.Name))
+		}
+	}
+
+	// DELETE CONFIGMAP
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: blueprintConfigMapName(crd), Namespace: crd.Namespace}, cm)
+	if err == nil {
+		l.Info(fmt.Sprintf("Deleting configmap `%v` in `%v`", cm.Name, cm.Namespace))
+		err = r.Delete(ctx, cm)
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	// DELETE DB ROWS
+	// rows are removed both by name and by path since either may have been used to store the blueprint
+	deletions := []map[string]interface{}{
+		{"name": crd.Name},
+	}
+	if crd.Spec.File != "" {
+		deletions = append(deletions, map[string]interface{}{"path": blueprintInstancePath(crd.Spec.File)})
+	}
+	for _, deleteColumnValues := range deletions {
+		l.Info(fmt.Sprintf("Deleting db blueprints matching `%v`...", deleteColumnValues))
+		result, err := deleteRowsByColumnValues(db, tableName, deleteColumnValues)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		count, err := (*result).RowsAffected()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		l.Info(fmt.Sprintf("Deleted %v db blueprints", count))
+	}
+
+	// RELEASE FINALIZER
+	controllerutil.RemoveFinalizer(crd, finalizerName)
+	err = r.Update(ctx, crd)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// teardownBlueprint hands authentik a copy of the blueprint with every entry marked absent and reports whether
+// authentik has applied it since the AkBlueprint was marked for deletion.
+func (r *AkBlueprintReconciler) teardownBlueprint(ctx context.Context, db *sql.DB, tableName string, crd *akmv1a1.AkBlueprint) (bool, error) {
+	l := klog.FromContext(ctx)
+	row, err := queryBlueprintInstance(db, tableName, crd)
+	if err != nil {
+		return false, err
+	}
+	if row == nil {
+		// authentik never registered this blueprint so it cannot have created anything
+		return true, nil
+	}
+	if row.LastApplied.After(crd.DeletionTimestamp.Time) && row.Status == "successful" {
+		l.Info(fmt.Sprintf("Teardown of `%v` applied by authentik", crd.Name))
+		return true, nil
+	}
+
+	content, err := blueprintContent(crd)
+	if err != nil {
+		return false, err
+	}
+	absent, err := absentBlueprint(content)
+	if err != nil {
+		return false, err
+	}
+	if crd.Spec.StorageType == "file" {
+		teardown := crd.DeepCopy()
+		teardown.Spec.Blueprint = absent
+		teardown.Spec.BlueprintSpec = nil
+		cmWant, err := r.configForBlueprint(teardown, blueprintConfigMapName(crd), crd.Namespace)
+		if err != nil {
+			return false, err
+		}
+		err = r.Update(ctx, cmWant)
+		if err != nil && errors.IsNotFound(err) {
+			// the blueprint was never mounted so there is nothing authentik can tear down through it
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+	} else if row.Content != absent {
+		rowWant := *row
+		rowWant.LastUpdated = time.Now()
+		rowWant.Content = absent
+		_, err = updateRowByColumns(db, tableName, map[string]interface{}{"name": crd.Name}, rowWant)
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// absentBlueprint rewrites a blueprint so every entry has state absent, which instructs authentik to delete
+// the objects it describes. Entries are reversed so dependants are removed before what they depend on.
+// This works on the yaml node tree so that custom tags like !KeyOf survive unchanged.
+func absentBlueprint(blueprint string) (string, error) {
+	doc := &yaml_v3.Node{}
+	err := yaml_v3.Unmarshal([]byte(blueprint), doc)
+	if err != nil {
+		return "", err
+	}
+	if doc.Kind != yaml_v3.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml_v3.MappingNode {
+		return "", fmt.Errorf("blueprint is not a yaml mapping")
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "entries" {
+			continue
+		}
+		entries := root.Content[i+1]
+		if entries.Kind != yaml_v3.SequenceNode {
+			return "", fmt.Errorf("blueprint entries is not a yaml sequence")
+		}
+		for _, entry := range entries.Content {
+			if entry.Kind != yaml_v3.MappingNode {
+				return "", fmt.Errorf("blueprint entry is not a yaml mapping")
+			}
+			found := false
+			for j := 0; j+1 < len(entry.Content); j += 2 {
+				if entry.Content[j].Value == "state" {
+					entry.Content[j+1] = &yaml_v3.Node{Kind: yaml_v3.ScalarNode, Tag: "!!str", Value: "absent"}
+					found = true
+				}
+			}
+			if !found {
+				entry.Content = append(entry.Content,
+					&yaml_v3.Node{Kind: yaml_v3.ScalarNode, Tag: "!!str", Value: "state"},
+					&yaml_v3.Node{Kind: yaml_v3.ScalarNode, Tag: "!!str", Value: "absent"},
+				)
+			}
+		}
+		for lo, hi := 0, len(entries.Content)-1; lo < hi; lo, hi = lo+1, hi-1 {
+			entries.Content[lo], entries.Content[hi] = entries.Content[hi], entries.Content[lo]
+		}
+	}
+	out, err := yaml_v3.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// blueprintConfigMapName is the name of the configmap file based blueprints are stored in
+func blueprintConfigMapName(crd *akmv1a1.AkBlueprint) string {
+	return fmt.Sprintf("bp-%v-%v", crd.Namespace, crd.Name)
+}
+
+// queryBlueprintInstance finds the row authentik keeps for the given AkBlueprint, or nil if there is none yet.
+func queryBlueprintInstance(db *sql.DB, tableName string, crd *akmv1a1.AkBlueprint) (*AuthentikBlueprintInstance, error) {
+	if crd.Spec.StorageType == "file" {
+		// file blueprints are discovered by authentik which names them from their metadata
+		// so the only reliable identifier we have is the path relative to the blueprints dir
+		return queryRowByColumnValue(db, tableName, "path", blueprintInstancePath(crd.Spec.File))
+	}
+	return queryRowByColumnValue(db, tableName, "name", crd.Name)
+}
+
+// blueprintInstancePath converts an absolute blueprint file location into the path authentik
+// stores for it, which is relative to the /blueprints dir authentik discovers blueprints from.
+func blueprintInstancePath(file string) string {
+	cleanFP := filepath.Clean(file)
+	rel, err := filepath.Rel("/blueprints", cleanFP)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return cleanFP
+	}
+	return rel
+}
+
+// setStatusFromBlueprintInstance copies authentiks view of a blueprint instance into the AkBlueprint status.
+// A nil row means authentik has not yet registered the blueprint.
+func setStatusFromBlueprintInstance(status *akmv1a1.AkBlueprintStatus, row *AuthentikBlueprintInstance, generation int64) {
+	status.ObservedGeneration = generation
+	ready := metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		ObservedGeneration: generation,
+	}
+	if row == nil {
+		status.Status = ""
+		status.LastApplied = nil
+		status.LastAppliedHash = ""
+		status.ManagedModels = nil
+		ready.Status = metav1.ConditionUnknown
+		ready.Reason = "NotRegistered"
+		ready.Message = "Blueprint has not yet been registered by authentik."
+		meta.SetStatusCondition(&status.Conditions, ready)
+		return
+	}
+
+	status.Status = row.Status
+	status.LastAppliedHash = row.LastAppliedHash
+	status.ManagedModels = row.ManagedModels
+	if row.LastApplied.IsZero() {
+		status.LastApplied = nil
+	} else {
+		lastApplied := metav1.NewTime(row.LastApplied)
+		status.LastApplied = &lastApplied
+	}
+
+	// https://github.com/goauthentik/authentik/blob/main/authentik/blueprints/models.py
+	switch row.Status {
+	case "successful", "warning":
+		ready.Status = metav1.ConditionTrue
+		ready.Reason = "Applied"
+		ready.Message = fmt.Sprintf("Blueprint applied by authentik with status `%v`.", row.Status)
+	case "error":
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "ApplyFailed"
+		ready.Message = "Authentik failed to apply the blueprint, check the authentik worker logs."
+	case "orphaned":
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "Orphaned"
+		ready.Message = "Authentik can no longer find the blueprint source."
+	default:
+		ready.Status = metav1.ConditionUnknown
+		ready.Reason = "Pending"
+		ready.Message = fmt.Sprintf("Blueprint has status `%v` and is waiting to be applied by authentik.", row.Status)
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+}
+
 func addRowBySchema(db *sql.DB, row *AuthentikBlueprintInstance, tableName string) error {
 	stmt := fmt.Sprintf("INSERT INTO %v (created,last_updated,managed,instance_uuid,name,metadata,path,context,last_applied,last_applied_hash,status,enabled,managed_models,content) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)", tableName)
 
@@ -419,8 +994,8 @@ func updateRowByColumns(db *sql.DB, tableName string, columnValues map[string]in
 		"metadata":          newValues.Metadata,
 		"path":              newValues.Path,
 		"context":           newValues.Context,
-		"last_applied":      newValues.LastApplied,
-		"last_applied_hash": newValues.LastAppliedHash,
+		"last_applied":      nil, // Owned by authentik, overwriting would lose the real apply time
+		"last_applied_hash": nil, // Owned by authentik, it compares this to the content hash to decide when to apply
 		"status":            nil,
 		"enabled":           newValues.Enabled,
 		"managed_models":    pq.Array(newValues.ManagedModels),
@@ -543,10 +1118,11 @@ func queryRowByColumnValue(db *sql.DB, tableName string, columnName string, colu
 
 	// Create a new struct instance to hold the row data
 	var result AuthentikBlueprintInstance
+	var managed sql.NullString
 
 	// Scan the row data into the struct fields
 	err := row.Scan(
-		&result.Created, &result.LastUpdated, &result.Managed, &result.InstanceUUID, &result.Name, &result.Metadata, &result.Path, &result.Context, &result.LastApplied, &result.LastAppliedHash, &result.Status, &result.Enabled, &result.ManagedModels, &result.Content,
+		&result.Created, &result.LastUpdated, &managed, &result.InstanceUUID, &result.Name, &result.Metadata, &result.Path, &result.Context, &result.LastApplied, &result.LastAppliedHash, &result.Status, &result.Enabled, pq.Array(&result.ManagedModels), &result.Content,
 	)
 	//err := row.Scan(&rowData.ID, &rowData.Name, &rowData.Email, &rowData.JSON)
 	if err != nil {
@@ -556,6 +1132,9 @@ func queryRowByColumnValue(db *sql.DB, tableName string, columnName string, colu
 		}
 		return nil, err
 	}
+	if managed.Valid {
+		result.Managed = managed.String
+	}
 
 	return &result, nil
 }
@@ -565,16 +1144,10 @@ func (r *AkBlueprintReconciler) configForBlueprint(crd *akmv1a1.AkBlueprint, nam
 	// create the map of key values for the data in configmap from blueprint contents
 	cleanFP := filepath.Clean(crd.Spec.File)
 	var dataMap = make(map[string]string)
-	// apply regex substitution to remove quotes ['"](?P<content>\!.*)['"] -> ${content}
-	// this is required since authentiks python yaml parser doesn't like quotes on
-	// their custom yaml tags so we have to ensure they are stripped here for consistency
-	regexPatterns := map[string]string{
-		`['"](?P<content>\!.*)['"]`: "${content}", // This strips the quotes from special yaml tags
-		//`['"](?P<content>null)['"]`: "${content}", // This strips the quotes from "null"
-		//`['"](?P<content>true)['"]`:  "${content}", // This strips the quotes from "true"
-		//`['"](?P<content>false)['"]`: "${content}", // This strips the quotes from "false"
-	}
-	cleanedBlueprint := regexSubstituteMap(regexPatterns, string(crd.Spec.Blueprint))
+	cleanedBlueprint, err := blueprintContent(crd)
+	if err != nil {
+		return nil, err
+	}
 	// set the configmap key to be the file name we want it to be mounted as for the volume mounts
 	dataMap[filepath.Base(cleanFP)] = cleanedBlueprint
 
@@ -584,7 +1157,7 @@ func (r *AkBlueprintReconciler) configForBlueprint(crd *akmv1a1.AkBlueprint, nam
 	// create label to specifically identify blueprint related configmaps
 	var labelMap = make(map[string]string)
 	labelMap["akm.goauthentik.io/type"] = "blueprint"
-	labelMap["akm.goauthentik.io/blueprint"] = crd.Name
+	labelMap[akmv1a1.BlueprintLabel] = crd.Name
 
 	cm := corev1.ConfigMap{
 		// Metadata
@@ -601,20 +1174,19 @@ func (r *AkBlueprintReconciler) configForBlueprint(crd *akmv1a1.AkBlueprint, nam
 	return &cm, nil
 }
 
+// blueprintContent renders the blueprint of an AkBlueprint into the yaml authentik reads, preferring the
+// structured BlueprintSpec over the Blueprint string. Tags in a BlueprintSpec are strings once they have been
+// through the api server, so they are rendered back into tags, while the Blueprint string is used as written.
+func blueprintContent(crd *akmv1a1.AkBlueprint) (string, error) {
+	if crd.Spec.BlueprintSpec != nil {
+		return blueprint.MarshalRetagged(crd.Spec.BlueprintSpec)
+	}
+	return crd.Spec.Blueprint, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *AkBlueprintReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&akmv1a1.AkBlueprint{}).
+		For(&akmv1a1.AkBlueprint{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
-
-// regexSubstituteMap takes in a map[string]string of regex patterns as keys and regex replacements as values
-// this is then applied to a given string by iterating over the keys to find matches and replacing the values
-func regexSubstituteMap(patterns map[string]string, data string) string {
-	result := data
-	for pattern, replacement := range patterns {
-		re := regexp.MustCompile(pattern)
-		result = re.ReplaceAllString(result, replacement)
-	}
-	return result
-}
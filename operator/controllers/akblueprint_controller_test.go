@@ -0,0 +1,363 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	akfake "gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik/fake"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/oci"
+	ocifake "gitlab.com/GeorgeRaven/authentik-manager/operator/utils/oci/fake"
+)
+
+func TestBlueprintInstancePath(t *testing.T) {
+	tests := map[string]string{
+		"/blueprints/operator/app.yaml":                                "operator/app.yaml",
+		"/blueprints/default/../operator/app.yaml":                     "operator/app.yaml",
+		"/blueprints/default/10-flow-default-authentication-flow.yaml": "default/10-flow-default-authentication-flow.yaml",
+		"/elsewhere/app.yaml":                                          "/elsewhere/app.yaml",
+	}
+	for in, want := range tests {
+		if got := blueprintInstancePath(in); got != want {
+			t.Errorf("blueprintInstancePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSetStatusFromBlueprintInstance(t *testing.T) {
+	applied := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		row    *AuthentikBlueprintInstance
+		status string
+		ready  metav1.ConditionStatus
+	}{
+		{"unregistered", nil, "", metav1.ConditionUnknown},
+		{"successful", &AuthentikBlueprintInstance{Status: "successful", LastApplied: applied}, "successful", metav1.ConditionTrue},
+		{"warning", &AuthentikBlueprintInstance{Status: "warning", LastApplied: applied}, "warning", metav1.ConditionTrue},
+		{"error", &AuthentikBlueprintInstance{Status: "error", LastApplied: applied}, "error", metav1.ConditionFalse},
+		{"orphaned", &AuthentikBlueprintInstance{Status: "orphaned"}, "orphaned", metav1.ConditionFalse},
+		{"unknown", &AuthentikBlueprintInstance{Status: "unknown"}, "unknown", metav1.ConditionUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &akmv1a1.AkBlueprintStatus{}
+			setStatusFromBlueprintInstance(status, tt.row, 3)
+			if status.Status != tt.status {
+				t.Errorf("status = %q, want %q", status.Status, tt.status)
+			}
+			if status.ObservedGeneration != 3 {
+				t.Errorf("observedGeneration = %v, want 3", status.ObservedGeneration)
+			}
+			ready := meta.FindStatusCondition(status.Conditions, akmv1a1.ConditionReady)
+			if ready == nil || ready.Status != tt.ready {
+				t.Errorf("ready condition = %v, want %v", ready, tt.ready)
+			}
+			if tt.row != nil && !tt.row.LastApplied.IsZero() && !status.LastApplied.Time.Equal(applied) {
+				t.Errorf("lastApplied = %v, want %v", status.LastApplied, applied)
+			}
+		})
+	}
+}
+
+func TestAbsentBlueprint(t *testing.T) {
+	blueprint := `version: 1
+metadata:
+  name: sample
+entries:
+- model: authentik_providers_oauth2.oauth2provider
+  id: provider
+  identifiers:
+    name: sample
+- model: authentik_core.application
+  state: present
+  identifiers:
+    slug: sample
+  attrs:
+    provider: !KeyOf provider
+`
+	got, err := absentBlueprint(blueprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `version: 1
+metadata:
+    name: sample
+entries:
+    - model: authentik_core.application
+      state: absent
+      identifiers:
+        slug: sample
+      attrs:
+        provider: !KeyOf provider
+    - model: authentik_providers_oauth2.oauth2provider
+      id: provider
+      identifiers:
+        name: sample
+      state: absent
+`
+	if got != want {
+		t.Errorf("absentBlueprint() =\n%v\nwant\n%v", got, want)
+	}
+
+	if _, err := absentBlueprint("- not a mapping"); err == nil {
+		t.Errorf("expected error for non mapping blueprint")
+	}
+}
+
+func TestSetStatusFromImportResult(t *testing.T) {
+	now := metav1.NewTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	tests := []struct {
+		name   string
+		result *authentik.BlueprintImportResult
+		status string
+		ready  metav1.ConditionStatus
+		hash   string
+	}{
+		{"successful", &authentik.BlueprintImportResult{Success: true, Logs: []authentik.BlueprintLog{{LogLevel: "info", Event: "applied"}}}, "successful", metav1.ConditionTrue, "abc"},
+		{"warning", &authentik.BlueprintImportResult{Success: true, Logs: []authentik.BlueprintLog{{LogLevel: "warning", Event: "odd"}}}, "warning", metav1.ConditionTrue, "abc"},
+		{"failed", &authentik.BlueprintImportResult{Success: false, Logs: []authentik.BlueprintLog{{LogLevel: "warning", Event: "invalid", Attributes: map[string]interface{}{"model": "x"}}}}, "error", metav1.ConditionFalse, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &akmv1a1.AkBlueprintStatus{}
+			setStatusFromImportResult(status, tt.result, "abc", now, 2)
+			if status.Status != tt.status {
+				t.Errorf("status = %q, want %q", status.Status, tt.status)
+			}
+			if status.LastAppliedHash != tt.hash {
+				t.Errorf("lastAppliedHash = %q, want %q", status.LastAppliedHash, tt.hash)
+			}
+			if len(status.Logs) != len(tt.result.Logs) {
+				t.Errorf("logs = %v, want %d entries", status.Logs, len(tt.result.Logs))
+			}
+			ready := meta.FindStatusCondition(status.Conditions, akmv1a1.ConditionReady)
+			if ready == nil || ready.Status != tt.ready {
+				t.Errorf("ready condition = %v, want %v", ready, tt.ready)
+			}
+		})
+	}
+}
+
+func TestReconcileAPIBlueprint(t *testing.T) {
+	ctx := context.TODO()
+	srv := akfake.NewServer("token")
+	defer srv.Close()
+	akc, err := authentik.NewClient(srv.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	crd := &akmv1a1.AkBlueprint{
+		ObjectMeta: metav1.ObjectMeta{Name: "bp", Namespace: "auth", Generation: 1},
+		Spec:       akmv1a1.AkBlueprintSpec{StorageType: "api", Blueprint: "version: 1\nentries: []\n"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(crd).WithStatusSubresource(crd).Build()
+	r := &AkBlueprintReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+	o := utils.Opts{BlueprintPollInterval: time.Minute}
+
+	result, err := r.reconcileAPIBlueprint(ctx, akc, crd, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("successful import requeued after %v", result.RequeueAfter)
+	}
+	got := &akmv1a1.AkBlueprint{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "bp", Namespace: "auth"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.Status != "successful" || got.Status.LastAppliedHash != blueprintHash(crd.Spec.Blueprint) {
+		t.Errorf("status = %+v, want successful with content hash", got.Status)
+	}
+
+	// unchanged content is not imported again
+	if _, err := r.reconcileAPIBlueprint(ctx, akc, got, o); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Imports()); n != 1 {
+		t.Errorf("imported %d times, want 1", n)
+	}
+
+	// changed content that fails to import is retried later
+	srv.HandleFunc("flows/instances/import", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"logs": [{"log_level": "warning", "event": "entry invalid"}], "success": false}`))
+	})
+	got.Spec.Blueprint = "version: 1\nentries: [{model: nope}]\n"
+	result, err = r.reconcileAPIBlueprint(ctx, akc, got, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != time.Minute {
+		t.Errorf("failed import requeued after %v, want %v", result.RequeueAfter, time.Minute)
+	}
+	if got.Status.Status != "error" || len(got.Status.Logs) != 1 {
+		t.Errorf("status = %+v, want error with logs", got.Status)
+	}
+}
+
+func TestReconcileOCIBlueprint(t *testing.T) {
+	ctx := context.TODO()
+	srv := akfake.NewServer("token")
+	defer srv.Close()
+	akc, err := authentik.NewClient(srv.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := ocifake.NewRegistry()
+	defer reg.Close()
+	reg.Username, reg.Password = "bot", "secret"
+	reg.Push("org/blueprints", "v1", "version: 1\nentries:\n- model: authentik_core.group\n  identifiers:\n    name: team\n")
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "auth"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths": {"` + reg.Host() + `": {"username": "bot", "password": "secret"}}}`),
+		},
+	}
+	crd := &akmv1a1.AkBlueprint{
+		ObjectMeta: metav1.ObjectMeta{Name: "bp", Namespace: "auth", Generation: 1, Finalizers: []string{finalizerName}},
+		Spec: akmv1a1.AkBlueprintSpec{
+			StorageType: "oci",
+			OCIRef:      reg.Host() + "/org/blueprints:v1",
+			PullSecret:  "registry",
+			Teardown:    true,
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(crd, pullSecret).WithStatusSubresource(crd).Build()
+	r := &AkBlueprintReconciler{
+		ControlBase: utils.ControlBase{Client: c, Scheme: scheme},
+		OCIClient:   &oci.Client{HTTPClient: reg.Client()},
+	}
+	o := utils.Opts{BlueprintPollInterval: time.Minute, BlueprintTeardownTimeout: time.Minute}
+
+	// credentials only reach authentik once embedding them is allowed
+	if _, err := r.reconcileOCIBlueprint(ctx, akc, crd, o); err != nil {
+		t.Fatal(err)
+	}
+	if ready := meta.FindStatusCondition(crd.Status.Conditions, akmv1a1.ConditionReady); ready == nil || ready.Reason != "PullFailed" {
+		t.Errorf("ready condition = %v, want PullFailed without embedPullCredentials", ready)
+	}
+	if instances := srv.Objects("managed/blueprints"); len(instances) != 0 {
+		t.Fatalf("authentik blueprints = %v, want none registered without embedPullCredentials", instances)
+	}
+	crd.Spec.EmbedPullCredentials = true
+
+	if _, err := r.reconcileOCIBlueprint(ctx, akc, crd, o); err != nil {
+		t.Fatal(err)
+	}
+	instances := srv.Objects("managed/blueprints")
+	if len(instances) != 1 || instances[0]["path"] != "oci://bot:secret@"+reg.Host()+"/org/blueprints:v1" {
+		t.Fatalf("authentik blueprints = %v, want one registered at the oci path", instances)
+	}
+
+	// an unknown tag is reported rather than registered
+	crd.Spec.OCIRef = reg.Host() + "/org/blueprints:v2"
+	if _, err := r.reconcileOCIBlueprint(ctx, akc, crd, o); err != nil {
+		t.Fatal(err)
+	}
+	ready := meta.FindStatusCondition(crd.Status.Conditions, akmv1a1.ConditionReady)
+	if ready == nil || ready.Reason != "PullFailed" {
+		t.Errorf("ready condition = %v, want PullFailed", ready)
+	}
+
+	// deletion tears down then unregisters
+	if err := c.Delete(ctx, crd); err != nil {
+		t.Fatal(err)
+	}
+	deleted := &akmv1a1.AkBlueprint{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "bp", Namespace: "auth"}, deleted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.finalizeOCIBlueprint(ctx, akc, deleted, o); err != nil {
+		t.Fatal(err)
+	}
+	if imports := srv.Imports(); len(imports) != 1 {
+		t.Errorf("imported %v, want a single teardown", imports)
+	}
+	if instances := srv.Objects("managed/blueprints"); len(instances) != 0 {
+		t.Errorf("authentik blueprints = %v, want none after deletion", instances)
+	}
+}
+
+func TestBlueprintContent(t *testing.T) {
+	// as the api server would hand the resource to us
+	data := []byte(`{"spec": {"blueprint": "ignored", "blueprintSpec": {
+		"version": 1,
+		"metadata": {"name": "sample"},
+		"entries": [{
+			"model": "authentik_core.application",
+			"identifiers": {"slug": "sample"},
+			"attrs": {"provider": "!KeyOf provider"}
+		}]
+	}}}`)
+	crd := &akmv1a1.AkBlueprint{}
+	if err := json.Unmarshal(data, crd); err != nil {
+		t.Fatal(err)
+	}
+	got, err := blueprintContent(crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `version: 1
+metadata:
+    name: sample
+entries:
+    - model: authentik_core.application
+      identifiers:
+        slug: sample
+      attrs:
+        provider: !KeyOf provider
+`
+	if got != want {
+		t.Errorf("blueprintContent() =\n%v\nwant\n%v", got, want)
+	}
+
+	// the blueprint string is what authentik reads, quoted strings stay strings
+	crd.Spec.BlueprintSpec = nil
+	crd.Spec.Blueprint = "attrs:\n  provider: !KeyOf provider\n  description: '!Format is a tag'\n"
+	if got, _ := blueprintContent(crd); got != crd.Spec.Blueprint {
+		t.Errorf("blueprintContent() = %q, want %q", got, crd.Spec.Blueprint)
+	}
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/alexflint/go-arg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the AkBrand resource that generated them.
+const (
+	akBrandNameLabel      = "akm.goauthentik.io/akbrand"
+	akBrandNamespaceLabel = "akm.goauthentik.io/akbrand-namespace"
+)
+
+// AkBrandReconciler reconciles a AkBrand object
+type AkBrandReconciler struct {
+	utils.ControlBase
+}
+
+// akBrandAssets are the logo, favicon, and CSS of a brand resolved from their URLs, ConfigMaps, or inline sources
+type akBrandAssets struct {
+	Logo    string
+	Favicon string
+	CSS     string
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akbrands,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akbrands/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akbrands/finalizers,verbs=update
+
+// Reconcile turns an AkBrand resource into an AkBlueprint of the brand in the authentik namespace, inlining any
+// logo, favicon, or CSS read from ConfigMaps.
+func (r *AkBrandReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.AkBrand{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("AkBrand resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get AkBrand resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found AkBrand resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("AkBrand resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, akBrandLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+
+	// ASSETS
+	assets, err := r.resolveAssets(ctx, crd)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "AssetsFailed", err)
+	}
+
+	// BLUEPRINT
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-brand-%v", crd.Namespace, crd.Name), akBrandLabels(crd), akBrandBlueprint(crd, assets))
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+	crd.Status.AkBlueprint = fmt.Sprintf("%v/%v", bp.Namespace, bp.Name)
+	pending := []string{}
+	if !meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady) {
+		pending = append(pending, crd.Status.AkBlueprint)
+	}
+
+	// STATUS
+	meta.SetStatusCondition(&crd.Status.Conditions, utils.BlueprintsReadyCondition(pending, crd.Generation))
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setFailedStatus marks the AkBrand resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *AkBrandReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.AkBrand, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of AkBrand `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// akBrandLabels are the labels that mark a generated resource as belonging to the given AkBrand
+func akBrandLabels(crd *akmv1a1.AkBrand) map[string]string {
+	return map[string]string{
+		akBrandNameLabel:      crd.Name,
+		akBrandNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToAkBrand maps a generated AkBlueprint back to the AkBrand resource that generated it
+func (r *AkBrandReconciler) akBlueprintToAkBrand(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, akBrandNameLabel, akBrandNamespaceLabel)
+}
+
+// akBrandConfigMaps are the names of the ConfigMaps the brand reads its assets from
+func akBrandConfigMaps(crd *akmv1a1.AkBrand) []string {
+	names := []string{}
+	if crd.Spec.Logo != nil && crd.Spec.Logo.ConfigMap != nil {
+		names = append(names, crd.Spec.Logo.ConfigMap.Name)
+	}
+	if crd.Spec.Favicon != nil && crd.Spec.Favicon.ConfigMap != nil {
+		names = append(names, crd.Spec.Favicon.ConfigMap.Name)
+	}
+	if crd.Spec.CSS != nil && crd.Spec.CSS.ConfigMap != nil {
+		names = append(names, crd.Spec.CSS.ConfigMap.Name)
+	}
+	return names
+}
+
+// configMapToAkBrands maps a ConfigMap to the AkBrands in its namespace that read assets from it
+func (r *AkBrandReconciler) configMapToAkBrands(ctx context.Context, obj client.Object) []reconcile.Request {
+	l := klog.FromContext(ctx)
+	brands := &akmv1a1.AkBrandList{}
+	err := r.List(ctx, brands, client.InNamespace(obj.GetNamespace()))
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to list AkBrands reading ConfigMap `%v` in `%v`.", obj.GetName(), obj.GetNamespace()))
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, brand := range brands.Items {
+		for _, name := range akBrandConfigMaps(&brand) {
+			if name == obj.GetName() {
+				reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: brand.Name, Namespace: brand.Namespace}})
+				break
+			}
+		}
+	}
+	return reqs
+}
+
+// resolveAssets gets the logo, favicon, and CSS of the brand, preferring ConfigMaps over URLs and inline sources
+func (r *AkBrandReconciler) resolveAssets(ctx context.Context, crd *akmv1a1.AkBrand) (akBrandAssets, error) {
+	assets := akBrandAssets{}
+	var err error
+	assets.Logo, err = r.resolveImage(ctx, crd.Namespace, crd.Spec.Logo)
+	if err != nil {
+		return assets, err
+	}
+	assets.Favicon, err = r.resolveImage(ctx, crd.Namespace, crd.Spec.Favicon)
+	if err != nil {
+		return assets, err
+	}
+	if css := crd.Spec.CSS; css != nil {
+		assets.CSS = css.Source
+		if css.ConfigMap != nil {
+			source, err := r.GetConfigMapKey(ctx, crd.Namespace, css.ConfigMap)
+			if err != nil {
+				return assets, err
+			}
+			assets.CSS = string(source)
+		}
+	}
+	return assets, nil
+}
+
+// resolveImage gets the URL of an image, inlining images from ConfigMaps as data URIs since authentik only
+// takes a URL or path for them
+func (r *AkBrandReconciler) resolveImage(ctx context.Context, namespace string, image *akmv1a1.AkBrandImage) (string, error) {
+	if image == nil {
+		return "", nil
+	}
+	if image.ConfigMap == nil {
+		return image.URL, nil
+	}
+	data, err := r.GetConfigMapKey(ctx, namespace, image.ConfigMap)
+	if err != nil {
+		return "", err
+	}
+	return dataURI(image.ConfigMap.Key, data), nil
+}
+
+// dataURI encodes the data as a data URI, its type is taken from the extension of the name where known
+// since sniffing cannot tell SVGs from other XML
+func dataURI(name string, data []byte) string {
+	mediaType := mime.TypeByExtension(filepath.Ext(name))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	return fmt.Sprintf("data:%v;base64,%v", mediaType, base64.StdEncoding.EncodeToString(data))
+}
+
+// akBrandBlueprint is the blueprint content of a brand, flows and other optional settings are only set when given
+// so the authentik defaults apply otherwise.
+func akBrandBlueprint(crd *akmv1a1.AkBrand, assets akBrandAssets) *blueprint.Blueprint {
+	attrs := map[string]interface{}{
+		"domain":  crd.Spec.Domain,
+		"default": crd.Spec.Default,
+	}
+	optional := map[string]string{
+		"branding_title":      crd.Spec.Title,
+		"branding_logo":       assets.Logo,
+		"branding_favicon":    assets.Favicon,
+		"branding_custom_css": assets.CSS,
+	}
+	for key, value := range optional {
+		if value != "" {
+			attrs[key] = value
+		}
+	}
+	flows := map[string]string{
+		"flow_authentication": crd.Spec.Flows.Authentication,
+		"flow_invalidation":   crd.Spec.Flows.Invalidation,
+		"flow_recovery":       crd.Spec.Flows.Recovery,
+		"flow_unenrollment":   crd.Spec.Flows.Unenrollment,
+		"flow_user_settings":  crd.Spec.Flows.UserSettings,
+		"flow_device_code":    crd.Spec.Flows.DeviceCode,
+	}
+	for key, slug := range flows {
+		if slug != "" {
+			attrs[key] = blueprint.Find("authentik_flows.flow", "slug", slug)
+		}
+	}
+	if crd.Spec.DefaultApplication != "" {
+		attrs["default_application"] = blueprint.Find("authentik_core.application", "slug", crd.Spec.DefaultApplication)
+	}
+	if crd.Spec.WebCertificate != "" {
+		attrs["web_certificate"] = blueprint.Find("authentik_crypto.certificatekeypair", "name", crd.Spec.WebCertificate)
+	}
+	if crd.Spec.Attributes != nil {
+		attrs["attributes"] = crd.Spec.Attributes
+	}
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       "authentik_brands.brand",
+				State:       "present",
+				Identifiers: map[string]interface{}{"domain": crd.Spec.Domain},
+				Attrs:       attrs,
+			},
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AkBrandReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.AkBrand{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToAkBrand)).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.configMapToAkBrands)).
+		Complete(r)
+}
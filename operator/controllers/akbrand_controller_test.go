@@ -0,0 +1,132 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+)
+
+func TestAkBrandBlueprint(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "branding", Namespace: "apps"},
+		Data: map[string]string{
+			"logo.svg":  "<svg xmlns=\"http://www.w3.org/2000/svg\"/>",
+			"brand.css": ".pf-c-login { background: #123456; }",
+		},
+	}
+	crd := &akmv1a1.AkBrand{
+		ObjectMeta: metav1.ObjectMeta{Name: "customer", Namespace: "apps"},
+		Spec: akmv1a1.AkBrandSpec{
+			Instance: akmv1a1.AuthentikInstance{Namespace: "auth"},
+			Domain:   "customer.example.org",
+			Title:    "Customer",
+			Logo: &akmv1a1.AkBrandImage{
+				ConfigMap: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "branding"}, Key: "logo.svg"},
+			},
+			Favicon: &akmv1a1.AkBrandImage{URL: "/static/dist/assets/icons/icon.png"},
+			CSS: &akmv1a1.AkBrandCSS{
+				ConfigMap: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "branding"}, Key: "brand.css"},
+			},
+			Flows:              akmv1a1.AkBrandFlows{Authentication: "my-login"},
+			DefaultApplication: "portal",
+			WebCertificate:     "my-signing-key",
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm, crd).Build()
+	r := &AkBrandReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+
+	assets, err := r.resolveAssets(ctx, crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(assets.Logo, "data:image/svg+xml;base64,") {
+		t.Errorf("expected an inlined SVG logo, got %q", assets.Logo)
+	}
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "apps-brand-customer", akBrandLabels(crd), akBrandBlueprint(crd, assets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"model: authentik_brands.brand",
+		"domain: customer.example.org",
+		"branding_title: Customer",
+		"branding_logo: data:image/svg+xml;base64,",
+		"branding_favicon: /static/dist/assets/icons/icon.png",
+		"branding_custom_css: '.pf-c-login { background: #123456; }'",
+		"flow_authentication: !Find [authentik_flows.flow, [slug, my-login]]",
+		"default_application: !Find [authentik_core.application, [slug, portal]]",
+		"web_certificate: !Find [authentik_crypto.certificatekeypair, [name, my-signing-key]]",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	// flows not given are left to the authentik defaults
+	if strings.Contains(content, "flow_recovery") {
+		t.Errorf("expected no recovery flow:\n%v", content)
+	}
+	// the generated blueprint must also pass admission
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated blueprint is invalid: %v", err)
+	}
+	reqs := r.akBlueprintToAkBrand(ctx, bp)
+	if len(reqs) != 1 || reqs[0].Name != "customer" || reqs[0].Namespace != "apps" {
+		t.Fatalf("expected request for apps/customer, got %v", reqs)
+	}
+
+	// changes to the ConfigMap reconcile the brand again
+	reqs = r.configMapToAkBrands(ctx, cm)
+	if len(reqs) != 1 || reqs[0].Name != "customer" {
+		t.Fatalf("expected request for apps/customer, got %v", reqs)
+	}
+}
+
+func TestDataURI(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	tests := map[string]struct {
+		name string
+		data []byte
+		want string
+	}{
+		"by extension": {name: "logo.svg", data: []byte("<svg/>"), want: "data:image/svg+xml;base64,PHN2Zy8+"},
+		"by sniffing":  {name: "logo", data: png, want: "data:image/png;base64,iVBORw0KGgo="},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := dataURI(tt.name, tt.data); got != tt.want {
+				t.Errorf("dataURI() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
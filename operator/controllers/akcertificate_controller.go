@@ -0,0 +1,242 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+
+	"github.com/alexflint/go-arg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the AkCertificate resource that generated them.
+const (
+	akCertificateNameLabel      = "akm.goauthentik.io/akcertificate"
+	akCertificateNamespaceLabel = "akm.goauthentik.io/akcertificate-namespace"
+)
+
+// AkCertificateReconciler reconciles a AkCertificate object
+type AkCertificateReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akcertificates,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akcertificates/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akcertificates/finalizers,verbs=update
+
+// Reconcile uploads the certificate keypair in the TLS secret of an AkCertificate resource through the authentik API,
+// and again whenever the secret is renewed. Blueprints are not used since they would hold the private key in plaintext.
+func (r *AkCertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.AkCertificate{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("AkCertificate resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get AkCertificate resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found AkCertificate resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("AkCertificate resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, akCertificateLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+
+	// SECRET
+	secret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: crd.Spec.Secret.Name, Namespace: crd.Namespace}, secret)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "SecretFailed", err)
+	}
+	cert, err := parseTLSSecret(secret)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "InvalidSecret", err)
+	}
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	// KEYPAIR
+	akc, err := r.NewAuthentikClient(ctx, ak)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "KeypairFailed", err)
+	}
+	err = syncCertificateKeyPair(ctx, akc, keypairFromSecret(crd, secret), fingerprint != crd.Status.Fingerprint)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "KeypairFailed", err)
+	}
+	crd.Status.Fingerprint = fingerprint
+	crd.Status.NotAfter = &metav1.Time{Time: cert.NotAfter}
+
+	// BLUEPRINT - blueprints generated by earlier versions hold the private key so are removed
+	err = r.DeleteGeneratedBlueprints(ctx, ak.Namespace, akCertificateLabels(crd))
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+
+	// STATUS
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "KeypairSynced",
+		Message:            fmt.Sprintf("Certificate keypair `%v` has been uploaded to authentik.", crd.KeypairName()),
+		ObservedGeneration: crd.Generation,
+	})
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setFailedStatus marks the AkCertificate resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *AkCertificateReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.AkCertificate, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of AkCertificate `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// akCertificateLabels are the labels that mark a generated resource as belonging to the given AkCertificate
+func akCertificateLabels(crd *akmv1a1.AkCertificate) map[string]string {
+	return map[string]string{
+		akCertificateNameLabel:      crd.Name,
+		akCertificateNamespaceLabel: crd.Namespace,
+	}
+}
+
+// secretToAkCertificates maps a secret to the AkCertificates in its namespace that sync it, so renewals are imported
+func (r *AkCertificateReconciler) secretToAkCertificates(ctx context.Context, obj client.Object) []reconcile.Request {
+	l := klog.FromContext(ctx)
+	certificates := &akmv1a1.AkCertificateList{}
+	err := r.List(ctx, certificates, client.InNamespace(obj.GetNamespace()))
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to list AkCertificates syncing secret `%v` in `%v`.", obj.GetName(), obj.GetNamespace()))
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, certificate := range certificates.Items {
+		if certificate.Spec.Secret.Name == obj.GetName() {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}})
+		}
+	}
+	return reqs
+}
+
+// parseTLSSecret checks the secret holds a PEM certificate and key, returning the leaf certificate
+func parseTLSSecret(secret *corev1.Secret) (*x509.Certificate, error) {
+	if len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 {
+		return nil, fmt.Errorf("secret `%v` has no `%v` key", secret.Name, corev1.TLSPrivateKeyKey)
+	}
+	block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("secret `%v` has no PEM certificate under `%v`", secret.Name, corev1.TLSCertKey)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// keypairFromSecret is the certificate keypair from a TLS secret, the whole chain is given to authentik so it can
+// serve the intermediates
+func keypairFromSecret(crd *akmv1a1.AkCertificate, secret *corev1.Secret) *authentik.CertificateKeyPair {
+	return &authentik.CertificateKeyPair{
+		Name:            crd.KeypairName(),
+		CertificateData: string(secret.Data[corev1.TLSCertKey]),
+		KeyData:         string(secret.Data[corev1.TLSPrivateKeyKey]),
+	}
+}
+
+// syncCertificateKeyPair uploads the keypair if authentik does not have one by its name yet, or again if it renewed
+func syncCertificateKeyPair(ctx context.Context, akc *authentik.Client, keypair *authentik.CertificateKeyPair, renewed bool) error {
+	existing, err := akc.ListCertificateKeyPairs(ctx, url.Values{"name": {keypair.Name}})
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		_, err = akc.CreateCertificateKeyPair(ctx, keypair)
+		return err
+	} else if !renewed {
+		return nil
+	}
+	keypair.PK = existing[0].PK
+	_, err = akc.PatchCertificateKeyPair(ctx, keypair)
+	return err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AkCertificateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.AkCertificate{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.secretToAkCertificates)).
+		Complete(r)
+}
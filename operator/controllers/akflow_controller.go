@@ -0,0 +1,307 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the AkFlow resource that generated them.
+const (
+	akFlowNameLabel      = "akm.goauthentik.io/akflow"
+	akFlowNamespaceLabel = "akm.goauthentik.io/akflow-namespace"
+)
+
+// stageModels are the authentik models of each AkStage type
+var stageModels = map[string]string{
+	"identification":         "authentik_stages_identification.identificationstage",
+	"password":               "authentik_stages_password.passwordstage",
+	"authenticator_validate": "authentik_stages_authenticator_validate.authenticatorvalidatestage",
+	"user_login":             "authentik_stages_user_login.userloginstage",
+	"consent":                "authentik_stages_consent.consentstage",
+}
+
+// AkFlowReconciler reconciles a AkFlow object
+type AkFlowReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akflows,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akflows/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akflows/finalizers,verbs=update
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akstages,verbs=get;list;watch
+
+// Reconcile turns an AkFlow resource, and the AkStages it binds, into a single AkBlueprint in the authentik namespace.
+func (r *AkFlowReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.AkFlow{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("AkFlow resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get AkFlow resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found AkFlow resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("AkFlow resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, akFlowLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+
+	// STAGES
+	stages, err := r.getBoundStages(ctx, crd)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "StageNotFound", err)
+	}
+
+	// BLUEPRINT
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-flow-%v", crd.Namespace, crd.Name), akFlowLabels(crd), akFlowBlueprint(crd, stages))
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+	crd.Status.AkBlueprint = fmt.Sprintf("%v/%v", bp.Namespace, bp.Name)
+	pending := []string{}
+	if !meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady) {
+		pending = append(pending, crd.Status.AkBlueprint)
+	}
+
+	// STATUS
+	meta.SetStatusCondition(&crd.Status.Conditions, utils.BlueprintsReadyCondition(pending, crd.Generation))
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setFailedStatus marks the AkFlow resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *AkFlowReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.AkFlow, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of AkFlow `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// akFlowLabels are the labels that mark a generated resource as belonging to the given AkFlow
+func akFlowLabels(crd *akmv1a1.AkFlow) map[string]string {
+	return map[string]string{
+		akFlowNameLabel:      crd.Name,
+		akFlowNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToAkFlow maps a generated AkBlueprint back to the AkFlow resource that generated it
+func (r *AkFlowReconciler) akBlueprintToAkFlow(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, akFlowNameLabel, akFlowNamespaceLabel)
+}
+
+// akStageToAkFlows maps an AkStage to the AkFlows in its namespace that bind it
+func (r *AkFlowReconciler) akStageToAkFlows(ctx context.Context, obj client.Object) []reconcile.Request {
+	l := klog.FromContext(ctx)
+	flows := &akmv1a1.AkFlowList{}
+	err := r.List(ctx, flows, client.InNamespace(obj.GetNamespace()))
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to list AkFlows binding AkStage `%v` in `%v`.", obj.GetName(), obj.GetNamespace()))
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, flow := range flows.Items {
+		for _, binding := range flow.Spec.Stages {
+			if binding.Name == obj.GetName() {
+				reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: flow.Name, Namespace: flow.Namespace}})
+				break
+			}
+		}
+	}
+	return reqs
+}
+
+// getBoundStages gets the AkStages bound to the flow by resource name, failing if any do not exist
+func (r *AkFlowReconciler) getBoundStages(ctx context.Context, crd *akmv1a1.AkFlow) (map[string]*akmv1a1.AkStage, error) {
+	stages := map[string]*akmv1a1.AkStage{}
+	for _, binding := range crd.Spec.Stages {
+		if _, ok := stages[binding.Name]; ok {
+			continue
+		}
+		stage := &akmv1a1.AkStage{}
+		err := r.Get(ctx, types.NamespacedName{Name: binding.Name, Namespace: crd.Namespace}, stage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get AkStage `%v` in `%v`: %w", binding.Name, crd.Namespace, err)
+		}
+		stages[binding.Name] = stage
+	}
+	return stages, nil
+}
+
+// akStageEntry is the blueprint entry of a stage, only the settings of its type are used and any not given
+// are left to the authentik defaults.
+func akStageEntry(stage *akmv1a1.AkStage) blueprint.Entry {
+	attrs := map[string]interface{}{
+		"name": stage.StageName(),
+	}
+	switch stage.Spec.Type {
+	case "identification":
+		if s := stage.Spec.Identification; s != nil {
+			attrs["user_fields"] = s.UserFields
+			attrs["case_insensitive_matching"] = s.CaseInsensitiveMatching
+			attrs["show_matched_user"] = s.ShowMatchedUser
+			if s.PasswordStage != "" {
+				attrs["password_stage"] = blueprint.Find("authentik_stages_password.passwordstage", "name", s.PasswordStage)
+			}
+		}
+	case "password":
+		if s := stage.Spec.Password; s != nil {
+			attrs["backends"] = s.Backends
+			attrs["failed_attempts_before_cancel"] = s.FailedAttemptsBeforeCancel
+		}
+	case "authenticator_validate":
+		if s := stage.Spec.AuthenticatorValidate; s != nil {
+			attrs["device_classes"] = s.DeviceClasses
+			attrs["not_configured_action"] = s.NotConfiguredAction
+		}
+	case "user_login":
+		if s := stage.Spec.UserLogin; s != nil {
+			attrs["session_duration"] = s.SessionDuration
+			attrs["remember_me_offset"] = s.RememberMeOffset
+		}
+	case "consent":
+		if s := stage.Spec.Consent; s != nil {
+			attrs["mode"] = s.Mode
+			attrs["consent_expire_in"] = s.ConsentExpireIn
+		}
+	}
+	return blueprint.Entry{
+		Model:       stageModels[stage.Spec.Type],
+		State:       "present",
+		ID:          fmt.Sprintf("stage-%v", stage.Name),
+		Identifiers: map[string]interface{}{"name": stage.StageName()},
+		Attrs:       attrs,
+	}
+}
+
+// akFlowBlueprint is the blueprint content of a flow, the stages it binds, and the bindings between them
+// ordered as listed in the flow.
+func akFlowBlueprint(crd *akmv1a1.AkFlow, stages map[string]*akmv1a1.AkStage) *blueprint.Blueprint {
+	name := crd.Spec.Name
+	if name == "" {
+		name = crd.FlowSlug()
+	}
+	entries := []blueprint.Entry{}
+	rendered := map[string]bool{}
+	for _, binding := range crd.Spec.Stages {
+		if rendered[binding.Name] {
+			continue
+		}
+		entries = append(entries, akStageEntry(stages[binding.Name]))
+		rendered[binding.Name] = true
+	}
+	entries = append(entries, blueprint.Entry{
+		Model:       "authentik_flows.flow",
+		State:       "present",
+		ID:          "flow",
+		Identifiers: map[string]interface{}{"slug": crd.FlowSlug()},
+		Attrs: map[string]interface{}{
+			"slug":               crd.FlowSlug(),
+			"name":               name,
+			"title":              crd.Spec.Title,
+			"designation":        crd.Spec.Designation,
+			"policy_engine_mode": crd.Spec.PolicyEngineMode,
+		},
+	})
+	for i, binding := range crd.Spec.Stages {
+		// spaced out like authentik does so bindings can be slotted in between by hand
+		order := (i + 1) * 10
+		entries = append(entries, blueprint.Entry{
+			Model: "authentik_flows.flowstagebinding",
+			State: "present",
+			Identifiers: map[string]interface{}{
+				"target": blueprint.KeyOf("flow"),
+				"stage":  blueprint.KeyOf(fmt.Sprintf("stage-%v", binding.Name)),
+				"order":  order,
+			},
+			Attrs: map[string]interface{}{
+				"evaluate_on_plan":     binding.EvaluateOnPlan,
+				"re_evaluate_policies": binding.ReEvaluatePolicies,
+			},
+		})
+	}
+	return &blueprint.Blueprint{Version: 1, Entries: entries}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AkFlowReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.AkFlow{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToAkFlow)).
+		Watches(&akmv1a1.AkStage{}, handler.EnqueueRequestsFromMapFunc(r.akStageToAkFlows), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+)
+
+func TestAkFlowBlueprint(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	identification := &akmv1a1.AkStage{
+		ObjectMeta: metav1.ObjectMeta{Name: "identify", Namespace: "apps"},
+		Spec: akmv1a1.AkStageSpec{
+			Name: "my-identification",
+			Type: "identification",
+			Identification: &akmv1a1.AkStageIdentification{
+				UserFields:    []string{"email"},
+				PasswordStage: "my-password",
+			},
+		},
+	}
+	login := &akmv1a1.AkStage{
+		ObjectMeta: metav1.ObjectMeta{Name: "login", Namespace: "apps"},
+		Spec:       akmv1a1.AkStageSpec{Type: "user_login"},
+	}
+	flow := &akmv1a1.AkFlow{
+		ObjectMeta: metav1.ObjectMeta{Name: "login", Namespace: "apps"},
+		Spec: akmv1a1.AkFlowSpec{
+			Instance:         akmv1a1.AuthentikInstance{Namespace: "auth"},
+			Slug:             "my-login",
+			Title:            "Welcome",
+			Designation:      "authentication",
+			PolicyEngineMode: "any",
+			Stages: []akmv1a1.AkFlowStageBinding{
+				{Name: "identify", ReEvaluatePolicies: true},
+				{Name: "login", ReEvaluatePolicies: true},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(identification, login, flow).Build()
+	r := &AkFlowReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+
+	stages, err := r.getBoundStages(ctx, flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "apps-flow-login", akFlowLabels(flow), akFlowBlueprint(flow, stages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"model: authentik_stages_identification.identificationstage",
+		"name: my-identification",
+		"password_stage: !Find [authentik_stages_password.passwordstage, [name, my-password]]",
+		"model: authentik_stages_user_login.userloginstage",
+		"model: authentik_flows.flow",
+		"slug: my-login",
+		"designation: authentication",
+		"model: authentik_flows.flowstagebinding",
+		"target: !KeyOf flow",
+		"stage: !KeyOf stage-identify",
+		"stage: !KeyOf stage-login",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	// stages are bound in the order listed
+	if strings.Index(content, "stage: !KeyOf stage-identify") > strings.Index(content, "stage: !KeyOf stage-login") {
+		t.Errorf("expected identify to be bound before login:\n%v", content)
+	}
+	// the generated blueprint must also pass admission
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated blueprint is invalid: %v", err)
+	}
+	reqs := r.akBlueprintToAkFlow(ctx, bp)
+	if len(reqs) != 1 || reqs[0].Name != "login" || reqs[0].Namespace != "apps" {
+		t.Fatalf("expected request for apps/login, got %v", reqs)
+	}
+
+	// changes to a bound stage reconcile the flow again
+	reqs = r.akStageToAkFlows(ctx, identification)
+	if len(reqs) != 1 || reqs[0].Name != "login" {
+		t.Fatalf("expected request for apps/login, got %v", reqs)
+	}
+
+	// flows cannot be rendered without all their stages
+	flow.Spec.Stages = append(flow.Spec.Stages, akmv1a1.AkFlowStageBinding{Name: "consent"})
+	if _, err := r.getBoundStages(ctx, flow); err == nil {
+		t.Error("expected an error binding a missing stage")
+	}
+}
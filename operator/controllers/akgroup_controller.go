@@ -0,0 +1,189 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the AkGroup resource that generated them.
+const (
+	akGroupNameLabel      = "akm.goauthentik.io/akgroup"
+	akGroupNamespaceLabel = "akm.goauthentik.io/akgroup-namespace"
+)
+
+// AkGroupReconciler reconciles a AkGroup object
+type AkGroupReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akgroups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akgroups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akgroups/finalizers,verbs=update
+
+// Reconcile turns an AkGroup resource into an AkBlueprint of the group in the authentik namespace.
+func (r *AkGroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.AkGroup{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("AkGroup resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get AkGroup resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found AkGroup resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("AkGroup resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, akGroupLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+
+	// BLUEPRINT
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-group-%v", crd.Namespace, crd.Name), akGroupLabels(crd), akGroupBlueprint(crd))
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+	crd.Status.AkBlueprint = fmt.Sprintf("%v/%v", bp.Namespace, bp.Name)
+	pending := []string{}
+	if !meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady) {
+		pending = append(pending, crd.Status.AkBlueprint)
+	}
+
+	// STATUS
+	meta.SetStatusCondition(&crd.Status.Conditions, utils.BlueprintsReadyCondition(pending, crd.Generation))
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setFailedStatus marks the AkGroup resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *AkGroupReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.AkGroup, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of AkGroup `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// akGroupLabels are the labels that mark a generated resource as belonging to the given AkGroup
+func akGroupLabels(crd *akmv1a1.AkGroup) map[string]string {
+	return map[string]string{
+		akGroupNameLabel:      crd.Name,
+		akGroupNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToAkGroup maps a generated AkBlueprint back to the AkGroup resource that generated it
+func (r *AkGroupReconciler) akBlueprintToAkGroup(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, akGroupNameLabel, akGroupNamespaceLabel)
+}
+
+// akGroupBlueprint is the blueprint content of a group, the parent, attributes, and roles are only set when
+// given so groups can be declared without them.
+func akGroupBlueprint(crd *akmv1a1.AkGroup) *blueprint.Blueprint {
+	attrs := map[string]interface{}{
+		"name":         crd.GroupName(),
+		"is_superuser": crd.Spec.IsSuperuser,
+	}
+	if crd.Spec.Parent != "" {
+		attrs["parent"] = blueprint.Find("authentik_core.group", "name", crd.Spec.Parent)
+	}
+	if crd.Spec.Attributes != nil {
+		attrs["attributes"] = crd.Spec.Attributes
+	}
+	if len(crd.Spec.Roles) > 0 {
+		roles := []interface{}{}
+		for _, role := range crd.Spec.Roles {
+			roles = append(roles, blueprint.Find("authentik_rbac.role", "name", role))
+		}
+		attrs["roles"] = roles
+	}
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       "authentik_core.group",
+				State:       "present",
+				Identifiers: map[string]interface{}{"name": crd.GroupName()},
+				Attrs:       attrs,
+			},
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AkGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.AkGroup{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToAkGroup)).
+		Complete(r)
+}
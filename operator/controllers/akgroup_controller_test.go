@@ -0,0 +1,111 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+)
+
+func TestAkGroupBlueprint(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := &AkGroupReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+
+	// as the api server would hand the resource to us
+	crd := &akmv1a1.AkGroup{}
+	data := []byte(`{"metadata": {"name": "editors", "namespace": "wiki"}, "spec": {
+		"instance": {"namespace": "auth"},
+		"parent": "staff",
+		"attributes": {"wiki": {"role": "editor"}},
+		"roles": ["wiki-editor"]
+	}}`)
+	if err := json.Unmarshal(data, crd); err != nil {
+		t.Fatal(err)
+	}
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "wiki-group-editors", akGroupLabels(crd), akGroupBlueprint(crd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"model: authentik_core.group",
+		"name: editors",
+		"is_superuser: false",
+		"parent: !Find [authentik_core.group, [name, staff]]",
+		"role: editor",
+		"- !Find [authentik_rbac.role, [name, wiki-editor]]",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("group blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated group blueprint is invalid: %v", err)
+	}
+	reqs := r.akBlueprintToAkGroup(ctx, bp)
+	if len(reqs) != 1 || reqs[0].Name != "editors" || reqs[0].Namespace != "wiki" {
+		t.Fatalf("expected request for wiki/editors, got %v", reqs)
+	}
+
+	named := &akmv1a1.AkGroup{ObjectMeta: metav1.ObjectMeta{Name: "admins"}, Spec: akmv1a1.AkGroupSpec{Name: "authentik Admins", IsSuperuser: true}}
+	if got := named.GroupName(); got != "authentik Admins" {
+		t.Errorf("GroupName() = %q", got)
+	}
+	if _, ok := akGroupBlueprint(named).Entries[0].Attrs["parent"]; ok {
+		t.Error("expected no parent without one given")
+	}
+
+	// names are quoted by the yaml encoder rather than breaking the !Find they are in
+	punctuated := &akmv1a1.AkGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops", Namespace: "wiki"},
+		Spec:       akmv1a1.AkGroupSpec{Parent: "Admins, Ops", Roles: []string{"a: b"}},
+	}
+	bp, err = r.ReconcileGeneratedBlueprint(ctx, "auth", "wiki-group-ops", akGroupLabels(punctuated), akGroupBlueprint(punctuated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"parent: !Find [authentik_core.group, [name, 'Admins, Ops']]",
+		"- !Find [authentik_rbac.role, [name, 'a: b']]",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("group blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated group blueprint is invalid: %v", err)
+	}
+}
@@ -0,0 +1,550 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/alexflint/go-arg"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the AkOutpost resource that generated them.
+const (
+	akOutpostNameLabel      = "akm.goauthentik.io/akoutpost"
+	akOutpostNamespaceLabel = "akm.goauthentik.io/akoutpost-namespace"
+)
+
+// outpostProviderModels are the blueprint models of the providers each outpost type serves
+var outpostProviderModels = map[string]string{
+	"proxy":  "authentik_providers_proxy.proxyprovider",
+	"ldap":   "authentik_providers_ldap.ldapprovider",
+	"radius": "authentik_providers_radius.radiusprovider",
+}
+
+// outpostPorts are the ports each outpost type listens on, every type also serves metrics on 9300
+var outpostPorts = map[string][]corev1.ContainerPort{
+	"proxy": {
+		{Name: "http", ContainerPort: 9000, Protocol: corev1.ProtocolTCP},
+		{Name: "https", ContainerPort: 9443, Protocol: corev1.ProtocolTCP},
+	},
+	"ldap": {
+		{Name: "ldap", ContainerPort: 3389, Protocol: corev1.ProtocolTCP},
+		{Name: "ldaps", ContainerPort: 6636, Protocol: corev1.ProtocolTCP},
+	},
+	"radius": {
+		{Name: "radius", ContainerPort: 1812, Protocol: corev1.ProtocolUDP},
+	},
+}
+
+// AkOutpostReconciler reconciles a AkOutpost object
+type AkOutpostReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akoutposts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akoutposts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akoutposts/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile turns an AkOutpost resource into an AkBlueprint of the outpost in the authentik namespace. Once
+// authentik has created the outpost its token is copied into a secret in the namespace of the resource, and the
+// outpost is run there by a deployment, service and optional ingress owned by the resource.
+func (r *AkOutpostReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.AkOutpost{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("AkOutpost resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get AkOutpost resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found AkOutpost resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("AkOutpost resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, akOutpostLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+
+	// BLUEPRINT
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-outpost-%v", crd.Namespace, crd.Name), akOutpostLabels(crd), akOutpostBlueprint(crd))
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+	crd.Status.AkBlueprint = fmt.Sprintf("%v/%v", bp.Namespace, bp.Name)
+	if !meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady) {
+		// the outpost and its token only exist in authentik once the blueprint has been applied
+		meta.SetStatusCondition(&crd.Status.Conditions, utils.BlueprintsReadyCondition([]string{crd.Status.AkBlueprint}, crd.Generation))
+		return ctrl.Result{}, r.updateStatus(ctx, crd, oldStatus)
+	}
+
+	// TOKEN - authentik generates a token for every outpost which it connects back with
+	values, err := r.GetReleasedValues(ak.Namespace, ak.Name)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "TokenFailed", err)
+	}
+	akc, err := r.NewAuthentikClient(ctx, ak)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "TokenFailed", err)
+	}
+	pk, token, err := outpostToken(ctx, akc, crd.OutpostName())
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "TokenFailed", err)
+	}
+	crd.Status.PK = pk
+	_, host := utils.AuthentikEndpoint(values, ak.Namespace)
+	secret := r.secretFromAkOutpost(crd, host, token)
+	if err = r.reconcileOwned(ctx, crd, secret); err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "SecretFailed", err)
+	}
+	crd.Status.Secret = secret.Name
+
+	// DEPLOYMENT
+	deployment := r.deploymentFromAkOutpost(crd, outpostImage(crd, values), secret)
+	if err = r.reconcileOwned(ctx, crd, deployment); err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "DeploymentFailed", err)
+	}
+	crd.Status.Deployment = deployment.Name
+	crd.Status.AvailableReplicas = deployment.Status.AvailableReplicas
+	if err = r.reconcileOwned(ctx, crd, r.serviceFromAkOutpost(crd)); err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "ServiceFailed", err)
+	}
+	ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: outpostResourceName(crd), Namespace: crd.Namespace}}
+	if crd.Spec.Ingress != nil {
+		if crd.Spec.Type != "proxy" {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "InvalidSpec", fmt.Errorf("only proxy outposts can have an ingress, type is `%v`", crd.Spec.Type))
+		}
+		err = r.reconcileOwned(ctx, crd, r.ingressFromAkOutpost(crd))
+	} else if err = r.Delete(ctx, ingress); errors.IsNotFound(err) {
+		err = nil
+	}
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "IngressFailed", err)
+	}
+
+	// STATUS
+	meta.SetStatusCondition(&crd.Status.Conditions, outpostReadyCondition(crd, deployment))
+	return ctrl.Result{}, r.updateStatus(ctx, crd, oldStatus)
+}
+
+// updateStatus writes the status of the AkOutpost resource if it differs from the old status
+func (r *AkOutpostReconciler) updateStatus(ctx context.Context, crd *akmv1a1.AkOutpost, oldStatus *akmv1a1.AkOutpostStatus) error {
+	if equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		return nil
+	}
+	return r.Status().Update(ctx, crd)
+}
+
+// setFailedStatus marks the AkOutpost resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *AkOutpostReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.AkOutpost, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of AkOutpost `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// outpostReadyCondition is the Ready condition of an applied outpost, which is only true once every replica
+// of its deployment is available
+func outpostReadyCondition(crd *akmv1a1.AkOutpost, deployment *appsv1.Deployment) metav1.Condition {
+	ready := metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "DeploymentAvailable",
+		Message:            "The outpost is applied and every replica is available.",
+		ObservedGeneration: crd.Generation,
+	}
+	want := int32(1)
+	if deployment.Spec.Replicas != nil {
+		want = *deployment.Spec.Replicas
+	}
+	if deployment.Status.AvailableReplicas < want {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "DeploymentPending"
+		ready.Message = fmt.Sprintf("Waiting for deployment `%v`, %v of %v replicas available.", deployment.Name, deployment.Status.AvailableReplicas, want)
+	}
+	return ready
+}
+
+// akOutpostLabels are the labels that mark a generated resource as belonging to the given AkOutpost
+func akOutpostLabels(crd *akmv1a1.AkOutpost) map[string]string {
+	return map[string]string{
+		akOutpostNameLabel:      crd.Name,
+		akOutpostNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToAkOutpost maps a generated AkBlueprint back to the AkOutpost resource that generated it
+func (r *AkOutpostReconciler) akBlueprintToAkOutpost(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, akOutpostNameLabel, akOutpostNamespaceLabel)
+}
+
+// akOutpostBlueprint is the blueprint content of an outpost without a service connection, since we deploy it ourselves
+func akOutpostBlueprint(crd *akmv1a1.AkOutpost) *blueprint.Blueprint {
+	providers := []interface{}{}
+	for _, provider := range crd.Spec.Providers {
+		providers = append(providers, blueprint.Find(outpostProviderModels[crd.Spec.Type], "name", provider))
+	}
+	attrs := map[string]interface{}{
+		"name":               crd.OutpostName(),
+		"type":               crd.Spec.Type,
+		"providers":          providers,
+		"service_connection": nil,
+	}
+	if crd.Spec.AuthentikHost != "" {
+		attrs["config"] = map[string]interface{}{"authentik_host": crd.Spec.AuthentikHost}
+	}
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       "authentik_outposts.outpost",
+				State:       "present",
+				Identifiers: map[string]interface{}{"name": crd.OutpostName()},
+				Attrs:       attrs,
+			},
+		},
+	}
+}
+
+// outpostToken finds the uuid of the named outpost and the key of the token authentik made for it
+func outpostToken(ctx context.Context, akc *authentik.Client, name string) (pk string, token string, err error) {
+	outposts, err := akc.ListOutposts(ctx, url.Values{"name__iexact": {name}})
+	if err != nil {
+		return "", "", err
+	}
+	if len(outposts) == 0 {
+		return "", "", fmt.Errorf("outpost `%v` not found in authentik", name)
+	}
+	pk = outposts[0].PK
+	token, err = akc.ViewTokenKey(ctx, fmt.Sprintf("ak-outpost-%v-api", pk))
+	if err != nil {
+		return "", "", err
+	}
+	return pk, token, nil
+}
+
+// outpostImage is the image of the outpost, by default that of its type at the version of the authentik server
+func outpostImage(crd *akmv1a1.AkOutpost, values map[string]interface{}) string {
+	if crd.Spec.Image != "" {
+		return crd.Spec.Image
+	}
+	registry, tag := "ghcr.io", "latest"
+	if section, ok := values["authentik"].(map[string]interface{}); ok {
+		if image, ok := section["image"].(map[string]interface{}); ok {
+			if r, ok := image["registry"].(string); ok && r != "" {
+				registry = r
+			}
+			if t, ok := image["tag"].(string); ok && t != "" {
+				tag = t
+			}
+		}
+	}
+	return fmt.Sprintf("%v/goauthentik/%v:%v", registry, crd.Spec.Type, tag)
+}
+
+// outpostResourceName is the name of the deployment, service and ingress running the outpost
+func outpostResourceName(crd *akmv1a1.AkOutpost) string {
+	return fmt.Sprintf("ak-outpost-%v", crd.Name)
+}
+
+// outpostServiceURL is the in cluster url of the http port of the service in front of the outpost
+func outpostServiceURL(crd *akmv1a1.AkOutpost) string {
+	port := int32(9000)
+	for _, p := range outpostPorts[crd.Spec.Type] {
+		if p.Name == "http" {
+			port = p.ContainerPort
+		}
+	}
+	return fmt.Sprintf("http://%v.%v.svc:%v", outpostResourceName(crd), crd.Namespace, port)
+}
+
+// outpostSelector are the labels selecting the pods of the outpost
+func outpostSelector(crd *akmv1a1.AkOutpost) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "authentik-outpost",
+		"app.kubernetes.io/instance":   crd.Name,
+		"app.kubernetes.io/component":  crd.Spec.Type,
+		"app.kubernetes.io/managed-by": "authentik-manager",
+	}
+}
+
+// reconcileOwned creates or updates a resource owned by the AkOutpost to match the desired specification, keeping
+// metadata and allocated fields others have set, and leaves the desired object holding the state of the cluster
+func (r *AkOutpostReconciler) reconcileOwned(ctx context.Context, crd *akmv1a1.AkOutpost, desired client.Object) error {
+	want := desired.DeepCopyObject().(client.Object)
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, desired, func() error {
+		switch obj := desired.(type) {
+		case *corev1.Secret:
+			obj.Data = want.(*corev1.Secret).Data
+		case *appsv1.Deployment:
+			obj.Spec = want.(*appsv1.Deployment).Spec
+		case *corev1.Service:
+			// the cluster IP and node ports are allocated by the cluster so are kept
+			spec := want.(*corev1.Service).Spec
+			spec.ClusterIP, spec.ClusterIPs = obj.Spec.ClusterIP, obj.Spec.ClusterIPs
+			for i := range spec.Ports {
+				for _, port := range obj.Spec.Ports {
+					if port.Name == spec.Ports[i].Name && spec.Type != corev1.ServiceTypeClusterIP {
+						spec.Ports[i].NodePort = port.NodePort
+					}
+				}
+			}
+			obj.Spec = spec
+		case *networkingv1.Ingress:
+			obj.Spec = want.(*networkingv1.Ingress).Spec
+		}
+		desired.SetLabels(mergeStringMaps(desired.GetLabels(), want.GetLabels()))
+		desired.SetAnnotations(mergeStringMaps(desired.GetAnnotations(), want.GetAnnotations()))
+		return ctrl.SetControllerReference(crd, desired, r.Scheme)
+	})
+	return err
+}
+
+// mergeStringMaps sets the entries of b onto a copy of a
+func mergeStringMaps(a map[string]string, b map[string]string) map[string]string {
+	if len(a) == 0 && len(b) == 0 {
+		return a
+	}
+	merged := map[string]string{}
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+// secretFromAkOutpost creates a secret specification holding the token of the outpost and the url it connects to
+func (r *AkOutpostReconciler) secretFromAkOutpost(crd *akmv1a1.AkOutpost, host string, token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%v-token", outpostResourceName(crd)),
+			Namespace:   crd.Namespace,
+			Labels:      outpostSelector(crd),
+			Annotations: crd.Annotations,
+		},
+		Data: map[string][]byte{
+			"host":  []byte(host),
+			"token": []byte(token),
+		},
+	}
+}
+
+// deploymentFromAkOutpost creates a deployment specification running the outpost, connecting to authentik
+// with the token and url from the secret
+func (r *AkOutpostReconciler) deploymentFromAkOutpost(crd *akmv1a1.AkOutpost, image string, secret *corev1.Secret) *appsv1.Deployment {
+	ports := append([]corev1.ContainerPort{}, outpostPorts[crd.Spec.Type]...)
+	ports = append(ports, corev1.ContainerPort{Name: "metrics", ContainerPort: 9300, Protocol: corev1.ProtocolTCP})
+	secretEnv := func(name string, key string) corev1.EnvVar {
+		return corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+					Key:                  key,
+				},
+			},
+		}
+	}
+	replicas := int32(1)
+	if crd.Spec.Replicas != nil {
+		replicas = *crd.Spec.Replicas
+	}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      outpostResourceName(crd),
+			Namespace: crd.Namespace,
+			Labels:    outpostSelector(crd),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: outpostSelector(crd)},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: outpostSelector(crd)},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  crd.Spec.Type,
+							Image: image,
+							Env: []corev1.EnvVar{
+								secretEnv("AUTHENTIK_HOST", "host"),
+								secretEnv("AUTHENTIK_TOKEN", "token"),
+								{Name: "AUTHENTIK_INSECURE", Value: "false"},
+							},
+							Ports:     ports,
+							Resources: crd.Spec.Resources,
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									HTTPGet: &corev1.HTTPGetAction{Path: "/outpost.goauthentik.io/ping", Port: intstr.FromString("metrics")},
+								},
+							},
+							LivenessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									HTTPGet: &corev1.HTTPGetAction{Path: "/outpost.goauthentik.io/ping", Port: intstr.FromString("metrics")},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// serviceFromAkOutpost creates a service specification exposing every port of the outpost
+func (r *AkOutpostReconciler) serviceFromAkOutpost(crd *akmv1a1.AkOutpost) *corev1.Service {
+	serviceType := crd.Spec.Service.Type
+	if serviceType == "" {
+		serviceType = corev1.ServiceTypeClusterIP
+	}
+	ports := []corev1.ServicePort{}
+	for _, port := range outpostPorts[crd.Spec.Type] {
+		ports = append(ports, corev1.ServicePort{
+			Name:       port.Name,
+			Port:       port.ContainerPort,
+			Protocol:   port.Protocol,
+			TargetPort: intstr.FromString(port.Name),
+		})
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        outpostResourceName(crd),
+			Namespace:   crd.Namespace,
+			Labels:      outpostSelector(crd),
+			Annotations: crd.Spec.Service.Annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     serviceType,
+			Selector: outpostSelector(crd),
+			Ports:    ports,
+		},
+	}
+}
+
+// ingressFromAkOutpost creates an ingress specification routing the hosts to the http port of a proxy outpost
+func (r *AkOutpostReconciler) ingressFromAkOutpost(crd *akmv1a1.AkOutpost) *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	rules := []networkingv1.IngressRule{}
+	for _, host := range crd.Spec.Ingress.Hosts {
+		rules = append(rules, networkingv1.IngressRule{
+			Host: host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{
+						{
+							Path:     "/",
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: outpostResourceName(crd),
+									Port: networkingv1.ServiceBackendPort{Name: "http"},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        outpostResourceName(crd),
+			Namespace:   crd.Namespace,
+			Labels:      outpostSelector(crd),
+			Annotations: crd.Spec.Ingress.Annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: crd.Spec.Ingress.ClassName,
+			Rules:            rules,
+		},
+	}
+	if crd.Spec.Ingress.TLSSecret != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: crd.Spec.Ingress.Hosts, SecretName: crd.Spec.Ingress.TLSSecret}}
+	}
+	return ingress
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AkOutpostReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.AkOutpost{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&corev1.Secret{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.Ingress{}).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToAkOutpost)).
+		Complete(r)
+}
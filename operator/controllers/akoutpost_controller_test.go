@@ -0,0 +1,206 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	akfake "gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik/fake"
+)
+
+func TestAkOutpostBlueprint(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	crd := &akmv1a1.AkOutpost{
+		ObjectMeta: metav1.ObjectMeta{Name: "internal", Namespace: "apps"},
+		Spec: akmv1a1.AkOutpostSpec{
+			Instance:      akmv1a1.AuthentikInstance{Namespace: "auth"},
+			Type:          "proxy",
+			Providers:     []string{"whoami", "grafana"},
+			AuthentikHost: "https://auth.example.org",
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(crd).Build()
+	r := &AkOutpostReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "apps-outpost-internal", akOutpostLabels(crd), akOutpostBlueprint(crd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"model: authentik_outposts.outpost",
+		"name: internal",
+		"type: proxy",
+		"- !Find [authentik_providers_proxy.proxyprovider, [name, whoami]]",
+		"- !Find [authentik_providers_proxy.proxyprovider, [name, grafana]]",
+		"service_connection: null",
+		"authentik_host: https://auth.example.org",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated blueprint is invalid: %v", err)
+	}
+	reqs := r.akBlueprintToAkOutpost(ctx, bp)
+	if len(reqs) != 1 || reqs[0].Name != "internal" || reqs[0].Namespace != "apps" {
+		t.Fatalf("expected request for apps/internal, got %v", reqs)
+	}
+}
+
+func TestOutpostToken(t *testing.T) {
+	ctx := context.Background()
+	srv := akfake.NewServer("token")
+	t.Cleanup(srv.Close)
+	akc, err := authentik.NewClient(srv.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outpost := srv.Seed("outposts/instances", map[string]interface{}{"name": "Internal", "type": "proxy"})
+	srv.HandleFunc(fmt.Sprintf("core/tokens/ak-outpost-%v-api/view_key", outpost["pk"]), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"key": "outpost-token"}`))
+	})
+
+	pk, token, err := outpostToken(ctx, akc, "internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk != outpost["pk"] || token != "outpost-token" {
+		t.Errorf("outpostToken() = %q, %q", pk, token)
+	}
+	if _, _, err := outpostToken(ctx, akc, "missing"); err == nil || !strings.Contains(err.Error(), "outpost `missing` not found") {
+		t.Errorf("expected missing outpost error, got %v", err)
+	}
+}
+
+func TestAkOutpostResources(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	replicas := int32(2)
+	crd := &akmv1a1.AkOutpost{
+		ObjectMeta: metav1.ObjectMeta{Name: "internal", Namespace: "apps", UID: "1234"},
+		Spec: akmv1a1.AkOutpostSpec{
+			Instance:  akmv1a1.AuthentikInstance{Namespace: "auth"},
+			Type:      "proxy",
+			Providers: []string{"whoami"},
+			Replicas:  &replicas,
+			Service:   akmv1a1.AkOutpostService{Type: corev1.ServiceTypeLoadBalancer},
+			Ingress:   &akmv1a1.AkOutpostIngress{Hosts: []string{"whoami.example.org"}, TLSSecret: "whoami-tls"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(crd).Build()
+	r := &AkOutpostReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+
+	values := map[string]interface{}{"authentik": map[string]interface{}{"image": map[string]interface{}{"registry": "ghcr.io", "tag": "2024.2.3"}}}
+	if got := outpostImage(crd, values); got != "ghcr.io/goauthentik/proxy:2024.2.3" {
+		t.Errorf("outpostImage() = %q", got)
+	}
+
+	secret := r.secretFromAkOutpost(crd, "http://authentik-server.auth.svc:80", "outpost-token")
+	if err := r.reconcileOwned(ctx, crd, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileOwned(ctx, crd, r.deploymentFromAkOutpost(crd, outpostImage(crd, values), secret)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileOwned(ctx, crd, r.serviceFromAkOutpost(crd)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileOwned(ctx, crd, r.ingressFromAkOutpost(crd)); err != nil {
+		t.Fatal(err)
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "ak-outpost-internal", Namespace: "apps"}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	if len(deployment.OwnerReferences) != 1 || deployment.OwnerReferences[0].Name != "internal" {
+		t.Errorf("expected the deployment to be owned by the AkOutpost, got %v", deployment.OwnerReferences)
+	}
+	if *deployment.Spec.Replicas != 2 {
+		t.Errorf("replicas = %v, want 2", *deployment.Spec.Replicas)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Image != "ghcr.io/goauthentik/proxy:2024.2.3" {
+		t.Errorf("image = %q", container.Image)
+	}
+	for _, env := range container.Env {
+		if env.Name == "AUTHENTIK_TOKEN" && (env.ValueFrom == nil || env.ValueFrom.SecretKeyRef.Name != "ak-outpost-internal-token") {
+			t.Errorf("expected the token to come from the token secret, got %v", env)
+		}
+	}
+
+	// the cluster allocated node ports and annotations set by others survive an update
+	service := &corev1.Service{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "ak-outpost-internal", Namespace: "apps"}, service); err != nil {
+		t.Fatal(err)
+	}
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer || len(service.Spec.Ports) != 2 {
+		t.Fatalf("unexpected service spec %v", service.Spec)
+	}
+	service.Spec.Ports[0].NodePort = 31000
+	service.Annotations = map[string]string{"example.org/owner": "someone"}
+	if err := c.Update(ctx, service); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileOwned(ctx, crd, r.serviceFromAkOutpost(crd)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "ak-outpost-internal", Namespace: "apps"}, service); err != nil {
+		t.Fatal(err)
+	}
+	if service.Spec.Ports[0].NodePort != 31000 || service.Annotations["example.org/owner"] != "someone" {
+		t.Errorf("expected node port and annotations to be kept, got %v %v", service.Spec.Ports, service.Annotations)
+	}
+
+	// the outpost is only ready once every replica is available
+	deployment.Status.AvailableReplicas = 1
+	if ready := outpostReadyCondition(crd, deployment); ready.Status != metav1.ConditionFalse || ready.Reason != "DeploymentPending" {
+		t.Errorf("expected pending with 1 of 2 replicas, got %v", ready)
+	}
+	deployment.Status.AvailableReplicas = 2
+	if ready := outpostReadyCondition(crd, deployment); ready.Status != metav1.ConditionTrue {
+		t.Errorf("expected ready with 2 of 2 replicas, got %v", ready)
+	}
+}
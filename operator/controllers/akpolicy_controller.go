@@ -0,0 +1,319 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	yaml_v3 "gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the AkPolicy resource that generated them.
+const (
+	akPolicyNameLabel      = "akm.goauthentik.io/akpolicy"
+	akPolicyNamespaceLabel = "akm.goauthentik.io/akpolicy-namespace"
+)
+
+// policyModels are the authentik models of each AkPolicy type, group membership is a binding so has none
+var policyModels = map[string]string{
+	"expression":    "authentik_policies_expression.expressionpolicy",
+	"password":      "authentik_policies_password.passwordpolicy",
+	"reputation":    "authentik_policies_reputation.reputationpolicy",
+	"event_matcher": "authentik_policies_event_matcher.eventmatcherpolicy",
+}
+
+// AkPolicyReconciler reconciles a AkPolicy object
+type AkPolicyReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akpolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akpolicies/finalizers,verbs=update
+
+// Reconcile turns an AkPolicy resource into an AkBlueprint of the policy and its bindings in the authentik namespace.
+func (r *AkPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.AkPolicy{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("AkPolicy resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get AkPolicy resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found AkPolicy resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("AkPolicy resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, akPolicyLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+
+	// EXPRESSION
+	expression, err := r.resolveExpression(ctx, crd)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "ExpressionFailed", err)
+	}
+
+	// BLUEPRINT
+	content, err := akPolicyBlueprint(crd, expression)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "InvalidSpec", err)
+	}
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-policy-%v", crd.Namespace, crd.Name), akPolicyLabels(crd), content)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+	crd.Status.AkBlueprint = fmt.Sprintf("%v/%v", bp.Namespace, bp.Name)
+	pending := []string{}
+	if !meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady) {
+		pending = append(pending, crd.Status.AkBlueprint)
+	}
+
+	// STATUS
+	meta.SetStatusCondition(&crd.Status.Conditions, utils.BlueprintsReadyCondition(pending, crd.Generation))
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setFailedStatus marks the AkPolicy resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *AkPolicyReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.AkPolicy, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of AkPolicy `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// akPolicyLabels are the labels that mark a generated resource as belonging to the given AkPolicy
+func akPolicyLabels(crd *akmv1a1.AkPolicy) map[string]string {
+	return map[string]string{
+		akPolicyNameLabel:      crd.Name,
+		akPolicyNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToAkPolicy maps a generated AkBlueprint back to the AkPolicy resource that generated it
+func (r *AkPolicyReconciler) akBlueprintToAkPolicy(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, akPolicyNameLabel, akPolicyNamespaceLabel)
+}
+
+// configMapToAkPolicies maps a ConfigMap to the AkPolicies in its namespace that read their expression from it
+func (r *AkPolicyReconciler) configMapToAkPolicies(ctx context.Context, obj client.Object) []reconcile.Request {
+	l := klog.FromContext(ctx)
+	policies := &akmv1a1.AkPolicyList{}
+	err := r.List(ctx, policies, client.InNamespace(obj.GetNamespace()))
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to list AkPolicies reading ConfigMap `%v` in `%v`.", obj.GetName(), obj.GetNamespace()))
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, policy := range policies.Items {
+		if e := policy.Spec.Expression; e != nil && e.ConfigMap != nil && e.ConfigMap.Name == obj.GetName() {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace}})
+		}
+	}
+	return reqs
+}
+
+// resolveExpression gets the python source of an expression policy, preferring the ConfigMap over the inline source
+func (r *AkPolicyReconciler) resolveExpression(ctx context.Context, crd *akmv1a1.AkPolicy) (string, error) {
+	e := crd.Spec.Expression
+	if crd.Spec.Type != "expression" || e == nil {
+		return "", nil
+	}
+	if e.ConfigMap == nil {
+		return e.Source, nil
+	}
+	source, err := r.GetConfigMapKey(ctx, crd.Namespace, e.ConfigMap)
+	if err != nil {
+		return "", err
+	}
+	return string(source), nil
+}
+
+// policyBindingTarget is the blueprint reference to what a binding binds the policy to. Stages are bound
+// where they are bound in a flow since authentik binds policies to the binding of the stage, not the stage.
+func policyBindingTarget(binding akmv1a1.AkPolicyBinding) (*yaml_v3.Node, error) {
+	switch {
+	case binding.Application != "" && binding.Flow == "" && binding.Stage == "":
+		return blueprint.Find("authentik_core.application", "slug", binding.Application), nil
+	case binding.Application == "" && binding.Flow != "" && binding.Stage == "":
+		return blueprint.Find("authentik_flows.flow", "slug", binding.Flow), nil
+	case binding.Application == "" && binding.Flow != "" && binding.Stage != "":
+		return blueprint.FindBy("authentik_flows.flowstagebinding",
+			blueprint.Field{Name: "target", Value: blueprint.Find("authentik_flows.flow", "slug", binding.Flow)},
+			blueprint.Field{Name: "stage", Value: blueprint.Find("authentik_flows.stage", "name", binding.Stage)},
+		), nil
+	}
+	return nil, fmt.Errorf("bindings need exactly one of an application, a flow, or a stage with its flow, got %+v", binding)
+}
+
+// akPolicyBlueprint is the blueprint content of a policy and its bindings, only the settings of its type are
+// used and any not given are left to the authentik defaults.
+func akPolicyBlueprint(crd *akmv1a1.AkPolicy, expression string) (*blueprint.Blueprint, error) {
+	entries := []blueprint.Entry{}
+	// what bindings bind, either the policy or for group membership a group
+	bound := map[string]interface{}{"policy": blueprint.KeyOf("policy")}
+	if crd.Spec.Type == "group_membership" {
+		if crd.Spec.GroupMembership == nil || crd.Spec.GroupMembership.Group == "" {
+			return nil, fmt.Errorf("group membership policies need a group")
+		}
+		bound = map[string]interface{}{"group": blueprint.Find("authentik_core.group", "name", crd.Spec.GroupMembership.Group)}
+	} else {
+		attrs := map[string]interface{}{
+			"name":              crd.PolicyName(),
+			"execution_logging": crd.Spec.ExecutionLogging,
+		}
+		switch crd.Spec.Type {
+		case "expression":
+			if expression == "" {
+				return nil, fmt.Errorf("expression policies need an expression")
+			}
+			attrs["expression"] = expression
+		case "password":
+			if s := crd.Spec.Password; s != nil {
+				attrs["password_field"] = s.PasswordField
+				attrs["check_static_rules"] = true
+				attrs["length_min"] = s.LengthMin
+				attrs["amount_uppercase"] = s.AmountUppercase
+				attrs["amount_lowercase"] = s.AmountLowercase
+				attrs["amount_digits"] = s.AmountDigits
+				attrs["amount_symbols"] = s.AmountSymbols
+				attrs["error_message"] = s.ErrorMessage
+				attrs["check_have_i_been_pwned"] = s.CheckHaveIBeenPwned
+				attrs["hibp_allowed_count"] = s.HIBPAllowedCount
+				attrs["check_zxcvbn"] = s.CheckZxcvbn
+				attrs["zxcvbn_score_threshold"] = s.ZxcvbnScoreThreshold
+			}
+		case "reputation":
+			if s := crd.Spec.Reputation; s != nil {
+				attrs["check_ip"] = s.CheckIP
+				attrs["check_username"] = s.CheckUsername
+				attrs["threshold"] = s.Threshold
+			}
+		case "event_matcher":
+			if s := crd.Spec.EventMatcher; s != nil {
+				for key, value := range map[string]string{"action": s.Action, "client_ip": s.ClientIP, "app": s.App, "model": s.Model} {
+					if value != "" {
+						attrs[key] = value
+					}
+				}
+			}
+		}
+		entries = append(entries, blueprint.Entry{
+			Model:       policyModels[crd.Spec.Type],
+			State:       "present",
+			ID:          "policy",
+			Identifiers: map[string]interface{}{"name": crd.PolicyName()},
+			Attrs:       attrs,
+		})
+	}
+	for _, binding := range crd.Spec.Bindings {
+		target, err := policyBindingTarget(binding)
+		if err != nil {
+			return nil, err
+		}
+		identifiers := map[string]interface{}{
+			"target": target,
+			"order":  binding.Order,
+		}
+		attrs := map[string]interface{}{
+			"target":  target,
+			"order":   binding.Order,
+			"negate":  binding.Negate,
+			"timeout": binding.Timeout,
+			"enabled": true,
+		}
+		for key, value := range bound {
+			identifiers[key] = value
+			attrs[key] = value
+		}
+		entries = append(entries, blueprint.Entry{
+			Model:       "authentik_policies.policybinding",
+			State:       "present",
+			Identifiers: identifiers,
+			Attrs:       attrs,
+		})
+	}
+	return &blueprint.Blueprint{Version: 1, Entries: entries}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AkPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.AkPolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToAkPolicy)).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.configMapToAkPolicies)).
+		Complete(r)
+}
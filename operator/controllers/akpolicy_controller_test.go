@@ -0,0 +1,167 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+)
+
+func TestAkPolicyBlueprint(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "policies", Namespace: "apps"},
+		Data:       map[string]string{"admins.py": "return ak_is_group_member(request.user, name=\"admins\")\n"},
+	}
+	expression := &akmv1a1.AkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "admins-only", Namespace: "apps"},
+		Spec: akmv1a1.AkPolicySpec{
+			Instance: akmv1a1.AuthentikInstance{Namespace: "auth"},
+			Type:     "expression",
+			Expression: &akmv1a1.AkPolicyExpression{
+				ConfigMap: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "policies"}, Key: "admins.py"},
+			},
+			Bindings: []akmv1a1.AkPolicyBinding{
+				{Application: "wiki", Order: 10, Timeout: 30},
+				{Flow: "my-login", Stage: "my-password", Negate: true, Timeout: 30},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm, expression).Build()
+	r := &AkPolicyReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+
+	source, err := r.resolveExpression(ctx, expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != cm.Data["admins.py"] {
+		t.Fatalf("expected the expression from the ConfigMap, got %q", source)
+	}
+	reqs := r.configMapToAkPolicies(ctx, cm)
+	if len(reqs) != 1 || reqs[0].Name != "admins-only" {
+		t.Fatalf("expected request for apps/admins-only, got %v", reqs)
+	}
+
+	tests := map[string]struct {
+		crd  *akmv1a1.AkPolicy
+		want []string
+	}{
+		"expression": {
+			crd: expression,
+			want: []string{
+				"model: authentik_policies_expression.expressionpolicy",
+				"name: admins-only",
+				"ak_is_group_member(request.user, name=\"admins\")",
+				"model: authentik_policies.policybinding",
+				"policy: !KeyOf policy",
+				"target: !Find [authentik_core.application, [slug, wiki]]",
+				"target: !Find [authentik_flows.flowstagebinding, [target, !Find [authentik_flows.flow, [slug, my-login]]], [stage, !Find [authentik_flows.stage, [name, my-password]]]]",
+				"negate: true",
+			},
+		},
+		"group membership": {
+			crd: &akmv1a1.AkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "staff", Namespace: "apps"},
+				Spec: akmv1a1.AkPolicySpec{
+					Type:            "group_membership",
+					GroupMembership: &akmv1a1.AkPolicyGroupMembership{Group: "staff"},
+					Bindings:        []akmv1a1.AkPolicyBinding{{Flow: "my-login", Timeout: 30}},
+				},
+			},
+			want: []string{
+				"group: !Find [authentik_core.group, [name, staff]]",
+				"target: !Find [authentik_flows.flow, [slug, my-login]]",
+			},
+		},
+		"reputation": {
+			crd: &akmv1a1.AkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "reputation", Namespace: "apps"},
+				Spec: akmv1a1.AkPolicySpec{
+					Type:       "reputation",
+					Reputation: &akmv1a1.AkPolicyReputation{CheckIP: true, Threshold: -5},
+				},
+			},
+			want: []string{"model: authentik_policies_reputation.reputationpolicy", "threshold: -5", "check_ip: true"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			source, err := r.resolveExpression(ctx, tt.crd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := akPolicyBlueprint(tt.crd, source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bp, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "apps-policy-"+tt.crd.Name, akPolicyLabels(tt.crd), content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rendered, err := blueprintContent(bp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(rendered, want) {
+					t.Errorf("blueprint does not contain %q:\n%v", want, rendered)
+				}
+			}
+			// the generated blueprints must also pass admission
+			if err := bp.Validate(); err != nil {
+				t.Errorf("generated blueprint is invalid: %v", err)
+			}
+			reqs := r.akBlueprintToAkPolicy(ctx, bp)
+			if len(reqs) != 1 || reqs[0].Name != tt.crd.Name || reqs[0].Namespace != "apps" {
+				t.Fatalf("expected request for apps/%v, got %v", tt.crd.Name, reqs)
+			}
+		})
+	}
+}
+
+func TestPolicyBindingTarget(t *testing.T) {
+	tests := map[string]struct {
+		binding akmv1a1.AkPolicyBinding
+		valid   bool
+	}{
+		"application":          {binding: akmv1a1.AkPolicyBinding{Application: "wiki"}, valid: true},
+		"flow":                 {binding: akmv1a1.AkPolicyBinding{Flow: "my-login"}, valid: true},
+		"stage":                {binding: akmv1a1.AkPolicyBinding{Flow: "my-login", Stage: "my-password"}, valid: true},
+		"nothing":              {binding: akmv1a1.AkPolicyBinding{}, valid: false},
+		"stage without flow":   {binding: akmv1a1.AkPolicyBinding{Stage: "my-password"}, valid: false},
+		"application and flow": {binding: akmv1a1.AkPolicyBinding{Application: "wiki", Flow: "my-login"}, valid: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := policyBindingTarget(tt.binding)
+			if (err == nil) != tt.valid {
+				t.Errorf("policyBindingTarget() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
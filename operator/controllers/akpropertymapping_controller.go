@@ -0,0 +1,251 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the AkPropertyMapping resource that generated them.
+const (
+	akPropertyMappingNameLabel      = "akm.goauthentik.io/akpropertymapping"
+	akPropertyMappingNamespaceLabel = "akm.goauthentik.io/akpropertymapping-namespace"
+)
+
+// propertyMappingModels are the blueprint models of each property mapping type
+var propertyMappingModels = map[string]string{
+	"scope": "authentik_providers_oauth2.scopemapping",
+	"saml":  "authentik_providers_saml.samlpropertymapping",
+}
+
+// AkPropertyMappingReconciler reconciles a AkPropertyMapping object
+type AkPropertyMappingReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akpropertymappings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akpropertymappings/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akpropertymappings/finalizers,verbs=update
+
+// Reconcile turns an AkPropertyMapping resource into an AkBlueprint of the mapping in the authentik namespace,
+// which OIDC and SAML providers then refer to by name.
+func (r *AkPropertyMappingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.AkPropertyMapping{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("AkPropertyMapping resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get AkPropertyMapping resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found AkPropertyMapping resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("AkPropertyMapping resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, akPropertyMappingLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+
+	// EXPRESSION
+	expression, err := r.resolveExpression(ctx, crd)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "ExpressionFailed", err)
+	}
+
+	// BLUEPRINT
+	content, err := akPropertyMappingBlueprint(crd, expression)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "InvalidSpec", err)
+	}
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-mapping-%v", crd.Namespace, crd.Name), akPropertyMappingLabels(crd), content)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+	crd.Status.AkBlueprint = fmt.Sprintf("%v/%v", bp.Namespace, bp.Name)
+	pending := []string{}
+	if !meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady) {
+		pending = append(pending, crd.Status.AkBlueprint)
+	}
+
+	// STATUS
+	meta.SetStatusCondition(&crd.Status.Conditions, utils.BlueprintsReadyCondition(pending, crd.Generation))
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setFailedStatus marks the AkPropertyMapping resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *AkPropertyMappingReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.AkPropertyMapping, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of AkPropertyMapping `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// akPropertyMappingLabels are the labels that mark a generated resource as belonging to the given AkPropertyMapping
+func akPropertyMappingLabels(crd *akmv1a1.AkPropertyMapping) map[string]string {
+	return map[string]string{
+		akPropertyMappingNameLabel:      crd.Name,
+		akPropertyMappingNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToAkPropertyMapping maps a generated AkBlueprint back to the AkPropertyMapping resource that generated it
+func (r *AkPropertyMappingReconciler) akBlueprintToAkPropertyMapping(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, akPropertyMappingNameLabel, akPropertyMappingNamespaceLabel)
+}
+
+// configMapToAkPropertyMappings maps a ConfigMap to the AkPropertyMappings in its namespace that read their expression from it
+func (r *AkPropertyMappingReconciler) configMapToAkPropertyMappings(ctx context.Context, obj client.Object) []reconcile.Request {
+	l := klog.FromContext(ctx)
+	mappings := &akmv1a1.AkPropertyMappingList{}
+	err := r.List(ctx, mappings, client.InNamespace(obj.GetNamespace()))
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to list AkPropertyMappings reading ConfigMap `%v` in `%v`.", obj.GetName(), obj.GetNamespace()))
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, mapping := range mappings.Items {
+		if cm := mapping.Spec.Expression.ConfigMap; cm != nil && cm.Name == obj.GetName() {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: mapping.Name, Namespace: mapping.Namespace}})
+		}
+	}
+	return reqs
+}
+
+// resolveExpression gets the python source of the mapping, preferring the ConfigMap over the inline source
+func (r *AkPropertyMappingReconciler) resolveExpression(ctx context.Context, crd *akmv1a1.AkPropertyMapping) (string, error) {
+	e := crd.Spec.Expression
+	if e.ConfigMap == nil {
+		return e.Source, nil
+	}
+	source, err := r.GetConfigMapKey(ctx, crd.Namespace, e.ConfigMap)
+	if err != nil {
+		return "", err
+	}
+	return string(source), nil
+}
+
+// akPropertyMappingBlueprint is the blueprint content of a property mapping with the given expression
+func akPropertyMappingBlueprint(crd *akmv1a1.AkPropertyMapping, expression string) (*blueprint.Blueprint, error) {
+	if expression == "" {
+		return nil, fmt.Errorf("the expression of mapping `%v` is empty", crd.MappingName())
+	}
+	attrs := map[string]interface{}{
+		"name":       crd.MappingName(),
+		"expression": expression,
+	}
+	switch {
+	case crd.Spec.Type == "scope" && crd.Spec.Scope != nil:
+		attrs["scope_name"] = crd.Spec.Scope.ScopeName
+		attrs["description"] = crd.Spec.Scope.Description
+	case crd.Spec.Type == "saml" && crd.Spec.SAML != nil:
+		attrs["saml_name"] = crd.Spec.SAML.AttributeName
+		if crd.Spec.SAML.FriendlyName != "" {
+			attrs["friendly_name"] = crd.Spec.SAML.FriendlyName
+		}
+	default:
+		return nil, fmt.Errorf("`%v` mappings need their settings under `%v`", crd.Spec.Type, crd.Spec.Type)
+	}
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       propertyMappingModels[crd.Spec.Type],
+				State:       "present",
+				Identifiers: map[string]interface{}{"name": crd.MappingName()},
+				Attrs:       attrs,
+			},
+		},
+	}, nil
+}
+
+// propertyMappingFinds are the blueprint references to the named property mappings of the given model, for
+// providers to bind them
+func propertyMappingFinds(model string, names []string) []interface{} {
+	finds := []interface{}{}
+	for _, name := range names {
+		finds = append(finds, blueprint.Find(model, "name", name))
+	}
+	return finds
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AkPropertyMappingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.AkPropertyMapping{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToAkPropertyMapping)).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.configMapToAkPropertyMappings)).
+		Complete(r)
+}
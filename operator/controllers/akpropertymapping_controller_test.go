@@ -0,0 +1,172 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+func TestAkPropertyMappingBlueprint(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "mappings", Namespace: "apps"},
+		Data:       map[string]string{"groups.py": "return {\"groups\": [group.name for group in request.user.ak_groups.all()]}"},
+	}
+	crd := &akmv1a1.AkPropertyMapping{
+		ObjectMeta: metav1.ObjectMeta{Name: "groups", Namespace: "apps"},
+		Spec: akmv1a1.AkPropertyMappingSpec{
+			Instance: akmv1a1.AuthentikInstance{Namespace: "auth"},
+			Type:     "scope",
+			Expression: akmv1a1.AkPropertyMappingExpression{
+				ConfigMap: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "mappings"}, Key: "groups.py"},
+			},
+			Scope: &akmv1a1.AkPropertyMappingScope{ScopeName: "groups", Description: "See your groups"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm, crd).Build()
+	r := &AkPropertyMappingReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+
+	expression, err := r.resolveExpression(ctx, crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expression != cm.Data["groups.py"] {
+		t.Errorf("resolveExpression() = %q", expression)
+	}
+	content, err := akPropertyMappingBlueprint(crd, expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "apps-mapping-groups", akPropertyMappingLabels(crd), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"model: authentik_providers_oauth2.scopemapping",
+		"name: groups",
+		"scope_name: groups",
+		"description: See your groups",
+		"request.user.ak_groups.all()",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("blueprint does not contain %q:\n%v", want, got)
+		}
+	}
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated blueprint is invalid: %v", err)
+	}
+	reqs := r.akBlueprintToAkPropertyMapping(ctx, bp)
+	if len(reqs) != 1 || reqs[0].Name != "groups" || reqs[0].Namespace != "apps" {
+		t.Fatalf("expected request for apps/groups, got %v", reqs)
+	}
+
+	// changes to the ConfigMap reconcile the mapping again
+	reqs = r.configMapToAkPropertyMappings(ctx, cm)
+	if len(reqs) != 1 || reqs[0].Name != "groups" {
+		t.Fatalf("expected request for apps/groups, got %v", reqs)
+	}
+}
+
+func TestAkPropertyMappingSAMLBlueprint(t *testing.T) {
+	crd := &akmv1a1.AkPropertyMapping{
+		ObjectMeta: metav1.ObjectMeta{Name: "department", Namespace: "apps"},
+		Spec: akmv1a1.AkPropertyMappingSpec{
+			Name:       "Department",
+			Type:       "saml",
+			Expression: akmv1a1.AkPropertyMappingExpression{Source: "return request.user.attributes.get(\"department\")"},
+			SAML:       &akmv1a1.AkPropertyMappingSAML{AttributeName: "department"},
+		},
+	}
+	content, err := akPropertyMappingBlueprint(crd, crd.Spec.Expression.Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := content.Entries[0]
+	attrs := entry.Attrs
+	if entry.Model != "authentik_providers_saml.samlpropertymapping" || attrs["name"] != "Department" || attrs["saml_name"] != "department" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+	if _, ok := attrs["friendly_name"]; ok {
+		t.Errorf("expected no friendly name, got %v", attrs["friendly_name"])
+	}
+
+	// mappings need their settings and an expression
+	crd.Spec.SAML = nil
+	if _, err := akPropertyMappingBlueprint(crd, crd.Spec.Expression.Source); err == nil {
+		t.Error("expected an error for a saml mapping without saml settings")
+	}
+	if _, err := akPropertyMappingBlueprint(crd, ""); err == nil {
+		t.Error("expected an error for an empty expression")
+	}
+}
+
+func TestProviderPropertyMappings(t *testing.T) {
+	saml := samlProviderBlueprint(&akmv1a1.SAMLProvider{
+		Name:             "wiki",
+		ProtocolSettings: akmv1a1.SAMLProviderProtocolSettings{PropertyMappings: []string{"Department"}},
+	})
+	want := []interface{}{blueprint.Find("authentik_providers_saml.samlpropertymapping", "name", "Department")}
+	if finds := saml.Entries[0].Attrs["property_mappings"]; !reflect.DeepEqual(finds, want) {
+		t.Errorf("saml property_mappings = %v, want %v", finds, want)
+	}
+
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := &OIDCReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+	ak := &akmv1a1.Ak{ObjectMeta: metav1.ObjectMeta{Name: "ak", Namespace: "auth"}}
+	crd := &akmv1a1.OIDC{ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "app"}}
+	provider := &akmv1a1.OIDCProvider{
+		Name:             "myapp",
+		ProtocolSettings: akmv1a1.OIDCProviderProtocolSettings{PropertyMappings: []string{"groups"}},
+	}
+	bp, err := r.reconcileProviderBlueprint(ak, ctx, crd, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "- !Find [authentik_providers_oauth2.scopemapping, [name, groups]]"; !strings.Contains(content, want) {
+		t.Errorf("provider blueprint does not contain %q:\n%v", want, content)
+	}
+}
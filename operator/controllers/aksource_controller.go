@@ -0,0 +1,367 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the AkSource resource that generated them.
+const (
+	akSourceNameLabel      = "akm.goauthentik.io/aksource"
+	akSourceNamespaceLabel = "akm.goauthentik.io/aksource-namespace"
+)
+
+// sourceModels are the blueprint models of each source type
+var sourceModels = map[string]string{
+	"oauth": "authentik_sources_oauth.oauthsource",
+	"saml":  "authentik_sources_saml.samlsource",
+}
+
+// AkSourceReconciler reconciles a AkSource object
+type AkSourceReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=aksources,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=aksources/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=aksources/finalizers,verbs=update
+
+// Reconcile turns an AkSource resource into an AkBlueprint of the source in the authentik namespace. The client
+// credentials of OAuth sources are read from a secret and only ever given to authentik through its API, so they
+// are never written into a blueprint.
+func (r *AkSourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.AkSource{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("AkSource resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get AkSource resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found AkSource resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("AkSource resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, akSourceLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+
+	if (crd.Spec.Type == "oauth" && crd.Spec.OAuth == nil) || (crd.Spec.Type == "saml" && crd.Spec.SAML == nil) {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "InvalidSpec", fmt.Errorf("`%v` sources need their settings under `%v`", crd.Spec.Type, crd.Spec.Type))
+	}
+
+	// CREDENTIALS - the source is created through the API with them so the blueprint never needs them
+	var akc *authentik.Client
+	if crd.Spec.Type == "oauth" {
+		clientID, clientSecret, err := r.getCredentials(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "SecretFailed", err)
+		}
+		akc, err = r.NewAuthentikClient(ctx, ak)
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "CredentialsFailed", err)
+		}
+		err = r.syncCredentials(ctx, akc, crd, clientID, clientSecret)
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "CredentialsFailed", err)
+		}
+	}
+
+	// BLUEPRINT
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-source-%v", crd.Namespace, crd.Name), akSourceLabels(crd), akSourceBlueprint(crd))
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+	crd.Status.AkBlueprint = fmt.Sprintf("%v/%v", bp.Namespace, bp.Name)
+	pending := []string{}
+	if !meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady) {
+		pending = append(pending, crd.Status.AkBlueprint)
+	}
+
+	// ICON - icons are not part of blueprints so are set through the API once the source exists
+	if len(pending) == 0 && crd.Spec.Icon != "" {
+		if akc == nil {
+			akc, err = r.NewAuthentikClient(ctx, ak)
+			if err != nil {
+				return ctrl.Result{}, r.setFailedStatus(ctx, crd, "IconFailed", err)
+			}
+		}
+		err = akc.SetSourceIconURL(ctx, crd.Spec.Slug, crd.Spec.Icon)
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "IconFailed", err)
+		}
+	}
+
+	// STATUS
+	meta.SetStatusCondition(&crd.Status.Conditions, utils.BlueprintsReadyCondition(pending, crd.Generation))
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setFailedStatus marks the AkSource resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *AkSourceReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.AkSource, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of AkSource `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// akSourceLabels are the labels that mark a generated resource as belonging to the given AkSource
+func akSourceLabels(crd *akmv1a1.AkSource) map[string]string {
+	return map[string]string{
+		akSourceNameLabel:      crd.Name,
+		akSourceNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToAkSource maps a generated AkBlueprint back to the AkSource resource that generated it
+func (r *AkSourceReconciler) akBlueprintToAkSource(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, akSourceNameLabel, akSourceNamespaceLabel)
+}
+
+// secretToAkSources maps a secret to the AkSources in its namespace reading their credentials from it, so
+// rotated credentials are given to authentik
+func (r *AkSourceReconciler) secretToAkSources(ctx context.Context, obj client.Object) []reconcile.Request {
+	l := klog.FromContext(ctx)
+	sources := &akmv1a1.AkSourceList{}
+	err := r.List(ctx, sources, client.InNamespace(obj.GetNamespace()))
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to list AkSources reading secret `%v` in `%v`.", obj.GetName(), obj.GetNamespace()))
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, source := range sources.Items {
+		if source.Spec.OAuth != nil && source.Spec.OAuth.Credentials.Name == obj.GetName() {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: source.Name, Namespace: source.Namespace}})
+		}
+	}
+	return reqs
+}
+
+// getCredentials reads the client id and secret of an OAuth source from its secret
+func (r *AkSourceReconciler) getCredentials(ctx context.Context, crd *akmv1a1.AkSource) (clientID string, clientSecret string, err error) {
+	credentials := crd.Spec.OAuth.Credentials
+	idKey, secretKey := credentials.ClientIDKey, credentials.ClientSecretKey
+	if idKey == "" {
+		idKey = "clientID"
+	}
+	if secretKey == "" {
+		secretKey = "clientSecret"
+	}
+	secret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: credentials.Name, Namespace: crd.Namespace}, secret)
+	if err != nil {
+		return "", "", err
+	}
+	for _, key := range []string{idKey, secretKey} {
+		if len(secret.Data[key]) == 0 {
+			return "", "", fmt.Errorf("secret `%v` has no `%v` key", secret.Name, key)
+		}
+	}
+	return string(secret.Data[idKey]), string(secret.Data[secretKey]), nil
+}
+
+// credentialsHash is a hash of client credentials to tell when they change without keeping them
+func credentialsHash(clientID string, clientSecret string) string {
+	sum := sha256.Sum256([]byte(clientID + "\x00" + clientSecret))
+	return hex.EncodeToString(sum[:])
+}
+
+// oauthSourceFromAkSource is the OAuth source with its credentials as given to the API, flows are left to the
+// blueprint since the API refers to them by uuid rather than slug
+func oauthSourceFromAkSource(crd *akmv1a1.AkSource, clientID string, clientSecret string) *authentik.OAuthSource {
+	oauth := crd.Spec.OAuth
+	return &authentik.OAuthSource{
+		Name:             crd.Spec.Name,
+		Slug:             crd.Spec.Slug,
+		ProviderType:     oauth.ProviderType,
+		ConsumerKey:      clientID,
+		ConsumerSecret:   clientSecret,
+		UserMatchingMode: crd.Spec.UserMatchingMode,
+		AdditionalScopes: oauth.AdditionalScopes,
+		AuthorizationURL: oauth.AuthorizationURL,
+		AccessTokenURL:   oauth.AccessTokenURL,
+		ProfileURL:       oauth.ProfileURL,
+		OIDCWellKnownURL: oauth.OIDCWellKnownURL,
+		OIDCJWKSURL:      oauth.OIDCJWKSURL,
+	}
+}
+
+// syncCredentials gives the credentials to authentik with the OAuth source, saving their hash as soon as they are
+// accepted so retries after a later failure do not send them again. The rest of the status, including the Ready
+// condition, is still updated at the end of the reconcile since it is compared against the status before this.
+func (r *AkSourceReconciler) syncCredentials(ctx context.Context, akc *authentik.Client, crd *akmv1a1.AkSource, clientID string, clientSecret string) error {
+	hash := credentialsHash(clientID, clientSecret)
+	rotated := hash != crd.Status.CredentialsHash
+	err := syncOAuthSource(ctx, akc, oauthSourceFromAkSource(crd, clientID, clientSecret), rotated)
+	if err != nil || !rotated {
+		return err
+	}
+	crd.Status.CredentialsHash = hash
+	return r.Status().Update(ctx, crd)
+}
+
+// syncOAuthSource creates the OAuth source if it does not exist yet, or gives it the credentials if they rotated
+func syncOAuthSource(ctx context.Context, akc *authentik.Client, source *authentik.OAuthSource, rotated bool) error {
+	_, err := akc.GetOAuthSource(ctx, source.Slug)
+	if authentik.IsNotFound(err) {
+		_, err = akc.CreateOAuthSource(ctx, source)
+		return err
+	} else if err != nil || !rotated {
+		return err
+	}
+	_, err = akc.PatchOAuthSource(ctx, source)
+	return err
+}
+
+// akSourceBlueprint is the blueprint content of a source, without any client credentials
+func akSourceBlueprint(crd *akmv1a1.AkSource) *blueprint.Blueprint {
+	attrs := map[string]interface{}{
+		"name": crd.Spec.Name,
+		"slug": crd.Spec.Slug,
+	}
+	optional := map[string]string{
+		"user_matching_mode": crd.Spec.UserMatchingMode,
+	}
+	flows := map[string]string{
+		"authentication_flow": crd.Spec.AuthenticationFlow,
+		"enrollment_flow":     crd.Spec.EnrollmentFlow,
+	}
+	keypairs := map[string]string{}
+	switch {
+	case crd.Spec.Type == "oauth" && crd.Spec.OAuth != nil:
+		oauth := crd.Spec.OAuth
+		attrs["provider_type"] = oauth.ProviderType
+		optional["additional_scopes"] = oauth.AdditionalScopes
+		optional["authorization_url"] = oauth.AuthorizationURL
+		optional["access_token_url"] = oauth.AccessTokenURL
+		optional["profile_url"] = oauth.ProfileURL
+		optional["oidc_well_known_url"] = oauth.OIDCWellKnownURL
+		optional["oidc_jwks_url"] = oauth.OIDCJWKSURL
+	case crd.Spec.Type == "saml" && crd.Spec.SAML != nil:
+		saml := crd.Spec.SAML
+		attrs["sso_url"] = saml.SSOURL
+		attrs["allow_idp_initiated"] = saml.AllowIDPInitiated
+		optional["slo_url"] = saml.SLOURL
+		optional["issuer"] = saml.Issuer
+		optional["binding_type"] = saml.BindingType
+		preAuthentication := saml.PreAuthenticationFlow
+		if preAuthentication == "" {
+			preAuthentication = "default-source-pre-authentication"
+		}
+		flows["pre_authentication_flow"] = preAuthentication
+		keypairs["signing_kp"] = saml.SigningKeypair
+		keypairs["verification_kp"] = saml.VerificationKeypair
+	}
+	for attr, value := range optional {
+		if value != "" {
+			attrs[attr] = value
+		}
+	}
+	for attr, slug := range flows {
+		if slug != "" {
+			attrs[attr] = blueprint.Find("authentik_flows.flow", "slug", slug)
+		}
+	}
+	for attr, name := range keypairs {
+		if name != "" {
+			attrs[attr] = blueprint.Find("authentik_crypto.certificatekeypair", "name", name)
+		}
+	}
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       sourceModels[crd.Spec.Type],
+				State:       "present",
+				Identifiers: map[string]interface{}{"slug": crd.Spec.Slug},
+				Attrs:       attrs,
+			},
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AkSourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.AkSource{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToAkSource)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.secretToAkSources)).
+		Complete(r)
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	akfake "gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik/fake"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+func TestAkSourceBlueprint(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "keycloak-client", Namespace: "apps"},
+		Data: map[string][]byte{
+			"clientID":     []byte("authentik"),
+			"clientSecret": []byte("super-secret"),
+		},
+	}
+	crd := &akmv1a1.AkSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "partner", Namespace: "apps"},
+		Spec: akmv1a1.AkSourceSpec{
+			Instance:         akmv1a1.AuthentikInstance{Namespace: "auth"},
+			Name:             "Partner",
+			Slug:             "partner",
+			Type:             "oauth",
+			EnrollmentFlow:   "partner-enrollment",
+			UserMatchingMode: "email_link",
+			OAuth: &akmv1a1.AkSourceOAuth{
+				ProviderType:     "openidconnect",
+				Credentials:      akmv1a1.AkSourceCredentials{Name: "keycloak-client"},
+				OIDCWellKnownURL: "https://sso.partner.example/realms/main/.well-known/openid-configuration",
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret, crd).Build()
+	r := &AkSourceReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+
+	clientID, clientSecret, err := r.getCredentials(ctx, crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientID != "authentik" || clientSecret != "super-secret" {
+		t.Errorf("getCredentials() = %q, %q", clientID, clientSecret)
+	}
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "apps-source-partner", akSourceLabels(crd), akSourceBlueprint(crd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"model: authentik_sources_oauth.oauthsource",
+		"slug: partner",
+		"provider_type: openidconnect",
+		"user_matching_mode: email_link",
+		"enrollment_flow: !Find [authentik_flows.flow, [slug, partner-enrollment]]",
+		"oidc_well_known_url: https://sso.partner.example/realms/main/.well-known/openid-configuration",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	// credentials must never end up in the blueprint
+	for _, leak := range []string{"super-secret", "consumer_"} {
+		if strings.Contains(content, leak) {
+			t.Errorf("blueprint contains %q:\n%v", leak, content)
+		}
+	}
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated blueprint is invalid: %v", err)
+	}
+	reqs := r.akBlueprintToAkSource(ctx, bp)
+	if len(reqs) != 1 || reqs[0].Name != "partner" || reqs[0].Namespace != "apps" {
+		t.Fatalf("expected request for apps/partner, got %v", reqs)
+	}
+
+	// changes to the credentials secret reconcile the source again
+	reqs = r.secretToAkSources(ctx, secret)
+	if len(reqs) != 1 || reqs[0].Name != "partner" {
+		t.Fatalf("expected request for apps/partner, got %v", reqs)
+	}
+}
+
+func TestAkSourceSAMLBlueprint(t *testing.T) {
+	crd := &akmv1a1.AkSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "corp", Namespace: "apps"},
+		Spec: akmv1a1.AkSourceSpec{
+			Name: "Corp",
+			Slug: "corp",
+			Type: "saml",
+			SAML: &akmv1a1.AkSourceSAML{
+				SSOURL:         "https://idp.corp.example/sso",
+				BindingType:    "POST",
+				SigningKeypair: "my-signing-key",
+			},
+		},
+	}
+	attrs := akSourceBlueprint(crd).Entries[0].Attrs
+	for attr, want := range map[string]interface{}{
+		"sso_url":                 "https://idp.corp.example/sso",
+		"binding_type":            "POST",
+		"allow_idp_initiated":     false,
+		"signing_kp":              blueprint.Find("authentik_crypto.certificatekeypair", "name", "my-signing-key"),
+		"pre_authentication_flow": blueprint.Find("authentik_flows.flow", "slug", "default-source-pre-authentication"),
+	} {
+		if !reflect.DeepEqual(attrs[attr], want) {
+			t.Errorf("%v = %v, want %v", attr, attrs[attr], want)
+		}
+	}
+	if _, ok := attrs["verification_kp"]; ok {
+		t.Errorf("expected no verification keypair, got %v", attrs["verification_kp"])
+	}
+}
+
+func TestSyncOAuthSource(t *testing.T) {
+	ctx := context.Background()
+	srv := akfake.NewServer("token")
+	t.Cleanup(srv.Close)
+	akc, err := authentik.NewClient(srv.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crd := &akmv1a1.AkSource{
+		Spec: akmv1a1.AkSourceSpec{
+			Name:  "GitHub",
+			Slug:  "github",
+			Type:  "oauth",
+			OAuth: &akmv1a1.AkSourceOAuth{ProviderType: "github"},
+		},
+	}
+
+	// a missing source is created with its credentials
+	if err := syncOAuthSource(ctx, akc, oauthSourceFromAkSource(crd, "id", "first"), false); err != nil {
+		t.Fatal(err)
+	}
+	sources := srv.Objects("sources/oauth")
+	if len(sources) != 1 || sources[0]["consumer_secret"] != "first" || sources[0]["provider_type"] != "github" {
+		t.Fatalf("unexpected sources %v", sources)
+	}
+	// credentials are only sent again once they rotate
+	if err := syncOAuthSource(ctx, akc, oauthSourceFromAkSource(crd, "id", "second"), false); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Objects("sources/oauth")[0]["consumer_secret"]; got != "first" {
+		t.Errorf("consumer_secret = %v, want first", got)
+	}
+	if err := syncOAuthSource(ctx, akc, oauthSourceFromAkSource(crd, "id", "second"), true); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Objects("sources/oauth")[0]["consumer_secret"]; got != "second" {
+		t.Errorf("consumer_secret = %v, want second", got)
+	}
+	if credentialsHash("id", "first") == credentialsHash("id", "second") {
+		t.Error("expected different credentials to hash differently")
+	}
+}
+
+func TestSyncCredentials(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	crd := &akmv1a1.AkSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "github", Namespace: "apps"},
+		Spec: akmv1a1.AkSourceSpec{
+			Name:  "GitHub",
+			Slug:  "github",
+			Type:  "oauth",
+			OAuth: &akmv1a1.AkSourceOAuth{ProviderType: "github"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(crd).WithStatusSubresource(crd).Build()
+	r := &AkSourceReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+	srv := akfake.NewServer("token")
+	t.Cleanup(srv.Close)
+	akc, err := authentik.NewClient(srv.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fetch is the AkSource as a retried reconcile would see it
+	fetch := func() *akmv1a1.AkSource {
+		got := &akmv1a1.AkSource{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(crd), got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	patches := func() int {
+		n := 0
+		for _, req := range srv.Requests() {
+			if strings.HasPrefix(req, "PATCH") {
+				n++
+			}
+		}
+		return n
+	}
+
+	if err := r.syncCredentials(ctx, akc, fetch(), "id", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fetch().Status.CredentialsHash; got != credentialsHash("id", "first") {
+		t.Fatalf("expected the hash to be saved once the source is created, got %q", got)
+	}
+	// rotated credentials are sent once, even if the reconcile is retried after a later failure
+	if err := r.syncCredentials(ctx, akc, fetch(), "id", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.syncCredentials(ctx, akc, fetch(), "id", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if n := patches(); n != 1 {
+		t.Errorf("expected rotated credentials to be patched once, got %v patches", n)
+	}
+	if got := srv.Objects("sources/oauth")[0]["consumer_secret"]; got != "second" {
+		t.Errorf("consumer_secret = %v, want second", got)
+	}
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the AkUser resource that generated them.
+const (
+	akUserNameLabel      = "akm.goauthentik.io/akuser"
+	akUserNamespaceLabel = "akm.goauthentik.io/akuser-namespace"
+)
+
+// tokenRotatedAtAnnotation records on the token secret when the token in it was generated
+const tokenRotatedAtAnnotation = "akm.goauthentik.io/token-rotated-at"
+
+// AkUserReconciler reconciles a AkUser object
+type AkUserReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akusers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akusers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=akusers/finalizers,verbs=update
+
+// Reconcile turns an AkUser resource into an AkBlueprint of the user, and its token for service accounts, in the
+// authentik namespace. Tokens are written to a secret in its own namespace and replaced on the rotation schedule,
+// their keys are given to authentik through the API rather than the blueprint.
+func (r *AkUserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.AkUser{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("AkUser resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get AkUser resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found AkUser resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("AkUser resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, akUserLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+
+	// TOKEN - only service accounts get a token, generated into a secret and rotated on schedule
+	var token *corev1.Secret
+	var rotatedAt time.Time
+	result := ctrl.Result{}
+	if crd.Spec.Token != nil {
+		if crd.Spec.Type != "service_account" {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "InvalidSpec", fmt.Errorf("only service accounts can have a token, type is `%v`", crd.Spec.Type))
+		}
+		token, rotatedAt, err = r.reconcileTokenSecret(ctx, crd, time.Now())
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "SecretFailed", err)
+		}
+		crd.Status.Secret = token.Name
+		crd.Status.TokenRotatedAt = &metav1.Time{Time: rotatedAt}
+		if rotation := crd.Spec.Token.Rotation; rotation != nil && rotation.Duration > 0 {
+			result.RequeueAfter = time.Until(rotatedAt.Add(rotation.Duration))
+		}
+	} else {
+		crd.Status.Secret = ""
+		crd.Status.TokenRotatedAt = nil
+		crd.Status.TokenHash = ""
+	}
+
+	// BLUEPRINT
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-user-%v", crd.Namespace, crd.Name), akUserLabels(crd), akUserBlueprint(crd, token != nil, rotatedAt))
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+	crd.Status.AkBlueprint = fmt.Sprintf("%v/%v", bp.Namespace, bp.Name)
+	pending := []string{}
+	if !meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady) {
+		pending = append(pending, crd.Status.AkBlueprint)
+	}
+
+	// TOKEN KEY - set once the blueprint has created the token, and again whenever it rotates
+	if token != nil && len(pending) == 0 {
+		hash := tokenHash(token)
+		if hash != crd.Status.TokenHash {
+			akc, err := r.NewAuthentikClient(ctx, ak)
+			if err != nil {
+				return ctrl.Result{}, r.setFailedStatus(ctx, crd, "TokenFailed", err)
+			}
+			err = setTokenKey(ctx, akc, crd, token)
+			if err != nil {
+				return ctrl.Result{}, r.setFailedStatus(ctx, crd, "TokenFailed", err)
+			}
+			crd.Status.TokenHash = hash
+		}
+	}
+
+	// STATUS
+	meta.SetStatusCondition(&crd.Status.Conditions, utils.BlueprintsReadyCondition(pending, crd.Generation))
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// setFailedStatus marks the AkUser resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *AkUserReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.AkUser, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of AkUser `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// akUserLabels are the labels that mark a generated resource as belonging to the given AkUser
+func akUserLabels(crd *akmv1a1.AkUser) map[string]string {
+	return map[string]string{
+		akUserNameLabel:      crd.Name,
+		akUserNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToAkUser maps a generated AkBlueprint back to the AkUser resource that generated it
+func (r *AkUserReconciler) akBlueprintToAkUser(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, akUserNameLabel, akUserNamespaceLabel)
+}
+
+// akUserTokenIdentifier is the identifier of the token in authentik, which must be unique across namespaces
+func akUserTokenIdentifier(crd *akmv1a1.AkUser) string {
+	return fmt.Sprintf("akm-%v-%v", crd.Namespace, crd.Name)
+}
+
+// tokenRotatedAt is when the token in the secret was generated, zero if unknown
+func tokenRotatedAt(secret *corev1.Secret) time.Time {
+	rotatedAt, err := time.Parse(time.RFC3339, secret.Annotations[tokenRotatedAtAnnotation])
+	if err != nil {
+		return time.Time{}
+	}
+	return rotatedAt
+}
+
+// tokenDue is whether the token in the secret is missing or older than the rotation period at the given time
+func tokenDue(secret *corev1.Secret, rotation *metav1.Duration, now time.Time) bool {
+	if len(secret.Data["token"]) == 0 {
+		return true
+	}
+	if rotation == nil || rotation.Duration <= 0 {
+		return false
+	}
+	return !now.Before(tokenRotatedAt(secret).Add(rotation.Duration))
+}
+
+// tokenHash is a hash of the token in the secret, to notice when it rotates without keeping the token itself
+func tokenHash(secret *corev1.Secret) string {
+	sum := sha256.Sum256(secret.Data["token"])
+	return hex.EncodeToString(sum[:])
+}
+
+// setTokenKey gives the token in the secret to authentik as the key of the token the blueprint created
+func setTokenKey(ctx context.Context, akc *authentik.Client, crd *akmv1a1.AkUser, secret *corev1.Secret) error {
+	return akc.SetTokenKey(ctx, akUserTokenIdentifier(crd), string(secret.Data["token"]))
+}
+
+// reconcileTokenSecret creates the token secret, or generates a new token into it when due for rotation,
+// returning the secret and when its token was generated
+func (r *AkUserReconciler) reconcileTokenSecret(ctx context.Context, crd *akmv1a1.AkUser, now time.Time) (*corev1.Secret, time.Time, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: crd.Spec.Token.Secret.Name, Namespace: crd.Namespace}, secret)
+	if errors.IsNotFound(err) {
+		secret = r.secretFromAkUser(crd, now)
+		return secret, now, r.Create(ctx, secret)
+	} else if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !tokenDue(secret, crd.Spec.Token.Rotation, now) && string(secret.Data["username"]) == crd.UserName() {
+		return secret, tokenRotatedAt(secret), nil
+	}
+	desired := r.secretFromAkUser(crd, now)
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[tokenRotatedAtAnnotation] = desired.Annotations[tokenRotatedAtAnnotation]
+	secret.Data = desired.Data
+	return secret, now, r.Update(ctx, secret)
+}
+
+// secretFromAkUser creates a secret specification holding the username and a newly generated token with
+// controller references
+func (r *AkUserReconciler) secretFromAkUser(crd *akmv1a1.AkUser, now time.Time) *corev1.Secret {
+	annotations := map[string]string{}
+	for k, v := range crd.Annotations {
+		annotations[k] = v
+	}
+	annotations[tokenRotatedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        crd.Spec.Token.Secret.Name,
+			Namespace:   crd.Namespace,
+			Annotations: annotations,
+		},
+		Data: map[string][]byte{
+			"username": []byte(crd.UserName()),
+			"token":    []byte(utils.GenerateRandomString(64, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")),
+		},
+	}
+	ctrl.SetControllerReference(crd, secret, r.Scheme)
+	return secret
+}
+
+// akUserBlueprint is the blueprint content of a user, with its token if it has one. Only the token metadata is
+// included since its key is set through the API. Rotated tokens expire a full rotation period after they are due to
+// be replaced, so they survive a late rotation.
+func akUserBlueprint(crd *akmv1a1.AkUser, token bool, rotatedAt time.Time) *blueprint.Blueprint {
+	attrs := map[string]interface{}{
+		"username": crd.UserName(),
+		"name":     crd.Spec.Name,
+		"type":     crd.Spec.Type,
+	}
+	if crd.Spec.Email != "" {
+		attrs["email"] = crd.Spec.Email
+	}
+	if crd.Spec.Path != "" {
+		attrs["path"] = crd.Spec.Path
+	}
+	if crd.Spec.Attributes != nil {
+		attrs["attributes"] = crd.Spec.Attributes
+	}
+	if len(crd.Spec.Groups) > 0 {
+		groups := []interface{}{}
+		for _, group := range crd.Spec.Groups {
+			groups = append(groups, blueprint.Find("authentik_core.group", "name", group))
+		}
+		attrs["groups"] = groups
+	}
+	entries := []blueprint.Entry{
+		{
+			Model:       "authentik_core.user",
+			State:       "present",
+			ID:          "user",
+			Identifiers: map[string]interface{}{"username": crd.UserName()},
+			Attrs:       attrs,
+		},
+	}
+	if token {
+		tokenAttrs := map[string]interface{}{
+			"identifier":  akUserTokenIdentifier(crd),
+			"user":        blueprint.KeyOf("user"),
+			"intent":      crd.Spec.Token.Intent,
+			"description": fmt.Sprintf("Managed by AkUser %v/%v", crd.Namespace, crd.Name),
+			"expiring":    false,
+		}
+		if rotation := crd.Spec.Token.Rotation; rotation != nil && rotation.Duration > 0 {
+			tokenAttrs["expiring"] = true
+			tokenAttrs["expires"] = rotatedAt.Add(2 * rotation.Duration).UTC().Format(time.RFC3339)
+		}
+		entries = append(entries, blueprint.Entry{
+			Model:       "authentik_core.token",
+			State:       "present",
+			Identifiers: map[string]interface{}{"identifier": akUserTokenIdentifier(crd)},
+			Attrs:       tokenAttrs,
+		})
+	}
+	return &blueprint.Blueprint{Version: 1, Entries: entries}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AkUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.AkUser{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&corev1.Secret{}).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToAkUser)).
+		Complete(r)
+}
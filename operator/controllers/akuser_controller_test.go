@@ -0,0 +1,195 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	akfake "gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik/fake"
+)
+
+func newTestAkUser() *akmv1a1.AkUser {
+	return &akmv1a1.AkUser{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci", Namespace: "apps", UID: "1234"},
+		Spec: akmv1a1.AkUserSpec{
+			Instance: akmv1a1.AuthentikInstance{Namespace: "auth"},
+			Name:     "CI Bot",
+			Type:     "service_account",
+			Path:     "goauthentik.io/service-accounts",
+			Groups:   []string{"deployers"},
+			Token: &akmv1a1.AkUserToken{
+				Secret:   corev1.LocalObjectReference{Name: "ci-token"},
+				Intent:   "api",
+				Rotation: &metav1.Duration{Duration: 24 * time.Hour},
+			},
+		},
+	}
+}
+
+func newTestAkUserReconciler(t *testing.T) *AkUserReconciler {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	return &AkUserReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+}
+
+func TestAkUserBlueprint(t *testing.T) {
+	ctx := context.TODO()
+	r := newTestAkUserReconciler(t)
+	crd := newTestAkUser()
+	rotatedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "apps-user-ci", akUserLabels(crd), akUserBlueprint(crd, true, rotatedAt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"model: authentik_core.user",
+		"username: ci",
+		"type: service_account",
+		"path: goauthentik.io/service-accounts",
+		"- !Find [authentik_core.group, [name, deployers]]",
+		"model: authentik_core.token",
+		"identifier: akm-apps-ci",
+		"user: !KeyOf user",
+		"expires: \"2024-01-03T00:00:00Z\"",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	if strings.Contains(content, "key:") {
+		t.Errorf("blueprint must not contain the token key:\n%v", content)
+	}
+	// the generated blueprint must also pass admission
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated blueprint is invalid: %v", err)
+	}
+	reqs := r.akBlueprintToAkUser(ctx, bp)
+	if len(reqs) != 1 || reqs[0].Name != "ci" || reqs[0].Namespace != "apps" {
+		t.Fatalf("expected request for apps/ci, got %v", reqs)
+	}
+}
+
+func TestSetTokenKey(t *testing.T) {
+	ctx := context.Background()
+	srv := akfake.NewServer("token")
+	t.Cleanup(srv.Close)
+	akc, err := authentik.NewClient(srv.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	srv.HandleFunc("core/tokens/akm-apps-ci/set_key", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	crd := newTestAkUser()
+	first := &corev1.Secret{Data: map[string][]byte{"token": []byte("first")}}
+	second := &corev1.Secret{Data: map[string][]byte{"token": []byte("second")}}
+
+	if err := setTokenKey(ctx, akc, crd, first); err != nil {
+		t.Fatal(err)
+	}
+	if got["key"] != "first" {
+		t.Errorf("key = %q, want first", got["key"])
+	}
+	if tokenHash(first) == tokenHash(second) {
+		t.Error("expected different tokens to hash differently")
+	}
+}
+
+func TestReconcileTokenSecret(t *testing.T) {
+	ctx := context.TODO()
+	r := newTestAkUserReconciler(t)
+	crd := newTestAkUser()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	secret, rotatedAt, err := r.reconcileTokenSecret(ctx, crd, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := string(secret.Data["token"])
+	if len(token) != 64 || !rotatedAt.Equal(now) {
+		t.Fatalf("expected a generated token at %v, got %q at %v", now, token, rotatedAt)
+	}
+
+	// before the rotation period the token is kept
+	secret, rotatedAt, err = r.reconcileTokenSecret(ctx, crd, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["token"]) != token || !rotatedAt.Equal(now) {
+		t.Error("expected the token to be kept")
+	}
+
+	// after it a new token is generated
+	later := now.Add(25 * time.Hour)
+	secret, rotatedAt, err = r.reconcileTokenSecret(ctx, crd, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["token"]) == token || !rotatedAt.Equal(later) {
+		t.Error("expected the token to be rotated")
+	}
+}
+
+func TestTokenDue(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := &metav1.Duration{Duration: 24 * time.Hour}
+	generated := func(at time.Time) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{tokenRotatedAtAnnotation: at.Format(time.RFC3339)}},
+			Data:       map[string][]byte{"token": []byte("s3cr3t")},
+		}
+	}
+	tests := map[string]struct {
+		secret   *corev1.Secret
+		rotation *metav1.Duration
+		want     bool
+	}{
+		"missing token":      {secret: &corev1.Secret{}, rotation: nil, want: true},
+		"never rotated":      {secret: generated(now.Add(-1000 * time.Hour)), rotation: nil, want: false},
+		"within rotation":    {secret: generated(now.Add(-time.Hour)), rotation: day, want: false},
+		"past rotation":      {secret: generated(now.Add(-25 * time.Hour)), rotation: day, want: true},
+		"unknown generation": {secret: &corev1.Secret{Data: map[string][]byte{"token": []byte("s3cr3t")}}, rotation: day, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tokenDue(tt.secret, tt.rotation, now); got != tt.want {
+				t.Errorf("tokenDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
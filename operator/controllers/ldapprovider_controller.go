@@ -0,0 +1,348 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the LDAPProvider resource that generated them.
+const (
+	ldapNameLabel      = "akm.goauthentik.io/ldapprovider"
+	ldapNamespaceLabel = "akm.goauthentik.io/ldapprovider-namespace"
+)
+
+// LDAPProviderReconciler reconciles a LDAPProvider object
+type LDAPProviderReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=ldapproviders,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=ldapproviders/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=ldapproviders/finalizers,verbs=update
+
+// Reconcile turns an LDAPProvider resource into AkBlueprints in the authentik namespace for the provider with its
+// bind user, the application, and the outpost serving them, and a secret of bind credentials in its own namespace.
+func (r *LDAPProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.LDAPProvider{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("LDAPProvider resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get LDAPProvider resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found LDAPProvider resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("LDAPProvider resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, ldapLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+	crd.Status.Blueprints = []akmv1a1.LDAPBlueprintStatus{}
+
+	// SECRET - bind credentials for the consuming application, the password is kept once generated
+	secret, err := r.reconcileSecret(ctx, crd)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "SecretFailed", err)
+	}
+	crd.Status.Secret = secret.Name
+	crd.Status.Outpost = ldapOutpostName(crd)
+
+	// BLUEPRINTS - provider and bind user, application, then the outpost serving the provider
+	blueprints := []struct {
+		name    string
+		content *blueprint.Blueprint
+	}{
+		{fmt.Sprintf("%v-ldap-provider-%v", crd.Namespace, crd.Name), ldapProviderBlueprint(crd, string(secret.Data["bindPassword"]))},
+		{fmt.Sprintf("%v-ldap-app-%v", crd.Namespace, crd.Name), ldapApplicationBlueprint(crd)},
+		{fmt.Sprintf("%v-ldap-outpost-%v", crd.Namespace, crd.Name), ldapOutpostBlueprint(crd)},
+	}
+	names := []string{}
+	for _, generated := range blueprints {
+		names = append(names, generated.name)
+		bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, generated.name, ldapLabels(crd), generated.content)
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+		}
+		crd.Status.Blueprints = append(crd.Status.Blueprints, akmv1a1.LDAPBlueprintStatus{
+			AkBlueprint:      fmt.Sprintf("%v/%v", bp.Namespace, bp.Name),
+			BlueprintApplied: meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady),
+		})
+	}
+
+	// PRUNE - blueprints this version of the operator no longer generates
+	err = r.DeleteGeneratedBlueprints(ctx, ak.Namespace, ldapLabels(crd), names...)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+
+	// STATUS
+	setLDAPReadyCondition(&crd.Status, crd.Generation)
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setLDAPReadyCondition sets the Ready condition based on whether every generated blueprint has been applied
+func setLDAPReadyCondition(status *akmv1a1.LDAPProviderStatus, generation int64) {
+	pending := []string{}
+	for _, bp := range status.Blueprints {
+		if !bp.BlueprintApplied {
+			pending = append(pending, bp.AkBlueprint)
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, utils.BlueprintsReadyCondition(pending, generation))
+}
+
+// setFailedStatus marks the LDAPProvider resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *LDAPProviderReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.LDAPProvider, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of LDAPProvider `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// ldapLabels are the labels that mark a generated resource as belonging to the given LDAPProvider
+func ldapLabels(crd *akmv1a1.LDAPProvider) map[string]string {
+	return map[string]string{
+		ldapNameLabel:      crd.Name,
+		ldapNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToLDAPProvider maps a generated AkBlueprint back to the LDAPProvider resource that generated it
+func (r *LDAPProviderReconciler) akBlueprintToLDAPProvider(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, ldapNameLabel, ldapNamespaceLabel)
+}
+
+// ldapProviderName is the name of the provider in authentik, which must be unique across namespaces
+func ldapProviderName(crd *akmv1a1.LDAPProvider) string {
+	return fmt.Sprintf("ldap-%v-%v", crd.Namespace, crd.Name)
+}
+
+// ldapBindUsername is the username of the service account the consuming application binds as
+func ldapBindUsername(crd *akmv1a1.LDAPProvider) string {
+	return fmt.Sprintf("ldap-bind-%v-%v", crd.Namespace, crd.Name)
+}
+
+// ldapOutpostName is the name of the outpost serving the provider
+func ldapOutpostName(crd *akmv1a1.LDAPProvider) string {
+	if crd.Spec.Outpost.Name != "" {
+		return crd.Spec.Outpost.Name
+	}
+	return ldapProviderName(crd)
+}
+
+// ldapBindDN is the distinguished name of the bind user as the outpost serves it
+func ldapBindDN(crd *akmv1a1.LDAPProvider) string {
+	return fmt.Sprintf("cn=%v,ou=users,%v", ldapBindUsername(crd), crd.Spec.BaseDN)
+}
+
+// reconcileSecret creates the bind credentials secret, or updates its DNs while keeping the password it already holds
+func (r *LDAPProviderReconciler) reconcileSecret(ctx context.Context, crd *akmv1a1.LDAPProvider) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: crd.Spec.Secret.Name, Namespace: crd.Namespace}, secret)
+	if errors.IsNotFound(err) {
+		secret = r.secretFromLDAPProvider(crd, "")
+		return secret, r.Create(ctx, secret)
+	} else if err != nil {
+		return nil, err
+	}
+	desired := r.secretFromLDAPProvider(crd, string(secret.Data["bindPassword"]))
+	if equality.Semantic.DeepEqual(secret.Data, desired.Data) {
+		return secret, nil
+	}
+	secret.Data = desired.Data
+	return secret, r.Update(ctx, secret)
+}
+
+// secretFromLDAPProvider creates a secret specification holding the bind credentials with controller references,
+// generating a password if none is given
+func (r *LDAPProviderReconciler) secretFromLDAPProvider(crd *akmv1a1.LDAPProvider, password string) *corev1.Secret {
+	if password == "" {
+		password = utils.GenerateRandomString(64, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        crd.Spec.Secret.Name,
+			Namespace:   crd.Namespace,
+			Annotations: crd.Annotations,
+		},
+		Data: map[string][]byte{
+			"bindDN":       []byte(ldapBindDN(crd)),
+			"bindPassword": []byte(password),
+			"baseDN":       []byte(crd.Spec.BaseDN),
+		},
+	}
+	ctrl.SetControllerReference(crd, secret, r.Scheme)
+	return secret
+}
+
+// ldapProviderBlueprint is the blueprint content of the LDAP provider and the service account the consuming
+// application binds as, optional settings are only set when given so authentik keeps its own defaults.
+func ldapProviderBlueprint(crd *akmv1a1.LDAPProvider, password string) *blueprint.Blueprint {
+	spec := crd.Spec
+	attrs := map[string]interface{}{
+		"name":               ldapProviderName(crd),
+		"authorization_flow": blueprint.Find("authentik_flows.flow", "slug", spec.BindFlow),
+		"base_dn":            spec.BaseDN,
+		"bind_mode":          spec.BindMode,
+		"tls_server_name":    spec.TLSServerName,
+	}
+	if spec.Certificate != "" {
+		attrs["certificate"] = blueprint.Find("authentik_crypto.certificatekeypair", "name", spec.Certificate)
+	}
+	user := map[string]interface{}{
+		"username": ldapBindUsername(crd),
+		"name":     fmt.Sprintf("LDAP bind user of %v/%v", crd.Namespace, crd.Name),
+		"type":     "service_account",
+		"password": password,
+	}
+	if spec.SearchGroup != "" {
+		group := blueprint.Find("authentik_core.group", "name", spec.SearchGroup)
+		attrs["search_group"] = group
+		user["groups"] = []interface{}{group}
+	}
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       "authentik_providers_ldap.ldapprovider",
+				State:       "present",
+				Identifiers: map[string]interface{}{"name": ldapProviderName(crd)},
+				Attrs:       attrs,
+			},
+			{
+				Model:       "authentik_core.user",
+				State:       "present",
+				Identifiers: map[string]interface{}{"username": ldapBindUsername(crd)},
+				Attrs:       user,
+			},
+		},
+	}
+}
+
+// ldapApplicationBlueprint is the blueprint content of the application using the LDAP provider
+func ldapApplicationBlueprint(crd *akmv1a1.LDAPProvider) *blueprint.Blueprint {
+	application := crd.Spec.Application
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       "authentik_core.application",
+				State:       "present",
+				Identifiers: map[string]interface{}{"slug": application.Slug},
+				Attrs: map[string]interface{}{
+					"name":               application.Name,
+					"slug":               application.Slug,
+					"group":              application.Group,
+					"policy_engine_mode": application.PolicyEngineMode,
+					"provider":           blueprint.Find("authentik_providers_ldap.ldapprovider", "name", ldapProviderName(crd)),
+				},
+			},
+		},
+	}
+}
+
+// ldapOutpostBlueprint is the blueprint content of the LDAP outpost serving the provider
+func ldapOutpostBlueprint(crd *akmv1a1.LDAPProvider) *blueprint.Blueprint {
+	attrs := map[string]interface{}{
+		"name":      ldapOutpostName(crd),
+		"type":      "ldap",
+		"providers": []interface{}{blueprint.Find("authentik_providers_ldap.ldapprovider", "name", ldapProviderName(crd))},
+	}
+	if crd.Spec.Outpost.ServiceConnection != "" {
+		attrs["service_connection"] = blueprint.Find("authentik_outposts.kubernetesserviceconnection", "name", crd.Spec.Outpost.ServiceConnection)
+	}
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       "authentik_outposts.outpost",
+				State:       "present",
+				Identifiers: map[string]interface{}{"name": ldapOutpostName(crd)},
+				Attrs:       attrs,
+			},
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *LDAPProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.LDAPProvider{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToLDAPProvider)).
+		Complete(r)
+}
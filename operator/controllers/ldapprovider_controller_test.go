@@ -0,0 +1,152 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+func newTestLDAPProvider() *akmv1a1.LDAPProvider {
+	return &akmv1a1.LDAPProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: "wiki", Namespace: "apps", UID: "1234"},
+		Spec: akmv1a1.LDAPProviderSpec{
+			Instance:    akmv1a1.AuthentikInstance{Namespace: "auth"},
+			BaseDN:      "DC=ldap,DC=org,DC=example",
+			SearchGroup: "ldap-search",
+			BindFlow:    "default-authentication-flow",
+			BindMode:    "cached",
+			Secret:      corev1.LocalObjectReference{Name: "wiki-ldap"},
+			Application: akmv1a1.LDAPApplication{Name: "Wiki", Slug: "wiki", PolicyEngineMode: "any"},
+			Outpost:     akmv1a1.LDAPOutpost{ServiceConnection: "Local Kubernetes Cluster"},
+		},
+	}
+}
+
+func TestLDAPProviderBlueprints(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := &LDAPProviderReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+	crd := newTestLDAPProvider()
+
+	tests := map[string]struct {
+		content *blueprint.Blueprint
+		want    []string
+	}{
+		"provider": {
+			content: ldapProviderBlueprint(crd, "hunter2"),
+			want: []string{
+				"model: authentik_providers_ldap.ldapprovider",
+				"name: ldap-apps-wiki",
+				"base_dn: DC=ldap,DC=org,DC=example",
+				"bind_mode: cached",
+				"search_group: !Find [authentik_core.group, [name, ldap-search]]",
+				"username: ldap-bind-apps-wiki",
+				"password: hunter2",
+			},
+		},
+		"application": {
+			content: ldapApplicationBlueprint(crd),
+			want:    []string{"slug: wiki", "provider: !Find [authentik_providers_ldap.ldapprovider, [name, ldap-apps-wiki]]"},
+		},
+		"outpost": {
+			content: ldapOutpostBlueprint(crd),
+			want: []string{
+				"model: authentik_outposts.outpost",
+				"type: ldap",
+				"- !Find [authentik_providers_ldap.ldapprovider, [name, ldap-apps-wiki]]",
+				"service_connection: !Find [authentik_outposts.kubernetesserviceconnection, [name, Local Kubernetes Cluster]]",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bp, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "apps-ldap-"+name+"-wiki", ldapLabels(crd), tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := blueprintContent(bp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(content, want) {
+					t.Errorf("blueprint does not contain %q:\n%v", want, content)
+				}
+			}
+			// the generated blueprints must also pass admission
+			if err := bp.Validate(); err != nil {
+				t.Errorf("generated blueprint is invalid: %v", err)
+			}
+			reqs := r.akBlueprintToLDAPProvider(ctx, bp)
+			if len(reqs) != 1 || reqs[0].Name != "wiki" || reqs[0].Namespace != "apps" {
+				t.Fatalf("expected request for apps/wiki, got %v", reqs)
+			}
+		})
+	}
+}
+
+func TestReconcileLDAPSecret(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := &LDAPProviderReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+	crd := newTestLDAPProvider()
+
+	secret, err := r.reconcileSecret(ctx, crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := string(secret.Data["bindPassword"])
+	if len(password) != 64 {
+		t.Errorf("expected a generated password, got %q", password)
+	}
+	if got := string(secret.Data["bindDN"]); got != "cn=ldap-bind-apps-wiki,ou=users,DC=ldap,DC=org,DC=example" {
+		t.Errorf("bindDN = %q", got)
+	}
+
+	// changing the base DN updates the DNs but keeps the password
+	crd.Spec.BaseDN = "DC=org,DC=example"
+	secret, err = r.reconcileSecret(ctx, crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["bindPassword"]) != password {
+		t.Error("expected the password to be kept")
+	}
+	if got := string(secret.Data["baseDN"]); got != "DC=org,DC=example" {
+		t.Errorf("baseDN = %q", got)
+	}
+}
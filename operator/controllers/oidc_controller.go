@@ -13,25 +13,40 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/alexflint/go-arg"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
 	akmv1alpha1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
 	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
 	uhelm "gitlab.com/GeorgeRaven/authentik-manager/operator/utils/helm"
-	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/raw"
-	yaml_v3 "gopkg.in/yaml.v3"
 )
 
 // Statically bundled templaes to ensure they are available in binaries
 
+// Labels placed on generated AkBlueprints so they can be traced back to the OIDC resource that generated them.
+// Owner references cannot be used since the AkBlueprints live in the authentik namespace not the OIDC namespace.
+const (
+	oidcNameLabel      = "akm.goauthentik.io/oidc"
+	oidcNamespaceLabel = "akm.goauthentik.io/oidc-namespace"
+)
+
 // OIDCReconciler reconciles a OIDC object
 type OIDCReconciler struct {
 	utils.ControlBase
@@ -55,7 +70,7 @@ func (r *OIDCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	err := r.Get(ctx, req.NamespacedName, crd)
 	if err != nil {
 		if errors.IsNotFound(err) {
-			l.Info("OIDC resource reconciliation triggered but disappeared. Uninstalling OIDC integration.")
+			l.Info("OIDC resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -70,6 +85,14 @@ func (r *OIDCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		l.Info(fmt.Sprintf("OIDC resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
 		return ctrl.Result{}, nil
 	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, oidcLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
 	aks, err := r.ListAk(o.OperatorNamespace)
 	if err != nil {
 		l.Error(err, "Failed to get Authentik instance. Retrying.")
@@ -83,47 +106,224 @@ func (r *OIDCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	}
 	ak := aks[0]
 
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+	crd.Status.Providers = []akmv1a1.OIDCProviderStatus{}
+	crd.Status.Applications = []akmv1a1.OIDCApplicationStatus{}
+	generated := []string{}
+
+	// FLOWS - providers find their flows by slug when authentik applies their blueprints
+	// so check they exist first to report which are missing rather than a failed blueprint
+	missing, err := r.findMissingFlows(ctx, ak, crd)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "FlowCheckFailed", err)
+	}
+	if len(missing) > 0 {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "FlowNotFound", fmt.Errorf("flows `%v` do not exist", strings.Join(missing, "`, `")))
+	}
+
 	// PROVIDERS - generate secret and blueprint for each provider
 	// secret contains clientID and clientSecret
 	for i := range crd.Spec.Providers {
 		provider := &crd.Spec.Providers[i]
-		fmt.Printf("provider: %v\n", provider.Name)
+		l.V(1).Info(fmt.Sprintf("Reconciling OIDC provider `%v`.", provider.Name))
 		secret, err := r.spawnAndFetchOIDCSecret(ctx, crd, provider)
 		if err != nil {
-			return ctrl.Result{}, err
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "SecretFailed", err)
 		}
-		fmt.Printf("secret: %v\n", secret)
 		// ensure clientID and clientSecret are back into provider
 		provider.ProtocolSettings.ClientID = string(secret.Data["clientID"])
 		provider.ProtocolSettings.ClientSecret = string(secret.Data["clientSecret"])
 		provider_blueprint, err := r.reconcileProviderBlueprint(ak, ctx, crd, provider)
 		if err != nil {
-			return ctrl.Result{}, err
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
 		}
-		fmt.Printf("privider blueprint: %v\n", provider_blueprint)
+		generated = append(generated, provider_blueprint.Name)
+		crd.Status.Providers = append(crd.Status.Providers, akmv1a1.OIDCProviderStatus{
+			Name:             provider.Name,
+			Secret:           secret.Name,
+			AkBlueprint:      fmt.Sprintf("%v/%v", provider_blueprint.Namespace, provider_blueprint.Name),
+			BlueprintApplied: meta.IsStatusConditionTrue(provider_blueprint.Status.Conditions, akmv1a1.ConditionReady),
+		})
 	}
 
 	// APPLICATIONS - generate configmap and blueprint for each application
 	// config contains urls for login, profile, logout, well-known, etc
 	for i := range crd.Spec.Applications {
 		application := &crd.Spec.Applications[i]
-		fmt.Printf("application: %v\n", application.Name)
+		l.V(1).Info(fmt.Sprintf("Reconciling OIDC application `%v`.", application.Slug))
 		configmap, err := r.reconcileConfigmap(ak, ctx, crd, application)
 		if err != nil {
-			return ctrl.Result{}, err
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "ConfigMapFailed", err)
+		}
+		groups, err := r.resolveAccessGroups(ctx, crd, application)
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "AccessGroupsFailed", err)
+		}
+		application_blueprint, err := r.reconcileApplicationBlueprint(ak, ctx, crd, application, groups)
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
 		}
-		fmt.Printf("configmap: %v\n", configmap)
-		application_blueprint, err := r.reconcileApplicationBlueprint(ak, ctx, crd, application)
+		generated = append(generated, application_blueprint.Name)
+		crd.Status.Applications = append(crd.Status.Applications, akmv1a1.OIDCApplicationStatus{
+			Slug:             application.Slug,
+			ConfigMap:        configmap.Name,
+			AkBlueprint:      fmt.Sprintf("%v/%v", application_blueprint.Namespace, application_blueprint.Name),
+			BlueprintApplied: meta.IsStatusConditionTrue(application_blueprint.Status.Conditions, akmv1a1.ConditionReady),
+		})
+	}
+
+	// PRUNE - blueprints of providers and applications no longer in the spec
+	err = r.DeleteGeneratedBlueprints(ctx, ak.Namespace, oidcLabels(crd), generated...)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+
+	// STATUS
+	// we are only ready once authentik has applied every blueprint we generated
+	// changes to the AkBlueprints status will trigger us again so no need to poll
+	setOIDCReadyCondition(&crd.Status, crd.Generation)
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
-		fmt.Printf("application blueprint: %v\n", application_blueprint)
 	}
-	//TODO: add live testing of OIDC status by operator and locking system to prevent binding to non-functioning OIDC
 
 	return ctrl.Result{}, nil
 }
 
+// setOIDCReadyCondition sets the Ready condition based on whether every generated blueprint has been applied
+func setOIDCReadyCondition(status *akmv1a1.OIDCStatus, generation int64) {
+	pending := []string{}
+	for _, provider := range status.Providers {
+		if !provider.BlueprintApplied {
+			pending = append(pending, provider.AkBlueprint)
+		}
+	}
+	for _, application := range status.Applications {
+		if !application.BlueprintApplied {
+			pending = append(pending, application.AkBlueprint)
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, utils.BlueprintsReadyCondition(pending, generation))
+}
+
+// setFailedStatus marks the OIDC resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *OIDCReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.OIDC, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of OIDC `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// oidcLabels are the labels that mark a generated resource as belonging to the given OIDC
+func oidcLabels(crd *akmv1a1.OIDC) map[string]string {
+	return map[string]string{
+		oidcNameLabel:      crd.Name,
+		oidcNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToOIDC maps a generated AkBlueprint back to the OIDC resource that generated it
+func (r *OIDCReconciler) akBlueprintToOIDC(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, oidcNameLabel, oidcNamespaceLabel)
+}
+
+// oidcFlowSlugs are the unique slugs of the flows the providers of the OIDC resource use, in order of first use
+func oidcFlowSlugs(crd *akmv1a1.OIDC) []string {
+	slugs := []string{}
+	seen := map[string]bool{}
+	for _, provider := range crd.Spec.Providers {
+		for _, slug := range []string{provider.AuthenticationFlow, provider.AuthorizationFlow} {
+			if slug != "" && !seen[slug] {
+				slugs = append(slugs, slug)
+				seen[slug] = true
+			}
+		}
+	}
+	return slugs
+}
+
+// findMissingFlows lists the flows used by the OIDC resource that are neither declared by an AkFlow for the
+// authentik instance nor already exist in authentik. authentik is only asked about flows no AkFlow declares.
+func (r *OIDCReconciler) findMissingFlows(ctx context.Context, ak *akmv1a1.Ak, crd *akmv1a1.OIDC) ([]string, error) {
+	flows := &akmv1a1.AkFlowList{}
+	err := r.List(ctx, flows)
+	if err != nil {
+		return nil, err
+	}
+	declared := map[string]bool{}
+	for _, flow := range flows.Items {
+		if flow.Spec.Instance.Namespace == ak.Namespace {
+			declared[flow.FlowSlug()] = true
+		}
+	}
+	undeclared := []string{}
+	for _, slug := range oidcFlowSlugs(crd) {
+		if !declared[slug] {
+			undeclared = append(undeclared, slug)
+		}
+	}
+	if len(undeclared) == 0 {
+		return nil, nil
+	}
+	akc, err := r.NewAuthentikClient(ctx, ak)
+	if err != nil {
+		return nil, err
+	}
+	return missingFlows(ctx, akc, undeclared)
+}
+
+// missingFlows lists the slugs of the given flows that do not exist in authentik
+func missingFlows(ctx context.Context, akc *authentik.Client, slugs []string) ([]string, error) {
+	missing := []string{}
+	for _, slug := range slugs {
+		_, err := akc.GetFlow(ctx, slug)
+		if authentik.IsNotFound(err) {
+			missing = append(missing, slug)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// akFlowToOIDCs maps an AkFlow to the OIDC resources whose providers use its slug, so they are
+// checked again once the flow is declared
+func (r *OIDCReconciler) akFlowToOIDCs(ctx context.Context, obj client.Object) []reconcile.Request {
+	l := klog.FromContext(ctx)
+	flow, ok := obj.(*akmv1a1.AkFlow)
+	if !ok {
+		return nil
+	}
+	oidcs := &akmv1a1.OIDCList{}
+	err := r.List(ctx, oidcs)
+	if err != nil {
+		l.Error(err, fmt.Sprintf("Failed to list OIDCs using AkFlow `%v` in `%v`.", flow.Name, flow.Namespace))
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, oidc := range oidcs.Items {
+		for _, slug := range oidcFlowSlugs(&oidc) {
+			if slug == flow.FlowSlug() {
+				reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: oidc.Name, Namespace: oidc.Namespace}})
+				break
+			}
+		}
+	}
+	return reqs
+}
+
 // spawnAndFetchOIDCSecret creates a secret for a client application to use to register and identify itself using the client_id and client_secret within.
 func (r *OIDCReconciler) spawnAndFetchOIDCSecret(ctx context.Context, crd *akmv1a1.OIDC, provider *akmv1a1.OIDCProvider) (*corev1.Secret, error) {
 	secret := &corev1.Secret{}
@@ -223,241 +423,173 @@ func (r *OIDCReconciler) ConfigmapFromOIDC(akfqdn string, crd *akmv1a1.OIDC, app
 	return configmap
 }
 
-func (r *OIDCReconciler) reconcileApplicationBlueprint(ak *akmv1a1.Ak, ctx context.Context, crd *akmv1a1.OIDC, application *akmv1a1.OIDCApplication) (*akmv1a1.AkBlueprint, error) {
-	// TODO: Once this works skip intermediary stage and go straight to blueprint
-	// as this is far too complex, but it does add sanity checking
-	// Really complicated way to take a map[string]string and convert it to a raw.Raw
-	mapId := map[string]interface{}{
-		//"id": nil,
-		"slug": application.Slug,
-	}
-	rawId, err := yaml_v3.Marshal(mapId)
-	if err != nil {
-		return nil, err
-	}
-	id := raw.Raw{}
-	err = yaml_v3.Unmarshal(rawId, &id)
-	if err != nil {
-		return nil, err
-	}
-
-	// Another complicated way to create the attrs map
-	mapAttrs := map[string]string{
+// reconcileApplicationBlueprint ensure the application blueprint exists and matches the desired state in the auth namespace
+func (r *OIDCReconciler) reconcileApplicationBlueprint(ak *akmv1a1.Ak, ctx context.Context, crd *akmv1a1.OIDC, application *akmv1a1.OIDCApplication, groups []string) (*akmv1a1.AkBlueprint, error) {
+	ui := application.OIDCApplicationUISettings
+	attrs := map[string]interface{}{
 		"name":               application.Name,
 		"group":              application.Group,
 		"policy_engine_mode": application.PolicyEngineMode,
-		"provider":           fmt.Sprintf("!Find [authentik_providers_oauth2.oauth2provider, [name, %v]]", application.Provider),
+		"provider":           blueprint.Find("authentik_providers_oauth2.oauth2provider", "name", application.Provider),
 		"slug":               application.Slug,
+		"open_in_new_tab":    ui.OpenInNewTab,
 	}
-	rawAttrs, err := yaml_v3.Marshal(mapAttrs)
-	if err != nil {
-		return nil, err
+	for attr, value := range map[string]string{
+		"meta_launch_url":  ui.LaunchURL,
+		"meta_icon":        ui.Icon,
+		"meta_publisher":   ui.Publisher,
+		"meta_description": ui.Description,
+	} {
+		if value != "" {
+			attrs[attr] = value
+		}
 	}
-	attrs := raw.Raw{}
-	err = yaml_v3.Unmarshal(rawAttrs, &attrs)
-	if err != nil {
-		return nil, err
+	if len(application.BackChannelProviders) > 0 {
+		backchannel := []interface{}{}
+		for _, name := range application.BackChannelProviders {
+			backchannel = append(backchannel, blueprint.Find("authentik_core.provider", "name", name))
+		}
+		attrs["backchannel_providers"] = backchannel
 	}
 
-	bpContent := &akmv1a1.BP{
-		Version: 1,
-		Metadata: akmv1a1.BPMeta{
-			Name: fmt.Sprintf("%v-app-%v", crd.Namespace, application.Slug),
-		},
-		Entries: []akmv1a1.BPModel{
-			akmv1a1.BPModel{
+	bp := blueprint.New(fmt.Sprintf("%v-app-%v", crd.Namespace, application.Slug),
+		append([]blueprint.Entry{
+			{
 				Model:       "authentik_core.application",
 				State:       "present",
-				Identifiers: &id,
-				Attrs:       &attrs,
-				Conditions:  []string{},
+				ID:          "application",
+				Identifiers: map[string]interface{}{"slug": application.Slug},
+				Attrs:       attrs,
 			},
-		},
-	}
-	fmt.Printf("APPLICATION BLUEPRINT: %+v\n", bpContent)
-	bpContentStr, err := yaml_v3.Marshal(bpContent)
-	fmt.Printf("APPLICATION BLUEPRINT: %+v\n", bpContentStr)
-	if err != nil {
-		return nil, err
-	}
-	bp := &akmv1a1.AkBlueprint{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%v-app-%v", crd.Namespace, application.Slug),
-			Namespace: ak.Namespace,
-		},
-		Spec: akmv1a1.AkBlueprintSpec{
-			StorageType: "file",
-			File:        fmt.Sprintf("/blueprints/operator/%v-app-%v.yaml", crd.Namespace, application.Slug),
-			Blueprint:   string(bpContentStr),
-		},
-	}
-	ctrl.SetControllerReference(crd, bp, r.Scheme)
-	fmt.Printf("APPLICATION BLUEPRINT: %+v\n", bp)
+		}, accessGroupBindings(groups)...)...,
+	)
+	return r.reconcileBlueprint(ak, ctx, crd, bp)
+}
 
-	err = r.Update(ctx, bp)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			err = r.Create(ctx, bp)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, err
+// resolveAccessGroups looks up the authentik group names of the AkGroups an application is restricted to
+func (r *OIDCReconciler) resolveAccessGroups(ctx context.Context, crd *akmv1a1.OIDC, application *akmv1a1.OIDCApplication) ([]string, error) {
+	groups := []string{}
+	for _, ref := range application.AccessGroups {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = crd.Namespace
 		}
+		group := &akmv1a1.AkGroup{}
+		err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, group)
+		if err != nil {
+			return nil, fmt.Errorf("access group `%v/%v` of application `%v`: %w", namespace, ref.Name, application.Slug, err)
+		}
+		groups = append(groups, group.GroupName())
 	}
-	return bp, nil
+	return groups, nil
+}
+
+// accessGroupBindings are the policy bindings of each group to the application entry of a blueprint,
+// with no bindings authentik lets everyone access the application, with any binding only those that match.
+func accessGroupBindings(groups []string) []blueprint.Entry {
+	bindings := []blueprint.Entry{}
+	for i, group := range groups {
+		find := blueprint.Find("authentik_core.group", "name", group)
+		bindings = append(bindings, blueprint.Entry{
+			Model: "authentik_policies.policybinding",
+			State: "present",
+			Identifiers: map[string]interface{}{
+				"target": blueprint.KeyOf("application"),
+				"group":  find,
+			},
+			Attrs: map[string]interface{}{
+				"target": blueprint.KeyOf("application"),
+				"group":  find,
+				"order":  i,
+			},
+		})
+	}
+	return bindings
 }
 
 // reconcileProviderBlueprint ensure the provider blueprint exists and matches the desired state in the auth namespace
 func (r *OIDCReconciler) reconcileProviderBlueprint(ak *akmv1a1.Ak, ctx context.Context, crd *akmv1a1.OIDC, provider *akmv1a1.OIDCProvider) (*akmv1a1.AkBlueprint, error) {
-	// TODO: Once this works skip intermediary stage and go straight to blueprint
-	// as this is far too complex, but it does add sanity checking
-	// Really complicated way to take a map[string]string and convert it to a raw.Raw
-
-	//mapId := map[string]interface{}{
-	//	"id": nil,
-	//}
-	//rawId, err := yaml_v3.Marshal(mapId)
-	//if err != nil {
-	//	return nil, err
-	//}
-	//id := raw.Raw{}
-	//err = yaml_v3.Unmarshal(rawId, &id)
-	//if err != nil {
-	//	return nil, err
-	//}
-
-	bpPlainContent := map[string]interface{}{
-		"version": 1,
-		"metadata": map[string]interface{}{
-			"name": fmt.Sprintf("%v-provider-%v", crd.Namespace, provider.Name),
-		},
-		"entries": []map[string]interface{}{
-			{
-				"model": "authentik_providers_oauth2.oauth2provider",
-				"state": "present",
-				"identifiers": map[string]interface{}{
-					"id": nil,
-				},
-				"attrs": map[string]interface{}{
-					"name":                       provider.Name,
-					"access_code_validity":       provider.ProtocolSettings.AccessCodeValidity,
-					"access_token_validity":      provider.ProtocolSettings.AccessTokenValidity,
-					"refresh_token_validity":     provider.ProtocolSettings.RefreshTokenValidity,
-					"authentication_flow":        fmt.Sprintf("!Find [authentik_flows.flow, [slug, %v]]", provider.AuthenticationFlow),
-					"authorization_flow":         fmt.Sprintf("!Find [authentik_flows.flow, [slug, %v]]", provider.AuthorizationFlow),
-					"signing_key":                fmt.Sprintf("!Find [authentik_crypto.certificatekeypair, [name, %v]]", provider.ProtocolSettings.SigningKey),
-					"client_id":                  provider.ProtocolSettings.ClientID,
-					"client_secret":              provider.ProtocolSettings.ClientSecret,
-					"client_type":                provider.ProtocolSettings.ClientType,
-					"include_claims_in_id_token": bool(provider.ProtocolSettings.IncludeClaimsInIDToken),
-					"issuer_mode":                provider.ProtocolSettings.IssuerMode,
-					"redirect_uris":              provider.ProtocolSettings.RedirectURIs,
-					"subject_claims":             provider.ProtocolSettings.SubjectMode,
-				},
-				"conditions": []string{},
-			},
-		},
+	attrs := map[string]interface{}{
+		"name":                       provider.Name,
+		"access_code_validity":       provider.ProtocolSettings.AccessCodeValidity,
+		"access_token_validity":      provider.ProtocolSettings.AccessTokenValidity,
+		"refresh_token_validity":     provider.ProtocolSettings.RefreshTokenValidity,
+		"authentication_flow":        blueprint.Find("authentik_flows.flow", "slug", provider.AuthenticationFlow),
+		"authorization_flow":         blueprint.Find("authentik_flows.flow", "slug", provider.AuthorizationFlow),
+		"signing_key":                blueprint.Find("authentik_crypto.certificatekeypair", "name", provider.ProtocolSettings.SigningKey),
+		"client_id":                  provider.ProtocolSettings.ClientID,
+		"client_secret":              provider.ProtocolSettings.ClientSecret,
+		"client_type":                provider.ProtocolSettings.ClientType,
+		"include_claims_in_id_token": provider.ProtocolSettings.IncludeClaimsInIDToken,
+		"issuer_mode":                provider.ProtocolSettings.IssuerMode,
+		"redirect_uris":              provider.ProtocolSettings.RedirectURIs,
+		"subject_claims":             provider.ProtocolSettings.SubjectMode,
+	}
+	if mappings := oidcPropertyMappings(provider); len(mappings) > 0 {
+		attrs["property_mappings"] = mappings
+	}
+	if sources := provider.MachineToMachineSettings.TrustedOIDCSources; len(sources) > 0 {
+		jwksSources := []interface{}{}
+		for _, source := range sources {
+			jwksSources = append(jwksSources, blueprint.Find("authentik_sources_oauth.oauthsource", "slug", source))
+		}
+		attrs["jwks_sources"] = jwksSources
 	}
 
-	bpPlainContentStr, err := yaml_v3.Marshal(bpPlainContent)
+	bp := blueprint.New(fmt.Sprintf("%v-provider-%v", crd.Namespace, provider.Name), blueprint.Entry{
+		Model:       "authentik_providers_oauth2.oauth2provider",
+		State:       "present",
+		Identifiers: map[string]interface{}{"id": nil},
+		Attrs:       attrs,
+	})
+	return r.reconcileBlueprint(ak, ctx, crd, bp)
+}
+
+// reconcileBlueprint ensures the AkBlueprint of a generated blueprint exists in the auth namespace,
+// named after the blueprint
+func (r *OIDCReconciler) reconcileBlueprint(ak *akmv1a1.Ak, ctx context.Context, crd *akmv1a1.OIDC, bp *blueprint.Blueprint) (*akmv1a1.AkBlueprint, error) {
+	content, err := bp.Marshal()
 	if err != nil {
 		return nil, err
 	}
-
-	//bpPlain := map[string]interface{}{
-	//b	"metadata": metav1.ObjectMeta{
-	//b		Name:      fmt.Sprintf("%v-provider-%v", crd.Namespace, provider.Name),
-	//b		Namespace: ak.Namespace,
-	//b	},
-	//b	"spec": map[string]interface{}{
-	//b		"storageType": "file",
-	//b		"file":        fmt.Sprintf("/blueprints/operator/%v-provider-%v.yaml", crd.Namespace, provider.Name),
-	//b		"blueprint":   string(bpPlainContentStr),
-	//b	},
-	//b}
-
-	//b// Another complicated way to create the attrs map
-	//bmapAttrs := map[string]interface{}{
-	//b	"name":                       provider.Name,
-	//b	"access_code_validity":       provider.ProtocolSettings.AccessCodeValidity,
-	//b	"access_token_validity":      provider.ProtocolSettings.AccessTokenValidity,
-	//b	"refresh_token_validity":     provider.ProtocolSettings.RefreshTokenValidity,
-	//b	"authentication_flow":        fmt.Sprintf("!Find [authentik_flows.flow, [slug, %v]]", provider.AuthenticationFlow),
-	//b	"authorization_flow":         fmt.Sprintf("!Find [authentik_flows.flow, [slug, %v]]", provider.AuthorizationFlow),
-	//b	"signing_key":                fmt.Sprintf("!Find [authentik_crypto.certificatekeypair, [name, %v]]", provider.ProtocolSettings.SigningKey),
-	//b	"client_id":                  provider.ProtocolSettings.ClientID,
-	//b	"client_secret":              provider.ProtocolSettings.ClientSecret,
-	//b	"client_type":                provider.ProtocolSettings.ClientType,
-	//b	"include_claims_in_id_token": bool(provider.ProtocolSettings.IncludeClaimsInIDToken),
-	//b	"issuer_mode":                provider.ProtocolSettings.IssuerMode,
-	//b	"redirect_uris":              provider.ProtocolSettings.RedirectURIs,
-	//b	"subject_claims":             provider.ProtocolSettings.SubjectMode,
-	//b}
-	//bfmt.Printf("PROVIDER BLUEPRINT ATTRS STRUCT: %+v\n", mapAttrs)
-	//brawAttrs, err := yaml_v3.Marshal(mapAttrs)
-	//bfmt.Printf("PROVIDER BLUEPRINT ATTRS SERIAL: %+v\n", string(rawAttrs))
-	//bif err != nil {
-	//b	return nil, err
-	//b}
-	//battrs := raw.Raw{}
-	//berr = yaml_v3.Unmarshal(rawAttrs, &attrs)
-	//bif err != nil {
-	//b	return nil, err
-	//b}
-
-	//bbpContent := &akmv1a1.BP{
-	//b	Version: 1,
-	//b	Metadata: akmv1a1.BPMeta{
-	//b		Name: fmt.Sprintf("%v-provider-%v", crd.Namespace, provider.Name),
-	//b	},
-	//b	Entries: []akmv1a1.BPModel{
-	//b		akmv1a1.BPModel{
-	//b			Model:       "authentik_providers_oauth2.oauth2provider",
-	//b			State:       "present",
-	//b			Identifiers: &id,
-	//b			Attrs:       &attrs,
-	//b			Conditions:  []string{},
-	//b		},
-	//b	},
-	//b}
-	//bfmt.Printf("PROVIDER BLUEPRINT CONTENT: %+v\n", bpContent)
-	//bbpContentStr, err := yaml_v3.Marshal(bpContent)
-	//bfmt.Printf("PROVIDER BLUEPRINT CONTENT STRING: %+v\n", string(bpContentStr))
-	//bif err != nil {
-	//b	return nil, err
-	//b}
-	bp := &akmv1a1.AkBlueprint{
+	name := bp.Metadata.Name
+	akbp := &akmv1a1.AkBlueprint{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%v-provider-%v", crd.Namespace, provider.Name),
+			Name:      name,
 			Namespace: ak.Namespace,
+			Labels:    oidcLabels(crd),
 		},
 		Spec: akmv1a1.AkBlueprintSpec{
 			StorageType: "file",
-			File:        fmt.Sprintf("/blueprints/operator/%v-provider-%v.yaml", crd.Namespace, provider.Name),
-			Blueprint:   string(bpPlainContentStr),
+			File:        fmt.Sprintf("/blueprints/operator/%v.yaml", name),
+			Blueprint:   content,
 		},
 	}
-	ctrl.SetControllerReference(crd, bp, r.Scheme)
-	fmt.Printf("PROVIDER BLUEPRINT: %+v\n", bp)
-
-	err = r.Update(ctx, bp)
+	err = r.ReconcileAkBlueprint(ctx, akbp)
 	if err != nil {
-		if errors.IsNotFound(err) {
-			err = r.Create(ctx, bp)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, err
-		}
+		return nil, err
+	}
+	return akbp, nil
+}
+
+// oidcPropertyMappings are the blueprint references to the scope mappings of a provider, those of its scopes
+// followed by those named in its property mappings
+func oidcPropertyMappings(provider *akmv1a1.OIDCProvider) []interface{} {
+	mappings := []interface{}{}
+	for _, scope := range provider.ProtocolSettings.Scopes {
+		mappings = append(mappings, blueprint.Find(propertyMappingModels["scope"], "scope_name", scope))
+	}
+	for _, name := range provider.ProtocolSettings.PropertyMappings {
+		mappings = append(mappings, blueprint.Find(propertyMappingModels["scope"], "name", name))
 	}
-	return bp, nil
+	return mappings
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *OIDCReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&akmv1alpha1.OIDC{}).
+		For(&akmv1alpha1.OIDC{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&akmv1alpha1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToOIDC)).
+		Watches(&akmv1alpha1.AkFlow{}, handler.EnqueueRequestsFromMapFunc(r.akFlowToOIDCs), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
@@ -0,0 +1,293 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	akfake "gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik/fake"
+)
+
+func TestSetOIDCReadyCondition(t *testing.T) {
+	status := &akmv1a1.OIDCStatus{
+		Providers:    []akmv1a1.OIDCProviderStatus{{Name: "p", AkBlueprint: "auth/app-provider-p", BlueprintApplied: true}},
+		Applications: []akmv1a1.OIDCApplicationStatus{{Slug: "a", AkBlueprint: "auth/app-app-a"}},
+	}
+	setOIDCReadyCondition(status, 1)
+	ready := meta.FindStatusCondition(status.Conditions, akmv1a1.ConditionReady)
+	if ready == nil || ready.Status != metav1.ConditionFalse || ready.Reason != "BlueprintsPending" {
+		t.Fatalf("expected pending ready condition, got %v", ready)
+	}
+
+	status.Applications[0].BlueprintApplied = true
+	setOIDCReadyCondition(status, 2)
+	ready = meta.FindStatusCondition(status.Conditions, akmv1a1.ConditionReady)
+	if ready == nil || ready.Status != metav1.ConditionTrue || ready.ObservedGeneration != 2 {
+		t.Fatalf("expected ready condition, got %v", ready)
+	}
+}
+
+func TestAkBlueprintToOIDC(t *testing.T) {
+	r := &OIDCReconciler{}
+	oidc := &akmv1a1.OIDC{ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "app"}}
+	bp := &akmv1a1.AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "app-app-myapp", Namespace: "auth", Labels: oidcLabels(oidc)}}
+	reqs := r.akBlueprintToOIDC(context.TODO(), bp)
+	if len(reqs) != 1 || reqs[0].Name != "myapp" || reqs[0].Namespace != "app" {
+		t.Fatalf("expected request for app/myapp, got %v", reqs)
+	}
+
+	unowned := &akmv1a1.AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "auth"}}
+	if reqs := r.akBlueprintToOIDC(context.TODO(), unowned); len(reqs) != 0 {
+		t.Fatalf("expected no requests for unlabelled blueprint, got %v", reqs)
+	}
+}
+
+func TestOIDCAccessGroups(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&akmv1a1.AkGroup{ObjectMeta: metav1.ObjectMeta{Name: "editors", Namespace: "app"}},
+		&akmv1a1.AkGroup{ObjectMeta: metav1.ObjectMeta{Name: "admins", Namespace: "auth"}, Spec: akmv1a1.AkGroupSpec{Name: "authentik Admins"}},
+	).Build()
+	r := &OIDCReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+	ak := &akmv1a1.Ak{ObjectMeta: metav1.ObjectMeta{Name: "ak", Namespace: "auth"}}
+	crd := &akmv1a1.OIDC{ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "app"}}
+	application := &akmv1a1.OIDCApplication{
+		Name:             "My App",
+		Slug:             "myapp",
+		Provider:         "myapp",
+		PolicyEngineMode: "any",
+		AccessGroups:     []akmv1a1.AkGroupReference{{Name: "editors"}, {Name: "admins", Namespace: "auth"}},
+	}
+
+	groups, err := r.resolveAccessGroups(ctx, crd, application)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(groups, ",") != "editors,authentik Admins" {
+		t.Fatalf("resolved groups = %v", groups)
+	}
+	bp, err := r.reconcileApplicationBlueprint(ak, ctx, crd, application, groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"model: authentik_policies.policybinding",
+		"group: !Find [authentik_core.group, [name, authentik Admins]]",
+		"target: !KeyOf application",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("application blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated application blueprint is invalid: %v", err)
+	}
+
+	application.AccessGroups = []akmv1a1.AkGroupReference{{Name: "missing"}}
+	if _, err := r.resolveAccessGroups(ctx, crd, application); err == nil || !strings.Contains(err.Error(), "app/missing") {
+		t.Errorf("expected a missing group error, got %v", err)
+	}
+}
+
+func TestOIDCMissingFlows(t *testing.T) {
+	ctx := context.Background()
+	srv := akfake.NewServer("token")
+	t.Cleanup(srv.Close)
+	akc, err := authentik.NewClient(srv.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Seed("flows/instances", map[string]interface{}{"slug": "default-authentication-flow", "designation": "authentication"})
+
+	crd := &akmv1a1.OIDC{
+		ObjectMeta: metav1.ObjectMeta{Name: "oidc", Namespace: "app"},
+		Spec: akmv1a1.OIDCSpec{
+			Providers: []akmv1a1.OIDCProvider{
+				{Name: "a", AuthenticationFlow: "default-authentication-flow", AuthorizationFlow: "my-consent"},
+				{Name: "b", AuthorizationFlow: "my-consent"},
+			},
+		},
+	}
+	slugs := oidcFlowSlugs(crd)
+	if strings.Join(slugs, ",") != "default-authentication-flow,my-consent" {
+		t.Fatalf("unexpected flow slugs %v", slugs)
+	}
+	missing, err := missingFlows(ctx, akc, slugs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 1 || missing[0] != "my-consent" {
+		t.Errorf("expected my-consent to be missing, got %v", missing)
+	}
+
+	// declaring the flow reconciles the OIDC resources using it
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(crd).Build()
+	r := &OIDCReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+	flow := &akmv1a1.AkFlow{
+		ObjectMeta: metav1.ObjectMeta{Name: "consent", Namespace: "auth"},
+		Spec:       akmv1a1.AkFlowSpec{Slug: "my-consent"},
+	}
+	reqs := r.akFlowToOIDCs(ctx, flow)
+	if len(reqs) != 1 || reqs[0].Name != "oidc" || reqs[0].Namespace != "app" {
+		t.Fatalf("expected request for app/oidc, got %v", reqs)
+	}
+}
+
+func TestOIDCBlueprintAttrs(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := &OIDCReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+	ak := &akmv1a1.Ak{ObjectMeta: metav1.ObjectMeta{Name: "ak", Namespace: "auth"}}
+	crd := &akmv1a1.OIDC{ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "app"}}
+	provider := &akmv1a1.OIDCProvider{
+		Name:              "myapp",
+		AuthorizationFlow: "default-provider-authorization-implicit-consent",
+		ProtocolSettings: akmv1a1.OIDCProviderProtocolSettings{
+			ClientType:       "confidential",
+			Scopes:           []string{"openid", "email"},
+			PropertyMappings: []string{"groups"},
+		},
+		MachineToMachineSettings: akmv1a1.OIDCProviderMachineToMachineSettings{TrustedOIDCSources: []string{"github"}},
+	}
+	application := &akmv1a1.OIDCApplication{
+		Name:                 "My App",
+		Slug:                 "myapp",
+		Provider:             "myapp",
+		PolicyEngineMode:     "any",
+		BackChannelProviders: []string{"myapp-ldap"},
+		OIDCApplicationUISettings: akmv1a1.OIDCApplicationUISettings{
+			LaunchURL:    "https://myapp.org.example",
+			OpenInNewTab: true,
+			Icon:         "fa://fa-rocket",
+			Publisher:    "Example Org",
+			// user text that looks like a tag must stay text rather than leak the secret key
+			Description: "!Env AUTHENTIK_SECRET_KEY",
+		},
+	}
+
+	bp, err := r.reconcileProviderBlueprint(ak, ctx, crd, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bp.OwnerReferences) != 0 {
+		t.Errorf("expected no owner references across namespaces, got %v", bp.OwnerReferences)
+	}
+	if bp.Labels[oidcNameLabel] != crd.Name || bp.Labels[oidcNamespaceLabel] != crd.Namespace {
+		t.Errorf("expected blueprint labelled with its OIDC resource, got %v", bp.Labels)
+	}
+	content, err := blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"- !Find [authentik_providers_oauth2.scopemapping, [scope_name, openid]]",
+		"- !Find [authentik_providers_oauth2.scopemapping, [scope_name, email]]",
+		"- !Find [authentik_providers_oauth2.scopemapping, [name, groups]]",
+		"jwks_sources:",
+		"- !Find [authentik_sources_oauth.oauthsource, [slug, github]]",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("provider blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated provider blueprint is invalid: %v", err)
+	}
+
+	bp, err = r.reconcileApplicationBlueprint(ak, ctx, crd, application, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = blueprintContent(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"- !Find [authentik_core.provider, [name, myapp-ldap]]",
+		"meta_launch_url: https://myapp.org.example",
+		"meta_icon: fa://fa-rocket",
+		"meta_publisher: Example Org",
+		"meta_description: '!Env AUTHENTIK_SECRET_KEY'",
+		"open_in_new_tab: true",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("application blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated application blueprint is invalid: %v", err)
+	}
+
+	// unset settings are left to authentik
+	provider.ProtocolSettings.Scopes = nil
+	provider.ProtocolSettings.PropertyMappings = nil
+	provider.MachineToMachineSettings.TrustedOIDCSources = nil
+	application.BackChannelProviders = nil
+	application.OIDCApplicationUISettings = akmv1a1.OIDCApplicationUISettings{}
+	if bp, err = r.reconcileProviderBlueprint(ak, ctx, crd, provider); err != nil {
+		t.Fatal(err)
+	}
+	if content, err = blueprintContent(bp); err != nil {
+		t.Fatal(err)
+	}
+	for _, unset := range []string{"property_mappings", "jwks_sources"} {
+		if strings.Contains(content, unset) {
+			t.Errorf("provider blueprint sets %v which was not given:\n%v", unset, content)
+		}
+	}
+	if bp, err = r.reconcileApplicationBlueprint(ak, ctx, crd, application, nil); err != nil {
+		t.Fatal(err)
+	}
+	if content, err = blueprintContent(bp); err != nil {
+		t.Fatal(err)
+	}
+	for _, unset := range []string{"backchannel_providers", "meta_"} {
+		if strings.Contains(content, unset) {
+			t.Errorf("application blueprint sets %v which was not given:\n%v", unset, content)
+		}
+	}
+}
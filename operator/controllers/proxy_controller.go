@@ -0,0 +1,456 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the Proxy resource that generated them.
+const (
+	proxyNameLabel      = "akm.goauthentik.io/proxy"
+	proxyNamespaceLabel = "akm.goauthentik.io/proxy-namespace"
+)
+
+// embeddedOutpost is the name of the outpost authentik runs inside its own server
+const embeddedOutpost = "authentik Embedded Outpost"
+
+// authResponseHeaders are the headers the outpost hands back to the ingress to pass on to the application
+const authResponseHeaders = "Set-Cookie,X-authentik-username,X-authentik-groups,X-authentik-entitlements,X-authentik-email,X-authentik-name,X-authentik-uid"
+
+// ProxyReconciler reconciles a Proxy object
+type ProxyReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=proxies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=proxies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=proxies/finalizers,verbs=update
+
+// Reconcile turns the providers and applications of a Proxy resource into AkBlueprints in the authentik
+// namespace, binds the providers to their outposts, and optionally generates configmaps of ingress-nginx
+// annotations for forward auth in its own namespace.
+func (r *ProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.Proxy{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("Proxy resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get Proxy resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found Proxy resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("Proxy resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, proxyLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+	crd.Status.Providers = []akmv1a1.ProxyProviderStatus{}
+	crd.Status.Applications = []akmv1a1.ProxyApplicationStatus{}
+	generated := []string{}
+
+	// PROVIDERS - generate a blueprint for each provider and bind it to its outpost once applied
+	var akc *authentik.Client
+	managed, err := r.managedOutposts(ctx, ak.Namespace)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "OutpostBindFailed", err)
+	}
+	for i := range crd.Spec.Providers {
+		provider := &crd.Spec.Providers[i]
+		bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-proxy-provider-%v", crd.Namespace, provider.Name), proxyLabels(crd), proxyProviderBlueprint(provider))
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+		}
+		generated = append(generated, bp.Name)
+		status := akmv1a1.ProxyProviderStatus{
+			Name:             provider.Name,
+			AkBlueprint:      fmt.Sprintf("%v/%v", bp.Namespace, bp.Name),
+			BlueprintApplied: meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady),
+		}
+		// the provider only exists in authentik once its blueprint has been applied
+		if status.BlueprintApplied {
+			if akc == nil {
+				akc, err = r.NewAuthentikClient(ctx, ak)
+				if err != nil {
+					return ctrl.Result{}, r.setFailedStatus(ctx, crd, "OutpostBindFailed", err)
+				}
+			}
+			err = bindOutpost(ctx, akc, provider.Name, provider.Outpost, managed)
+			if err != nil {
+				return ctrl.Result{}, r.setFailedStatus(ctx, crd, "OutpostBindFailed", err)
+			}
+			status.Outpost = provider.Outpost
+		}
+		crd.Status.Providers = append(crd.Status.Providers, status)
+	}
+
+	// APPLICATIONS - generate a blueprint and optionally an annotations configmap for each application
+	var values map[string]interface{}
+	for i := range crd.Spec.Applications {
+		application := &crd.Spec.Applications[i]
+		bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-proxy-app-%v", crd.Namespace, application.Slug), proxyLabels(crd), proxyApplicationBlueprint(application))
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+		}
+		generated = append(generated, bp.Name)
+		status := akmv1a1.ProxyApplicationStatus{
+			Slug:             application.Slug,
+			AkBlueprint:      fmt.Sprintf("%v/%v", bp.Namespace, bp.Name),
+			BlueprintApplied: meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady),
+		}
+		if application.ConfigMap != nil {
+			provider := findProxyProvider(crd, application.Provider)
+			if provider == nil {
+				return ctrl.Result{}, r.setFailedStatus(ctx, crd, "ConfigMapFailed", fmt.Errorf("application `%v` uses provider `%v` which is not in this Proxy", application.Slug, application.Provider))
+			}
+			if values == nil {
+				values, err = r.GetReleasedValues(ak.Namespace, ak.Name)
+				if err != nil {
+					return ctrl.Result{}, r.setFailedStatus(ctx, crd, "ConfigMapFailed", err)
+				}
+			}
+			configmap := r.configmapFromProxy(crd, application, nginxAnnotations(outpostURL(values, ak.Namespace, provider.Outpost, managed), provider))
+			err = r.reconcileConfigmap(ctx, configmap)
+			if err != nil {
+				return ctrl.Result{}, r.setFailedStatus(ctx, crd, "ConfigMapFailed", err)
+			}
+			status.ConfigMap = configmap.Name
+		}
+		crd.Status.Applications = append(crd.Status.Applications, status)
+	}
+
+	// PRUNE - blueprints of providers and applications no longer in the spec
+	err = r.DeleteGeneratedBlueprints(ctx, ak.Namespace, proxyLabels(crd), generated...)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+
+	// STATUS
+	setProxyReadyCondition(&crd.Status, crd.Generation)
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setProxyReadyCondition sets the Ready condition based on whether every generated blueprint has been applied
+func setProxyReadyCondition(status *akmv1a1.ProxyStatus, generation int64) {
+	pending := []string{}
+	for _, provider := range status.Providers {
+		if !provider.BlueprintApplied {
+			pending = append(pending, provider.AkBlueprint)
+		}
+	}
+	for _, application := range status.Applications {
+		if !application.BlueprintApplied {
+			pending = append(pending, application.AkBlueprint)
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, utils.BlueprintsReadyCondition(pending, generation))
+}
+
+// setFailedStatus marks the Proxy resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *ProxyReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.Proxy, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of Proxy `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// proxyLabels are the labels that mark a generated resource as belonging to the given Proxy
+func proxyLabels(crd *akmv1a1.Proxy) map[string]string {
+	return map[string]string{
+		proxyNameLabel:      crd.Name,
+		proxyNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToProxy maps a generated AkBlueprint back to the Proxy resource that generated it
+func (r *ProxyReconciler) akBlueprintToProxy(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, proxyNameLabel, proxyNamespaceLabel)
+}
+
+// findProxyProvider finds the provider with the given name in the spec of a Proxy
+func findProxyProvider(crd *akmv1a1.Proxy, name string) *akmv1a1.ProxyProvider {
+	for i := range crd.Spec.Providers {
+		if crd.Spec.Providers[i].Name == name {
+			return &crd.Spec.Providers[i]
+		}
+	}
+	return nil
+}
+
+// managedOutposts are the AkOutposts of the authentik instance by the lower case name of their outpost. They set the
+// providers of their outposts themselves, so Proxy resources leave those outposts alone.
+func (r *ProxyReconciler) managedOutposts(ctx context.Context, akNamespace string) (map[string]*akmv1a1.AkOutpost, error) {
+	outposts := &akmv1a1.AkOutpostList{}
+	err := r.List(ctx, outposts)
+	if err != nil {
+		return nil, err
+	}
+	managed := map[string]*akmv1a1.AkOutpost{}
+	for i := range outposts.Items {
+		if outposts.Items[i].Spec.Instance.Namespace == akNamespace {
+			managed[strings.ToLower(outposts.Items[i].OutpostName())] = &outposts.Items[i]
+		}
+	}
+	return managed, nil
+}
+
+// bindOutpost adds the proxy provider to the named outpost and removes it from any other outpost, so that
+// exactly one outpost serves it. Other providers of the outposts are left untouched. Outposts managed by an
+// AkOutpost are never changed, the provider must instead be listed in the AkOutpost it is bound to.
+func bindOutpost(ctx context.Context, akc *authentik.Client, providerName string, outpostName string, managed map[string]*akmv1a1.AkOutpost) error {
+	providers, err := akc.ListProxyProviders(ctx, url.Values{"name__iexact": {providerName}})
+	if err != nil {
+		return err
+	}
+	if len(providers) == 0 {
+		return fmt.Errorf("proxy provider `%v` not found in authentik", providerName)
+	}
+	pk := providers[0].PK
+
+	outposts, err := akc.ListOutposts(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// check the outpost exists before unbinding the provider from anywhere else
+	found := false
+	for _, outpost := range outposts {
+		found = found || strings.EqualFold(outpost.Name, outpostName)
+	}
+	if !found {
+		return fmt.Errorf("outpost `%v` not found in authentik", outpostName)
+	}
+	if owner, ok := managed[strings.ToLower(outpostName)]; ok {
+		listed := false
+		for _, name := range owner.Spec.Providers {
+			listed = listed || strings.EqualFold(name, providerName)
+		}
+		if !listed {
+			return fmt.Errorf("outpost `%v` is managed by AkOutpost `%v/%v` which does not list provider `%v`", outpostName, owner.Namespace, owner.Name, providerName)
+		}
+	}
+	for i := range outposts {
+		outpost := &outposts[i]
+		if _, ok := managed[strings.ToLower(outpost.Name)]; ok {
+			continue
+		}
+		target := strings.EqualFold(outpost.Name, outpostName)
+		bound := []int{}
+		has := false
+		for _, p := range outpost.Providers {
+			if p == pk {
+				has = true
+				if !target {
+					continue
+				}
+			}
+			bound = append(bound, p)
+		}
+		if target && !has {
+			bound = append(bound, pk)
+		}
+		if target == has {
+			continue
+		}
+		outpost.Providers = bound
+		if _, err := akc.UpdateOutpost(ctx, outpost); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outpostURL is the in cluster url of the named outpost, the embedded outpost is served by the authentik
+// server itself, those managed by an AkOutpost by the service it creates, while others are deployed by authentik
+// as ak-outpost-<name> services.
+func outpostURL(values map[string]interface{}, akNamespace string, outpostName string, managed map[string]*akmv1a1.AkOutpost) string {
+	if outpostName == "" || strings.EqualFold(outpostName, embeddedOutpost) {
+		_, baseURL := utils.AuthentikEndpoint(values, akNamespace)
+		return baseURL
+	}
+	if owner, ok := managed[strings.ToLower(outpostName)]; ok {
+		return outpostServiceURL(owner)
+	}
+	slug := strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(outpostName), "-"), "-")
+	return fmt.Sprintf("http://ak-outpost-%v.%v.svc:9000", slug, akNamespace)
+}
+
+// nginxAnnotations are the ingress-nginx annotations that make an ingress ask the outpost to authenticate requests
+// https://docs.goauthentik.io/docs/add-secure-apps/providers/proxy/server_nginx
+func nginxAnnotations(outpost string, provider *akmv1a1.ProxyProvider) map[string]string {
+	return map[string]string{
+		"nginx.ingress.kubernetes.io/auth-url":              fmt.Sprintf("%v/outpost.goauthentik.io/auth/nginx", strings.TrimSuffix(outpost, "/")),
+		"nginx.ingress.kubernetes.io/auth-signin":           fmt.Sprintf("%v/outpost.goauthentik.io/start?rd=$scheme://$http_host$escaped_request_uri", strings.TrimSuffix(provider.ProtocolSettings.ExternalHost, "/")),
+		"nginx.ingress.kubernetes.io/auth-response-headers": authResponseHeaders,
+		"nginx.ingress.kubernetes.io/auth-snippet":          "proxy_set_header X-Forwarded-Host $http_host;",
+	}
+}
+
+// reconcileConfigmap creates or updates the configmap of an application to keep it in sync
+func (r *ProxyReconciler) reconcileConfigmap(ctx context.Context, configmap *corev1.ConfigMap) error {
+	err := r.Update(ctx, configmap)
+	if errors.IsNotFound(err) {
+		return r.Create(ctx, configmap)
+	}
+	return err
+}
+
+// configmapFromProxy generates the configmap holding the ingress annotations an application should use
+func (r *ProxyReconciler) configmapFromProxy(crd *akmv1a1.Proxy, application *akmv1a1.ProxyApplication, annotations map[string]string) *corev1.ConfigMap {
+	configmap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        application.ConfigMap.Name,
+			Namespace:   crd.Namespace,
+			Annotations: crd.Annotations,
+		},
+		Data: annotations,
+	}
+	ctrl.SetControllerReference(crd, configmap, r.Scheme)
+	return configmap
+}
+
+// proxyProviderBlueprint is the blueprint content of a proxy provider, optional flows and basic auth are
+// only set when given so authentik keeps its own defaults.
+func proxyProviderBlueprint(provider *akmv1a1.ProxyProvider) *blueprint.Blueprint {
+	settings := provider.ProtocolSettings
+	attrs := map[string]interface{}{
+		"name":                         provider.Name,
+		"authorization_flow":           blueprint.Find("authentik_flows.flow", "slug", provider.AuthorizationFlow),
+		"mode":                         settings.Mode,
+		"external_host":                settings.ExternalHost,
+		"internal_host":                settings.InternalHost,
+		"internal_host_ssl_validation": settings.InternalHostSSLValidation,
+		"cookie_domain":                settings.CookieDomain,
+		"skip_path_regex":              settings.SkipPathRegex,
+		"basic_auth_enabled":           settings.BasicAuth != nil,
+	}
+	if provider.AuthenticationFlow != "" {
+		attrs["authentication_flow"] = blueprint.Find("authentik_flows.flow", "slug", provider.AuthenticationFlow)
+	}
+	if settings.BasicAuth != nil {
+		attrs["basic_auth_user_attribute"] = settings.BasicAuth.UserAttribute
+		attrs["basic_auth_password_attribute"] = settings.BasicAuth.PasswordAttribute
+	}
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       "authentik_providers_proxy.proxyprovider",
+				State:       "present",
+				Identifiers: map[string]interface{}{"name": provider.Name},
+				Attrs:       attrs,
+			},
+		},
+	}
+}
+
+// proxyApplicationBlueprint is the blueprint content of an application using a proxy provider
+func proxyApplicationBlueprint(application *akmv1a1.ProxyApplication) *blueprint.Blueprint {
+	ui := application.ProxyApplicationUISettings
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       "authentik_core.application",
+				State:       "present",
+				Identifiers: map[string]interface{}{"slug": application.Slug},
+				Attrs: map[string]interface{}{
+					"name":               application.Name,
+					"slug":               application.Slug,
+					"group":              application.Group,
+					"policy_engine_mode": application.PolicyEngineMode,
+					"provider":           blueprint.Find("authentik_providers_proxy.proxyprovider", "name", application.Provider),
+					"meta_launch_url":    ui.LaunchURL,
+					"open_in_new_tab":    ui.OpenInNewTab,
+					"meta_icon":          ui.Icon,
+					"meta_publisher":     ui.Publisher,
+					"meta_description":   ui.Description,
+				},
+			},
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.Proxy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToProxy)).
+		Complete(r)
+}
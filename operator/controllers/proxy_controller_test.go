@@ -0,0 +1,204 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	akfake "gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik/fake"
+)
+
+func TestReconcileProxyBlueprints(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := &ProxyReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+	crd := &akmv1a1.Proxy{ObjectMeta: metav1.ObjectMeta{Name: "whoami", Namespace: "apps"}}
+	provider := &akmv1a1.ProxyProvider{
+		Name:              "whoami",
+		AuthorizationFlow: "default-provider-authorization-implicit-consent",
+		Outpost:           "authentik Embedded Outpost",
+		ProtocolSettings: akmv1a1.ProxyProviderProtocolSettings{
+			Mode:                      "forward_single",
+			ExternalHost:              "https://whoami.org.example",
+			InternalHostSSLValidation: true,
+			SkipPathRegex:             "^/health$",
+			BasicAuth:                 &akmv1a1.ProxyBasicAuth{PasswordAttribute: "whoami_password"},
+		},
+	}
+	application := &akmv1a1.ProxyApplication{Name: "Whoami", Slug: "whoami", Provider: "whoami", PolicyEngineMode: "any"}
+
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "apps-proxy-provider-whoami", proxyLabels(crd), proxyProviderBlueprint(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := r.ReconcileGeneratedBlueprint(ctx, "auth", "apps-proxy-app-whoami", proxyLabels(crd), proxyApplicationBlueprint(application))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := &akmv1a1.AkBlueprint{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "apps-proxy-provider-whoami", Namespace: "auth"}, got); err != nil {
+		t.Fatal(err)
+	}
+	content, err := blueprintContent(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"model: authentik_providers_proxy.proxyprovider",
+		"mode: forward_single",
+		"external_host: https://whoami.org.example",
+		"basic_auth_enabled: true",
+		"basic_auth_password_attribute: whoami_password",
+		"authorization_flow: !Find [authentik_flows.flow, [slug, default-provider-authorization-implicit-consent]]",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("provider blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	if strings.Contains(content, "authentication_flow") {
+		t.Errorf("provider blueprint sets authentication_flow which was not given:\n%v", content)
+	}
+
+	// the generated blueprints must also pass admission
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated provider blueprint is invalid: %v", err)
+	}
+	if err := app.Validate(); err != nil {
+		t.Errorf("generated application blueprint is invalid: %v", err)
+	}
+
+	reqs := r.akBlueprintToProxy(ctx, app)
+	if len(reqs) != 1 || reqs[0].Name != "whoami" || reqs[0].Namespace != "apps" {
+		t.Fatalf("expected request for apps/whoami, got %v", reqs)
+	}
+}
+
+func TestNginxAnnotations(t *testing.T) {
+	provider := &akmv1a1.ProxyProvider{ProtocolSettings: akmv1a1.ProxyProviderProtocolSettings{ExternalHost: "https://whoami.org.example/"}}
+
+	embedded := nginxAnnotations(outpostURL(nil, "auth", "authentik Embedded Outpost", nil), provider)
+	if got := embedded["nginx.ingress.kubernetes.io/auth-url"]; got != "http://authentik-server.auth.svc:80/outpost.goauthentik.io/auth/nginx" {
+		t.Errorf("auth-url = %q", got)
+	}
+	if got := embedded["nginx.ingress.kubernetes.io/auth-signin"]; got != "https://whoami.org.example/outpost.goauthentik.io/start?rd=$scheme://$http_host$escaped_request_uri" {
+		t.Errorf("auth-signin = %q", got)
+	}
+
+	external := nginxAnnotations(outpostURL(nil, "auth", "Internal Proxy", nil), provider)
+	if got := external["nginx.ingress.kubernetes.io/auth-url"]; got != "http://ak-outpost-internal-proxy.auth.svc:9000/outpost.goauthentik.io/auth/nginx" {
+		t.Errorf("auth-url = %q", got)
+	}
+
+	// outposts run by an AkOutpost are reached through the service it creates in its own namespace
+	managed := map[string]*akmv1a1.AkOutpost{
+		"internal proxy": {
+			ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "ingress"},
+			Spec:       akmv1a1.AkOutpostSpec{Name: "Internal Proxy", Type: "proxy"},
+		},
+	}
+	external = nginxAnnotations(outpostURL(nil, "auth", "Internal Proxy", managed), provider)
+	if got := external["nginx.ingress.kubernetes.io/auth-url"]; got != "http://ak-outpost-edge.ingress.svc:9000/outpost.goauthentik.io/auth/nginx" {
+		t.Errorf("auth-url = %q", got)
+	}
+}
+
+func TestBindOutpost(t *testing.T) {
+	ctx := context.Background()
+	srv := akfake.NewServer("token")
+	t.Cleanup(srv.Close)
+	akc, err := authentik.NewClient(srv.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := srv.Seed("providers/proxy", map[string]interface{}{"name": "whoami"})
+	pk := provider["pk"]
+	embedded := srv.Seed("outposts/instances", map[string]interface{}{"name": "authentik Embedded Outpost", "providers": []interface{}{7}})
+	internal := srv.Seed("outposts/instances", map[string]interface{}{"name": "internal", "providers": []interface{}{pk, 8}})
+
+	if err := bindOutpost(ctx, akc, "WHOAMI", "authentik embedded outpost", nil); err != nil {
+		t.Fatal(err)
+	}
+	providers := map[string]string{}
+	for _, outpost := range srv.Objects("outposts/instances") {
+		providers[fmt.Sprint(outpost["pk"])] = fmt.Sprint(outpost["providers"])
+	}
+	if got, want := providers[fmt.Sprint(embedded["pk"])], fmt.Sprint([]interface{}{7, pk}); got != want {
+		t.Errorf("embedded outpost providers = %v, want %v", got, want)
+	}
+	if got, want := providers[fmt.Sprint(internal["pk"])], "[8]"; got != want {
+		t.Errorf("internal outpost providers = %v, want %v", got, want)
+	}
+
+	// binding again changes nothing so nothing is written
+	before := len(srv.Requests())
+	if err := bindOutpost(ctx, akc, "whoami", "authentik Embedded Outpost", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range srv.Requests()[before:] {
+		if !strings.HasPrefix(req, "GET") {
+			t.Errorf("unexpected write %v binding an already bound provider", req)
+		}
+	}
+
+	if err := bindOutpost(ctx, akc, "whoami", "missing", nil); err == nil || !strings.Contains(err.Error(), "outpost `missing` not found") {
+		t.Errorf("expected a missing outpost error, got %v", err)
+	}
+	if got := fmt.Sprint(srv.Objects("outposts/instances")[0]["providers"]); got != fmt.Sprint([]interface{}{7, pk}) {
+		t.Errorf("binding to a missing outpost unbound the provider, embedded outpost providers = %v", got)
+	}
+	if err := bindOutpost(ctx, akc, "other", "internal", nil); err == nil || !strings.Contains(err.Error(), "proxy provider `other` not found") {
+		t.Errorf("expected a missing provider error, got %v", err)
+	}
+
+	// outposts run by an AkOutpost keep the providers it sets
+	managed := map[string]*akmv1a1.AkOutpost{
+		"internal": {
+			ObjectMeta: metav1.ObjectMeta{Name: "internal", Namespace: "apps"},
+			Spec:       akmv1a1.AkOutpostSpec{Type: "proxy", Providers: []string{"other"}},
+		},
+	}
+	before = len(srv.Requests())
+	if err := bindOutpost(ctx, akc, "whoami", "internal", managed); err == nil || !strings.Contains(err.Error(), "does not list provider `whoami`") {
+		t.Errorf("expected an unlisted provider error, got %v", err)
+	}
+	managed["internal"].Spec.Providers = append(managed["internal"].Spec.Providers, "whoami")
+	if err := bindOutpost(ctx, akc, "whoami", "internal", managed); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range srv.Requests()[before:] {
+		if !strings.HasPrefix(req, "GET") && strings.Contains(req, fmt.Sprint(internal["pk"])) {
+			t.Errorf("unexpected write %v to an outpost managed by an AkOutpost", req)
+		}
+	}
+	if got := fmt.Sprint(srv.Objects("outposts/instances")[0]["providers"]); got != "[7]" {
+		t.Errorf("expected the provider to move off the embedded outpost, its providers = %v", got)
+	}
+}
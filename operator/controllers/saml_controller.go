@@ -0,0 +1,316 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	klog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+	uhelm "gitlab.com/GeorgeRaven/authentik-manager/operator/utils/helm"
+)
+
+// Labels placed on generated AkBlueprints so they can be traced back to the SAML resource that generated them.
+const (
+	samlNameLabel      = "akm.goauthentik.io/saml"
+	samlNamespaceLabel = "akm.goauthentik.io/saml-namespace"
+)
+
+// SAMLReconciler reconciles a SAML object
+type SAMLReconciler struct {
+	utils.ControlBase
+}
+
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=samls,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=samls/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=akm.goauthentik.io,resources=samls/finalizers,verbs=update
+
+// Reconcile turns the providers and applications of a SAML resource into AkBlueprints in the authentik
+// namespace, and configmaps holding the SAML endpoints for the consuming applications in its own namespace.
+func (r *SAMLReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := klog.FromContext(ctx)
+
+	o := utils.Opts{}
+	arg.MustParse(&o)
+
+	// GET CRD
+	crd := &akmv1a1.SAML{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			l.Info("SAML resource reconciliation triggered but disappeared. Ignoring since object must have been finalized.")
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "Failed to get SAML resource. Likely fetch error. Retrying.")
+		return ctrl.Result{}, err
+	}
+	l.Info(fmt.Sprintf("Found SAML resource `%v` in `%v`.", crd.Name, crd.Namespace))
+
+	// AUTHENTIK INSTANCE
+	if crd.Spec.Instance.Namespace != o.OperatorNamespace {
+		l.Info(fmt.Sprintf("SAML resource reconciliation triggered but CRD specifies a different namespace to operator (operator namespace: %v, crd namespace: %v), Ignoring.", o.OperatorNamespace, crd.Spec.Instance.Namespace))
+		return ctrl.Result{}, nil
+	}
+
+	// FINALIZER
+	// generated blueprints live in the authentik namespace so are deleted by us rather than garbage collected
+	deleted, err := r.ReconcileGeneratorFinalizer(ctx, crd, finalizerName, o.OperatorNamespace, samlLabels(crd))
+	if err != nil || deleted {
+		return ctrl.Result{}, err
+	}
+
+	aks, err := r.ListAk(o.OperatorNamespace)
+	if err != nil {
+		l.Error(err, "Failed to get Authentik instance. Retrying.")
+		return ctrl.Result{}, err
+	}
+	if len(aks) > 1 {
+		return ctrl.Result{}, fmt.Errorf("more than one Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	} else if len(aks) == 0 {
+		return ctrl.Result{}, fmt.Errorf("no Authentik instance found in namespace `%v`", o.OperatorNamespace)
+	}
+	ak := aks[0]
+
+	oldStatus := crd.Status.DeepCopy()
+	crd.Status.ObservedGeneration = crd.Generation
+	crd.Status.Providers = []akmv1a1.SAMLProviderStatus{}
+	crd.Status.Applications = []akmv1a1.SAMLApplicationStatus{}
+	generated := []string{}
+
+	// PROVIDERS - generate a blueprint for each provider
+	for i := range crd.Spec.Providers {
+		provider := &crd.Spec.Providers[i]
+		bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-saml-provider-%v", crd.Namespace, provider.Name), samlLabels(crd), samlProviderBlueprint(provider))
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+		}
+		generated = append(generated, bp.Name)
+		crd.Status.Providers = append(crd.Status.Providers, akmv1a1.SAMLProviderStatus{
+			Name:             provider.Name,
+			AkBlueprint:      fmt.Sprintf("%v/%v", bp.Namespace, bp.Name),
+			BlueprintApplied: meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady),
+		})
+	}
+
+	// APPLICATIONS - generate a configmap and blueprint for each application
+	akfqdn, err := uhelm.GetAkFQDN(ak)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "ConfigMapFailed", err)
+	}
+	for i := range crd.Spec.Applications {
+		application := &crd.Spec.Applications[i]
+		configmap := r.configmapFromSAML(akfqdn, crd, application)
+		err = r.reconcileConfigmap(ctx, configmap)
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "ConfigMapFailed", err)
+		}
+		bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, fmt.Sprintf("%v-saml-app-%v", crd.Namespace, application.Slug), samlLabels(crd), samlApplicationBlueprint(application))
+		if err != nil {
+			return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+		}
+		generated = append(generated, bp.Name)
+		crd.Status.Applications = append(crd.Status.Applications, akmv1a1.SAMLApplicationStatus{
+			Slug:             application.Slug,
+			ConfigMap:        configmap.Name,
+			AkBlueprint:      fmt.Sprintf("%v/%v", bp.Namespace, bp.Name),
+			BlueprintApplied: meta.IsStatusConditionTrue(bp.Status.Conditions, akmv1a1.ConditionReady),
+		})
+	}
+
+	// PRUNE - blueprints of providers and applications no longer in the spec
+	err = r.DeleteGeneratedBlueprints(ctx, ak.Namespace, samlLabels(crd), generated...)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(ctx, crd, "BlueprintFailed", err)
+	}
+
+	// STATUS
+	setSAMLReadyCondition(&crd.Status, crd.Generation)
+	if !equality.Semantic.DeepEqual(oldStatus, &crd.Status) {
+		err = r.Status().Update(ctx, crd)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setSAMLReadyCondition sets the Ready condition based on whether every generated blueprint has been applied
+func setSAMLReadyCondition(status *akmv1a1.SAMLStatus, generation int64) {
+	pending := []string{}
+	for _, provider := range status.Providers {
+		if !provider.BlueprintApplied {
+			pending = append(pending, provider.AkBlueprint)
+		}
+	}
+	for _, application := range status.Applications {
+		if !application.BlueprintApplied {
+			pending = append(pending, application.AkBlueprint)
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, utils.BlueprintsReadyCondition(pending, generation))
+}
+
+// setFailedStatus marks the SAML resource as not ready due to the given error and returns the error
+// so it can be passed straight back to the controller-runtime for a retry.
+func (r *SAMLReconciler) setFailedStatus(ctx context.Context, crd *akmv1a1.SAML, reason string, err error) error {
+	l := klog.FromContext(ctx)
+	meta.SetStatusCondition(&crd.Status.Conditions, metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: crd.Generation,
+	})
+	if uerr := r.Status().Update(ctx, crd); uerr != nil {
+		l.Error(uerr, fmt.Sprintf("Failed to update status of SAML `%v` in `%v`.", crd.Name, crd.Namespace))
+	}
+	return err
+}
+
+// samlLabels are the labels that mark a generated resource as belonging to the given SAML
+func samlLabels(crd *akmv1a1.SAML) map[string]string {
+	return map[string]string{
+		samlNameLabel:      crd.Name,
+		samlNamespaceLabel: crd.Namespace,
+	}
+}
+
+// akBlueprintToSAML maps a generated AkBlueprint back to the SAML resource that generated it
+func (r *SAMLReconciler) akBlueprintToSAML(ctx context.Context, obj client.Object) []reconcile.Request {
+	return utils.GeneratedBlueprintRequests(obj, samlNameLabel, samlNamespaceLabel)
+}
+
+// reconcileConfigmap creates or updates the configmap of an application to keep it in sync
+func (r *SAMLReconciler) reconcileConfigmap(ctx context.Context, configmap *corev1.ConfigMap) error {
+	err := r.Update(ctx, configmap)
+	if errors.IsNotFound(err) {
+		return r.Create(ctx, configmap)
+	}
+	return err
+}
+
+// configmapFromSAML generates the configmap that tells an application where to find authentik as its IdP
+func (r *SAMLReconciler) configmapFromSAML(akfqdn string, crd *akmv1a1.SAML, application *akmv1a1.SAMLApplication) *corev1.ConfigMap {
+	configmap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        application.ConfigMap.Name,
+			Namespace:   crd.Namespace,
+			Annotations: crd.Annotations,
+		},
+		Data: samlURLs(akfqdn, application.Slug),
+	}
+	ctrl.SetControllerReference(crd, configmap, r.Scheme)
+	return configmap
+}
+
+// samlURLs are the IdP endpoints authentik serves for a SAML application
+// https://docs.goauthentik.io/docs/add-secure-apps/providers/saml/
+func samlURLs(akfqdn string, slug string) map[string]string {
+	return map[string]string{
+		"metadataURL": fmt.Sprintf("https://%v/application/saml/%v/metadata/", akfqdn, slug),
+		"ssoURL":      fmt.Sprintf("https://%v/application/saml/%v/sso/binding/redirect/", akfqdn, slug),
+		"ssoPostURL":  fmt.Sprintf("https://%v/application/saml/%v/sso/binding/post/", akfqdn, slug),
+		"ssoInitURL":  fmt.Sprintf("https://%v/application/saml/%v/sso/binding/init/", akfqdn, slug),
+		"sloURL":      fmt.Sprintf("https://%v/application/saml/%v/slo/binding/redirect/", akfqdn, slug),
+		"sloPostURL":  fmt.Sprintf("https://%v/application/saml/%v/slo/binding/post/", akfqdn, slug),
+	}
+}
+
+// samlProviderBlueprint is the blueprint content of a SAML provider, optional certificates and mappings are
+// only set when given so authentik keeps its own defaults.
+func samlProviderBlueprint(provider *akmv1a1.SAMLProvider) *blueprint.Blueprint {
+	settings := provider.ProtocolSettings
+	attrs := map[string]interface{}{
+		"name":               provider.Name,
+		"authorization_flow": blueprint.Find("authentik_flows.flow", "slug", provider.AuthorizationFlow),
+		"acs_url":            settings.ACSURL,
+		"audience":           settings.Audience,
+		"issuer":             settings.Issuer,
+		"sp_binding":         settings.SPBinding,
+	}
+	if provider.AuthenticationFlow != "" {
+		attrs["authentication_flow"] = blueprint.Find("authentik_flows.flow", "slug", provider.AuthenticationFlow)
+	}
+	if settings.SigningCertificate != "" {
+		attrs["signing_kp"] = blueprint.Find("authentik_crypto.certificatekeypair", "name", settings.SigningCertificate)
+	}
+	if settings.VerificationCertificate != "" {
+		attrs["verification_kp"] = blueprint.Find("authentik_crypto.certificatekeypair", "name", settings.VerificationCertificate)
+	}
+	if settings.NameIDPropertyMapping != "" {
+		attrs["name_id_mapping"] = blueprint.Find("authentik_providers_saml.samlpropertymapping", "name", settings.NameIDPropertyMapping)
+	}
+	if len(settings.PropertyMappings) > 0 {
+		attrs["property_mappings"] = propertyMappingFinds(propertyMappingModels["saml"], settings.PropertyMappings)
+	}
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       "authentik_providers_saml.samlprovider",
+				State:       "present",
+				Identifiers: map[string]interface{}{"name": provider.Name},
+				Attrs:       attrs,
+			},
+		},
+	}
+}
+
+// samlApplicationBlueprint is the blueprint content of an application using a SAML provider
+func samlApplicationBlueprint(application *akmv1a1.SAMLApplication) *blueprint.Blueprint {
+	ui := application.SAMLApplicationUISettings
+	return &blueprint.Blueprint{
+		Version: 1,
+		Entries: []blueprint.Entry{
+			{
+				Model:       "authentik_core.application",
+				State:       "present",
+				Identifiers: map[string]interface{}{"slug": application.Slug},
+				Attrs: map[string]interface{}{
+					"name":               application.Name,
+					"slug":               application.Slug,
+					"group":              application.Group,
+					"policy_engine_mode": application.PolicyEngineMode,
+					"provider":           blueprint.Find("authentik_providers_saml.samlprovider", "name", application.Provider),
+					"meta_launch_url":    ui.LaunchURL,
+					"open_in_new_tab":    ui.OpenInNewTab,
+					"meta_icon":          ui.Icon,
+					"meta_publisher":     ui.Publisher,
+					"meta_description":   ui.Description,
+				},
+			},
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SAMLReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akmv1a1.SAML{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&akmv1a1.AkBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.akBlueprintToSAML)).
+		Complete(r)
+}
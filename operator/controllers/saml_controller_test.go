@@ -0,0 +1,130 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils"
+)
+
+func TestSAMLURLs(t *testing.T) {
+	urls := samlURLs("auth.org.example", "wiki")
+	want := map[string]string{
+		"metadataURL": "https://auth.org.example/application/saml/wiki/metadata/",
+		"ssoURL":      "https://auth.org.example/application/saml/wiki/sso/binding/redirect/",
+		"sloURL":      "https://auth.org.example/application/saml/wiki/slo/binding/redirect/",
+	}
+	for k, v := range want {
+		if urls[k] != v {
+			t.Errorf("%v = %q, want %q", k, urls[k], v)
+		}
+	}
+}
+
+func TestReconcileSAMLBlueprints(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := &SAMLReconciler{ControlBase: utils.ControlBase{Client: c, Scheme: scheme}}
+	ak := &akmv1a1.Ak{ObjectMeta: metav1.ObjectMeta{Name: "ak", Namespace: "auth"}}
+	crd := &akmv1a1.SAML{ObjectMeta: metav1.ObjectMeta{Name: "wiki", Namespace: "apps"}}
+	provider := &akmv1a1.SAMLProvider{
+		Name:              "wiki",
+		AuthorizationFlow: "default-provider-authorization-implicit-consent",
+		ProtocolSettings: akmv1a1.SAMLProviderProtocolSettings{
+			ACSURL:             "https://wiki.org.example/saml/acs",
+			Issuer:             "authentik",
+			SPBinding:          "post",
+			SigningCertificate: "authentik Self-signed Certificate",
+		},
+	}
+	application := &akmv1a1.SAMLApplication{Name: "Wiki", Slug: "wiki", Provider: "wiki", PolicyEngineMode: "any"}
+
+	bp, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, "apps-saml-provider-wiki", samlLabels(crd), samlProviderBlueprint(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReconcileGeneratedBlueprint(ctx, ak.Namespace, "apps-saml-app-wiki", samlLabels(crd), samlApplicationBlueprint(application)); err != nil {
+		t.Fatal(err)
+	}
+
+	got := &akmv1a1.AkBlueprint{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "apps-saml-provider-wiki", Namespace: "auth"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec.File != "/blueprints/operator/apps-saml-provider-wiki.yaml" || got.Labels[samlNameLabel] != "wiki" || got.Labels[samlNamespaceLabel] != "apps" {
+		t.Errorf("generated blueprint = %+v", got.ObjectMeta)
+	}
+	content, err := blueprintContent(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"model: authentik_providers_saml.samlprovider",
+		"acs_url: https://wiki.org.example/saml/acs",
+		"sp_binding: post",
+		"signing_kp: !Find [authentik_crypto.certificatekeypair, [name, authentik Self-signed Certificate]]",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("provider blueprint does not contain %q:\n%v", want, content)
+		}
+	}
+	for _, unset := range []string{"verification_kp", "name_id_mapping", "authentication_flow"} {
+		if strings.Contains(content, unset) {
+			t.Errorf("provider blueprint sets %v which was not given:\n%v", unset, content)
+		}
+	}
+
+	// the generated blueprints must also pass admission
+	if err := bp.Validate(); err != nil {
+		t.Errorf("generated provider blueprint is invalid: %v", err)
+	}
+	app := &akmv1a1.AkBlueprint{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "apps-saml-app-wiki", Namespace: "auth"}, app); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Validate(); err != nil {
+		t.Errorf("generated application blueprint is invalid: %v", err)
+	}
+
+	reqs := r.akBlueprintToSAML(ctx, app)
+	if len(reqs) != 1 || reqs[0].Name != "wiki" || reqs[0].Namespace != "apps" {
+		t.Fatalf("expected request for apps/wiki, got %v", reqs)
+	}
+
+	// the application is removed from the spec so only the provider blueprint is kept
+	if err := r.DeleteGeneratedBlueprints(ctx, ak.Namespace, samlLabels(crd), "apps-saml-provider-wiki"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "apps-saml-app-wiki", Namespace: "auth"}, app); !errors.IsNotFound(err) {
+		t.Errorf("expected the application blueprint to be pruned, got %v", err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "apps-saml-provider-wiki", Namespace: "auth"}, got); err != nil {
+		t.Errorf("expected the provider blueprint to be kept, got %v", err)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	akmv1alpha1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
 	"gitlab.com/GeorgeRaven/authentik-manager/operator/controllers"
@@ -65,6 +66,10 @@ func main() {
 			BindAddress: o.MetricsAddr,
 		},
 		//Port:                   o.Port,
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Port:    o.Port,
+			CertDir: o.WebhookCertDir,
+		}),
 		HealthProbeBindAddress: o.ProbeAddr,
 		LeaderElection:         o.EnableLeaderElection,
 		LeaderElectionID:       o.LeaderElectionID,
@@ -126,6 +131,120 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "OIDC")
 		os.Exit(1)
 	}
+	if err = (&controllers.SAMLReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SAML")
+		os.Exit(1)
+	}
+	if err = (&controllers.ProxyReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Proxy")
+		os.Exit(1)
+	}
+	if err = (&controllers.LDAPProviderReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "LDAPProvider")
+		os.Exit(1)
+	}
+	if err = (&controllers.AkGroupReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AkGroup")
+		os.Exit(1)
+	}
+	if err = (&controllers.AkUserReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AkUser")
+		os.Exit(1)
+	}
+	if err = (&controllers.AkFlowReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AkFlow")
+		os.Exit(1)
+	}
+	if err = (&controllers.AkPolicyReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AkPolicy")
+		os.Exit(1)
+	}
+	if err = (&controllers.AkCertificateReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AkCertificate")
+		os.Exit(1)
+	}
+	if err = (&controllers.AkBrandReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AkBrand")
+		os.Exit(1)
+	}
+	if err = (&controllers.AkOutpostReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AkOutpost")
+		os.Exit(1)
+	}
+	if err = (&controllers.AkSourceReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AkSource")
+		os.Exit(1)
+	}
+	if err = (&controllers.AkPropertyMappingReconciler{
+		ControlBase: utils.ControlBase{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AkPropertyMapping")
+		os.Exit(1)
+	}
+	if o.EnableWebhooks {
+		if err = (&akmv1alpha1.AkBlueprint{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "AkBlueprint")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
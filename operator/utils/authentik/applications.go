@@ -0,0 +1,59 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"context"
+	"net/url"
+)
+
+const applicationsPath = "core/applications"
+
+// Application is an authentik application, identified by its slug
+// https://docs.goauthentik.io/docs/applications/
+type Application struct {
+	PK                   string `json:"pk,omitempty"`
+	Name                 string `json:"name"`
+	Slug                 string `json:"slug"`
+	Provider             *int   `json:"provider"`
+	BackchannelProviders []int  `json:"backchannel_providers,omitempty"`
+	Group                string `json:"group,omitempty"`
+	PolicyEngineMode     string `json:"policy_engine_mode,omitempty"`
+	MetaLaunchURL        string `json:"meta_launch_url,omitempty"`
+	MetaDescription      string `json:"meta_description,omitempty"`
+	MetaPublisher        string `json:"meta_publisher,omitempty"`
+	OpenInNewTab         bool   `json:"open_in_new_tab"`
+}
+
+// ListApplications lists applications matching the query
+func (c *Client) ListApplications(ctx context.Context, query url.Values) ([]Application, error) {
+	return list[Application](ctx, c, applicationsPath, query)
+}
+
+// GetApplication gets the application with the given slug
+func (c *Client) GetApplication(ctx context.Context, slug string) (*Application, error) {
+	return get[Application](ctx, c, objectPath(applicationsPath, slug))
+}
+
+// CreateApplication creates a new application
+func (c *Client) CreateApplication(ctx context.Context, app *Application) (*Application, error) {
+	return create(ctx, c, applicationsPath, app)
+}
+
+// UpdateApplication replaces the application with the same slug
+func (c *Client) UpdateApplication(ctx context.Context, app *Application) (*Application, error) {
+	return update(ctx, c, objectPath(applicationsPath, app.Slug), app)
+}
+
+// DeleteApplication deletes the application with the given slug
+func (c *Client) DeleteApplication(ctx context.Context, slug string) error {
+	return remove(ctx, c, objectPath(applicationsPath, slug))
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	blueprintsPath      = "managed/blueprints"
+	blueprintImportPath = "flows/instances/import"
+)
+
+// BlueprintInstance is authentiks record of a blueprint, identified by its uuid
+// https://docs.goauthentik.io/developer-docs/blueprints/
+type BlueprintInstance struct {
+	PK              string                 `json:"pk,omitempty"`
+	Name            string                 `json:"name"`
+	Path            string                 `json:"path,omitempty"`
+	Context         map[string]interface{} `json:"context,omitempty"`
+	Enabled         bool                   `json:"enabled"`
+	Content         string                 `json:"content,omitempty"`
+	Status          string                 `json:"status,omitempty"`
+	LastApplied     time.Time              `json:"last_applied,omitempty"`
+	LastAppliedHash string                 `json:"last_applied_hash,omitempty"`
+	ManagedModels   []string               `json:"managed_models,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ListBlueprints lists blueprint instances matching the query e.g. url.Values{"path": {"operator/app.yaml"}}
+func (c *Client) ListBlueprints(ctx context.Context, query url.Values) ([]BlueprintInstance, error) {
+	return list[BlueprintInstance](ctx, c, blueprintsPath, query)
+}
+
+// GetBlueprint gets the blueprint instance with the given uuid
+func (c *Client) GetBlueprint(ctx context.Context, pk string) (*BlueprintInstance, error) {
+	return get[BlueprintInstance](ctx, c, objectPath(blueprintsPath, pk))
+}
+
+// CreateBlueprint creates a new blueprint instance
+func (c *Client) CreateBlueprint(ctx context.Context, bp *BlueprintInstance) (*BlueprintInstance, error) {
+	return create(ctx, c, blueprintsPath, bp)
+}
+
+// UpdateBlueprint replaces the blueprint instance with the same uuid
+func (c *Client) UpdateBlueprint(ctx context.Context, bp *BlueprintInstance) (*BlueprintInstance, error) {
+	return update(ctx, c, objectPath(blueprintsPath, bp.PK), bp)
+}
+
+// DeleteBlueprint deletes the blueprint instance with the given uuid
+func (c *Client) DeleteBlueprint(ctx context.Context, pk string) error {
+	return remove(ctx, c, objectPath(blueprintsPath, pk))
+}
+
+// ApplyBlueprint asks authentik to apply the blueprint instance with the given uuid now
+func (c *Client) ApplyBlueprint(ctx context.Context, pk string) (*BlueprintInstance, error) {
+	out := &BlueprintInstance{}
+	err := c.Do(ctx, http.MethodPost, objectPath(blueprintsPath, pk)+"/apply", nil, nil, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BlueprintLog is a single log event authentik emits while importing a blueprint
+type BlueprintLog struct {
+	LogLevel   string                 `json:"log_level"`
+	Event      string                 `json:"event"`
+	Logger     string                 `json:"logger,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// BlueprintImportResult is authentiks response to importing a blueprint
+type BlueprintImportResult struct {
+	Logs    []BlueprintLog `json:"logs"`
+	Success bool           `json:"success"`
+}
+
+// ImportBlueprint has authentik validate and apply the given blueprint content immediately.
+// Unlike blueprint instances the content is not stored by authentik, so it resolves every YAML tag
+// but will not be reapplied on authentiks own schedule. A failed import is reported through the result
+// rather than as an error, with the reasons in its logs.
+func (c *Client) ImportBlueprint(ctx context.Context, content string) (*BlueprintImportResult, error) {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("file", "blueprint.yaml")
+	if err != nil {
+		return nil, err
+	}
+	_, err = part.Write([]byte(content))
+	if err != nil {
+		return nil, err
+	}
+	err = form.Close()
+	if err != nil {
+		return nil, err
+	}
+	out := &BlueprintImportResult{}
+	err = c.do(ctx, http.MethodPost, blueprintImportPath, nil, body.Bytes(), form.FormDataContentType(), out)
+	// authentik responds 400 with the same result body when the blueprint is invalid
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+		if json.Unmarshal([]byte(apiErr.Body), out) == nil && out.Logs != nil {
+			return out, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
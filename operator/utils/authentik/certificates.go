@@ -0,0 +1,49 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"context"
+	"net/url"
+)
+
+const certificateKeyPairsPath = "crypto/certificatekeypairs"
+
+// CertificateKeyPair is an authentik certificate and its private key, identified by its uuid. The PEM data is only
+// written, authentik does not return it when reading keypairs.
+// https://docs.goauthentik.io/docs/sys-mgmt/certificates
+type CertificateKeyPair struct {
+	PK              string `json:"pk,omitempty"`
+	Name            string `json:"name"`
+	CertificateData string `json:"certificate_data,omitempty"`
+	KeyData         string `json:"key_data,omitempty"`
+}
+
+// ListCertificateKeyPairs lists certificate keypairs matching the query e.g. url.Values{"name": {"my-signing-key"}}
+func (c *Client) ListCertificateKeyPairs(ctx context.Context, query url.Values) ([]CertificateKeyPair, error) {
+	return list[CertificateKeyPair](ctx, c, certificateKeyPairsPath, query)
+}
+
+// CreateCertificateKeyPair uploads a new certificate keypair
+func (c *Client) CreateCertificateKeyPair(ctx context.Context, keypair *CertificateKeyPair) (*CertificateKeyPair, error) {
+	return create(ctx, c, certificateKeyPairsPath, keypair)
+}
+
+// PatchCertificateKeyPair updates only the fields set on the certificate keypair with the same uuid, such as its
+// PEM data when it is renewed
+func (c *Client) PatchCertificateKeyPair(ctx context.Context, keypair *CertificateKeyPair) (*CertificateKeyPair, error) {
+	return patch(ctx, c, objectPath(certificateKeyPairsPath, keypair.PK), keypair)
+}
+
+// DeleteCertificateKeyPair deletes the certificate keypair with the given uuid
+func (c *Client) DeleteCertificateKeyPair(ctx context.Context, pk string) error {
+	return remove(ctx, c, objectPath(certificateKeyPairsPath, pk))
+}
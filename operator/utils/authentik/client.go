@@ -0,0 +1,248 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+// Package authentik implements a small typed client for the authentik REST API.
+// It covers only the endpoints the operator needs, and authenticates with the bootstrap token
+// from the release secret. https://docs.goauthentik.io/developer-docs/api/
+package authentik
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// APIPath is the path of the authentik v3 API relative to the authentik server
+const APIPath = "/api/v3/"
+
+// Client talks to a single authentik instance.
+type Client struct {
+	// BaseURL is the root of the authentik server e.g. http://authentik-server.auth.svc
+	BaseURL *url.URL
+	// Token is the API token sent as a bearer token with every request
+	Token string
+	// HTTPClient is the client used to make requests
+	HTTPClient *http.Client
+	// Retries is how many times a failed request is retried when the failure is likely transient
+	Retries int
+	// Backoff is the wait before the first retry, doubling after each one
+	Backoff time.Duration
+}
+
+// NewClient creates a client for the authentik server at baseURL using the given API token.
+func NewClient(baseURL string, token string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("authentik base url `%v` must include scheme and host", baseURL)
+	}
+	return &Client{
+		BaseURL:    u,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Retries:    3,
+		Backoff:    500 * time.Millisecond,
+	}, nil
+}
+
+// paginated is the envelope authentik wraps all list responses in
+type paginated[T any] struct {
+	Pagination struct {
+		Next       float64 `json:"next"`
+		Current    float64 `json:"current"`
+		TotalPages float64 `json:"total_pages"`
+	} `json:"pagination"`
+	Results []T `json:"results"`
+}
+
+// list fetches every page of a list endpoint filtered by the given query.
+func list[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, error) {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	results := []T{}
+	page := 1
+	for {
+		q.Set("page", fmt.Sprint(page))
+		out := &paginated[T]{}
+		err := c.Do(ctx, http.MethodGet, path, q, nil, out)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, out.Results...)
+		if out.Pagination.Next == 0 || int(out.Pagination.Next) <= page {
+			return results, nil
+		}
+		page = int(out.Pagination.Next)
+	}
+}
+
+// Do sends a request to the API path relative to /api/v3/, encoding in as the JSON body if not nil
+// and decoding the JSON response into out if not nil. Non 2xx responses are returned as *APIError.
+func (c *Client) Do(ctx context.Context, method string, path string, query url.Values, in interface{}, out interface{}) error {
+	var body []byte
+	contentType := ""
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+	return c.do(ctx, method, path, query, body, contentType, out)
+}
+
+// do sends an already encoded body, retrying failures that are likely transient
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body []byte, contentType string, out interface{}) error {
+	u := c.BaseURL.JoinPath(APIPath, path)
+	// authentik routes all end in a slash and redirect otherwise which loses the body
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path = u.Path + "/"
+	}
+	u.RawQuery = query.Encode()
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, u.String(), body, contentType, out)
+		if err == nil || attempt >= c.Retries || !retryable(method, err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = backoff * 2
+	}
+}
+
+// send makes a single attempt at a request
+func (c *Client) send(ctx context.Context, method string, u string, body []byte, contentType string, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return &transportError{err: err}
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &transportError{err: err}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(method, req.URL.Path, resp.StatusCode, respBody)
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// retryable decides if an error is worth trying again. Requests that authentik may have acted on
+// are only retried when repeating them is harmless.
+func retryable(method string, err error) bool {
+	idempotent := method != http.MethodPost && method != http.MethodPatch
+	switch e := err.(type) {
+	case *transportError:
+		return idempotent
+	case *APIError:
+		switch e.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		case http.StatusBadGateway, http.StatusGatewayTimeout, http.StatusInternalServerError:
+			return idempotent
+		}
+	}
+	return false
+}
+
+// transportError wraps failures to reach authentik at all
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return fmt.Sprintf("authentik unreachable: %v", e.err)
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// get fetches a single object
+func get[T any](ctx context.Context, c *Client, path string) (*T, error) {
+	out := new(T)
+	err := c.Do(ctx, http.MethodGet, path, nil, nil, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// create posts a new object returning what authentik stored
+func create[T any](ctx context.Context, c *Client, path string, in *T) (*T, error) {
+	out := new(T)
+	err := c.Do(ctx, http.MethodPost, path, nil, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// update replaces an existing object returning what authentik stored
+func update[T any](ctx context.Context, c *Client, path string, in *T) (*T, error) {
+	out := new(T)
+	err := c.Do(ctx, http.MethodPut, path, nil, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// patch updates only the fields given of an existing object returning what authentik stored
+func patch[T any](ctx context.Context, c *Client, path string, in *T) (*T, error) {
+	out := new(T)
+	err := c.Do(ctx, http.MethodPatch, path, nil, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// remove deletes a single object
+func remove(ctx context.Context, c *Client, path string) error {
+	return c.Do(ctx, http.MethodDelete, path, nil, nil, nil)
+}
+
+// objectPath joins a collection path with an object id
+func objectPath(collection string, id interface{}) string {
+	return collection + "/" + url.PathEscape(fmt.Sprint(id))
+}
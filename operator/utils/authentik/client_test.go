@@ -0,0 +1,274 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik/fake"
+)
+
+const token = "test-token"
+
+// newTestClient starts a fake authentik and a client pointed at it with fast retries
+func newTestClient(t *testing.T) (*authentik.Client, *fake.Server) {
+	t.Helper()
+	srv := fake.NewServer(token)
+	t.Cleanup(srv.Close)
+	c, err := authentik.NewClient(srv.URL, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Backoff = time.Millisecond
+	return c, srv
+}
+
+func TestNewClientRequiresAbsoluteURL(t *testing.T) {
+	if _, err := authentik.NewClient("authentik-server.auth.svc", token); err == nil {
+		t.Fatal("expected an error for a base url without a scheme")
+	}
+}
+
+func TestFlowCRUD(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	created, err := c.CreateFlow(ctx, &authentik.Flow{Slug: "login", Name: "Login", Title: "Welcome", Designation: "authentication"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.PK == "" {
+		t.Fatal("expected authentik to assign a primary key")
+	}
+	created.Title = "Hello"
+	if _, err := c.UpdateFlow(ctx, created); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetFlow(ctx, "login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Hello" {
+		t.Fatalf("title = %q, want %q", got.Title, "Hello")
+	}
+	if err := c.DeleteFlow(ctx, "login"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetFlow(ctx, "login")
+	if !authentik.IsNotFound(err) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+}
+
+func TestListFollowsPagination(t *testing.T) {
+	ctx := context.Background()
+	c, srv := newTestClient(t)
+	srv.PageSize = 2
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		srv.Seed("core/groups", map[string]interface{}{"name": name, "is_superuser": name == "c"})
+	}
+
+	groups, err := c.ListGroups(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 5 {
+		t.Fatalf("got %d groups across pages, want 5", len(groups))
+	}
+	supers, err := c.ListGroups(ctx, url.Values{"is_superuser": {"true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(supers) != 1 || supers[0].Name != "c" {
+		t.Fatalf("filtered groups = %+v, want only c", supers)
+	}
+}
+
+func TestProvidersAcrossTypes(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	p, err := c.CreateOAuth2Provider(ctx, &authentik.OAuth2Provider{Name: "grafana", AuthorizationFlow: "flow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := c.ListProviders(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].PK != p.PK {
+		t.Fatalf("providers = %+v, want the oauth2 provider %d", all, p.PK)
+	}
+	if err := c.DeleteProvider(ctx, p.PK); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetOAuth2Provider(ctx, p.PK); !authentik.IsNotFound(err) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+}
+
+func TestAPIErrorFields(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	if _, err := c.CreateApplication(ctx, &authentik.Application{Name: "Grafana", Slug: "grafana"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.CreateApplication(ctx, &authentik.Application{Name: "Grafana", Slug: "grafana"})
+	if !authentik.IsBadRequest(err) {
+		t.Fatalf("expected bad request for a duplicate slug, got %v", err)
+	}
+	apiErr := err.(*authentik.APIError)
+	if len(apiErr.Fields["slug"]) != 1 {
+		t.Fatalf("expected a slug field error, got %+v", apiErr.Fields)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	c.Token = "wrong"
+	_, err := c.ListUsers(ctx, nil)
+	if !authentik.IsUnauthorized(err) {
+		t.Fatalf("expected unauthorized, got %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("get retries unavailable", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		srv.Fail(http.StatusServiceUnavailable, http.StatusBadGateway)
+		if _, err := c.ListUsers(ctx, nil); err != nil {
+			t.Fatalf("expected retries to recover, got %v", err)
+		}
+		if n := len(srv.Requests()); n != 3 {
+			t.Fatalf("made %d requests, want 3", n)
+		}
+	})
+
+	t.Run("post does not retry server errors", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		srv.Fail(http.StatusInternalServerError)
+		_, err := c.CreateUser(ctx, &authentik.User{Username: "jane", Name: "Jane"})
+		if err == nil {
+			t.Fatal("expected the server error to be returned")
+		}
+		if n := len(srv.Requests()); n != 1 {
+			t.Fatalf("made %d requests, want 1", n)
+		}
+	})
+
+	t.Run("gives up after retries", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		c.Retries = 1
+		srv.Fail(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		if _, err := c.ListUsers(ctx, nil); err == nil {
+			t.Fatal("expected an error once retries are exhausted")
+		}
+		if n := len(srv.Requests()); n != 2 {
+			t.Fatalf("made %d requests, want 2", n)
+		}
+	})
+}
+
+func TestImportBlueprint(t *testing.T) {
+	ctx := context.Background()
+	c, srv := newTestClient(t)
+
+	content := "version: 1\nentries:\n- model: authentik_core.group\n  identifiers:\n    name: !Format [\"%s-admins\", team]\n"
+	result, err := c.ImportBlueprint(ctx, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || len(result.Logs) == 0 {
+		t.Fatalf("import result = %+v, want success with logs", result)
+	}
+	if imports := srv.Imports(); len(imports) != 1 || imports[0] != content {
+		t.Fatalf("authentik received %q, want the blueprint unchanged", imports)
+	}
+
+	result, err = c.ImportBlueprint(ctx, "")
+	if err != nil {
+		t.Fatalf("expected a failed import to be a result not an error, got %v", err)
+	}
+	if result.Success {
+		t.Fatal("expected an empty blueprint to fail to import")
+	}
+}
+
+func TestViewTokenKey(t *testing.T) {
+	ctx := context.Background()
+	c, srv := newTestClient(t)
+	srv.HandleFunc("core/tokens/ak-outpost-1234-api/view_key", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"key": "s3cr3t"}`))
+	})
+
+	key, err := c.ViewTokenKey(ctx, "ak-outpost-1234-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "s3cr3t" {
+		t.Fatalf("key = %q, want s3cr3t", key)
+	}
+	if _, err := c.ViewTokenKey(ctx, "missing"); !authentik.IsNotFound(err) {
+		t.Fatalf("expected not found for a missing token, got %v", err)
+	}
+}
+
+func TestSetTokenKey(t *testing.T) {
+	ctx := context.Background()
+	c, srv := newTestClient(t)
+	got := map[string]string{}
+	srv.HandleFunc("core/tokens/akm-default-some-user/set_key", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %v, want POST", r.Method)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := c.SetTokenKey(ctx, "akm-default-some-user", "s3cr3t"); err != nil {
+		t.Fatal(err)
+	}
+	if got["key"] != "s3cr3t" {
+		t.Fatalf("key = %q, want s3cr3t", got["key"])
+	}
+}
+
+func TestPatchOAuthSource(t *testing.T) {
+	ctx := context.Background()
+	c, srv := newTestClient(t)
+
+	if _, err := c.CreateOAuthSource(ctx, &authentik.OAuthSource{Name: "GitHub", Slug: "github", ProviderType: "github", ConsumerKey: "id", ConsumerSecret: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PatchOAuthSource(ctx, &authentik.OAuthSource{Slug: "github", ConsumerKey: "id", ConsumerSecret: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	// the patch replaces the credentials only
+	objs := srv.Objects("sources/oauth")
+	if len(objs) != 1 || objs[0]["consumer_secret"] != "new" || objs[0]["provider_type"] != "github" {
+		t.Fatalf("unexpected sources after patch %v", objs)
+	}
+	if err := c.SetSourceIconURL(ctx, "github", "https://github.com/favicon.ico"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PatchOAuthSource(ctx, &authentik.OAuthSource{Slug: "missing"}); !authentik.IsNotFound(err) {
+		t.Fatalf("expected not found for a missing source, got %v", err)
+	}
+}
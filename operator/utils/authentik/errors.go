@@ -0,0 +1,106 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// APIError is returned whenever authentik responds with a non 2xx status.
+type APIError struct {
+	// StatusCode is the HTTP status authentik responded with
+	StatusCode int
+	// Method and Path identify the request that failed
+	Method string
+	Path   string
+	// Detail is the human readable reason authentik gave, if any
+	Detail string
+	// Fields holds per field validation errors from 400 responses
+	Fields map[string][]string
+	// Body is the raw response body
+	Body string
+}
+
+func newAPIError(method string, path string, status int, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: status,
+		Method:     method,
+		Path:       path,
+		Body:       string(body),
+		Fields:     map[string][]string{},
+	}
+	// authentik returns either {"detail": "..."} or a map of field names to lists of errors
+	parsed := map[string]interface{}{}
+	if json.Unmarshal(body, &parsed) != nil {
+		return e
+	}
+	for k, v := range parsed {
+		switch val := v.(type) {
+		case string:
+			if k == "detail" {
+				e.Detail = val
+			} else {
+				e.Fields[k] = []string{val}
+			}
+		case []interface{}:
+			for _, item := range val {
+				e.Fields[k] = append(e.Fields[k], fmt.Sprint(item))
+			}
+		}
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("authentik %v %v: %v %v", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Detail != "" {
+		msg = fmt.Sprintf("%v: %v", msg, e.Detail)
+	}
+	if len(e.Fields) > 0 {
+		keys := make([]string, 0, len(e.Fields))
+		for k := range e.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := []string{}
+		for _, k := range keys {
+			fields = append(fields, fmt.Sprintf("%v: %v", k, strings.Join(e.Fields[k], ", ")))
+		}
+		msg = fmt.Sprintf("%v (%v)", msg, strings.Join(fields, "; "))
+	}
+	return msg
+}
+
+// hasStatus checks if err is an APIError with the given status
+func hasStatus(err error, status int) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.StatusCode == status
+}
+
+// IsNotFound returns true if authentik reported the object as not existing
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsBadRequest returns true if authentik rejected the request as invalid
+func IsBadRequest(err error) bool {
+	return hasStatus(err, http.StatusBadRequest)
+}
+
+// IsUnauthorized returns true if authentik did not accept our token
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized) || hasStatus(err, http.StatusForbidden)
+}
@@ -0,0 +1,423 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+// Package fake implements an in-memory stand in for the authentik API so that code using
+// the authentik client can be tested without a running authentik instance.
+// It only behaves like authentik as far as generic CRUD goes, anything more specific
+// can be added per test with HandleFunc.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofrs/uuid"
+)
+
+// collectionSpec describes how objects in an API collection are identified
+type collectionSpec struct {
+	// lookup is the field used in object urls
+	lookup string
+	// intPK is true when the primary key is an integer rather than a uuid
+	intPK bool
+}
+
+// collections are the API collections the fake server knows about
+var collections = map[string]collectionSpec{
+	"flows/instances":            {lookup: "slug"},
+	"providers/oauth2":           {lookup: "pk", intPK: true},
+	"providers/proxy":            {lookup: "pk", intPK: true},
+	"core/applications":          {lookup: "slug"},
+	"core/groups":                {lookup: "pk"},
+	"core/users":                 {lookup: "pk", intPK: true},
+	"managed/blueprints":         {lookup: "pk"},
+	"outposts/instances":         {lookup: "pk"},
+	"sources/oauth":              {lookup: "slug"},
+	"crypto/certificatekeypairs": {lookup: "pk"},
+}
+
+// allViews are the read only views authentik gives over every type of a collection, by the prefix of the
+// collections they combine e.g. providers/all over providers/oauth2 and providers/proxy
+var allViews = map[string]string{
+	"providers/all": "providers/",
+	"sources/all":   "sources/",
+}
+
+// Server is a fake authentik API server backed by in-memory collections.
+type Server struct {
+	*httptest.Server
+	// Token is the bearer token requests must present
+	Token string
+	// PageSize is how many results list endpoints return per page
+	PageSize int
+
+	mu       sync.Mutex
+	objects  map[string][]map[string]interface{}
+	handlers map[string]http.HandlerFunc
+	failures []int
+	requests []string
+	imports  []string
+	nextPK   int
+}
+
+// NewServer starts a fake authentik API server accepting the given token.
+// Callers should Close the server when done.
+func NewServer(token string) *Server {
+	s := &Server{
+		Token:    token,
+		PageSize: 20,
+		objects:  map[string][]map[string]interface{}{},
+		handlers: map[string]http.HandlerFunc{},
+		nextPK:   1,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// HandleFunc overrides the handling of requests to the given API path relative to /api/v3/
+// e.g. "core/users/1/set_password". The method and body are left to the handler.
+func (s *Server) HandleFunc(path string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[strings.Trim(path, "/")] = handler
+}
+
+// Fail makes the next requests fail with the given statuses in order, before requests are handled normally again.
+func (s *Server) Fail(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// Seed adds an object to a collection as if it had been created through the API, returning the stored object.
+func (s *Server) Seed(collection string, obj map[string]interface{}) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.store(collection, obj)
+	return copyObject(stored)
+}
+
+// Objects returns a copy of every object in a collection.
+func (s *Server) Objects(collection string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []map[string]interface{}{}
+	for _, obj := range s.objects[collection] {
+		out = append(out, copyObject(obj))
+	}
+	return out
+}
+
+// Imports returns the content of every blueprint imported so far.
+func (s *Server) Imports() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.imports...)
+}
+
+// Requests returns every request received so far as "METHOD path".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, fmt.Sprintf("%v %v", r.Method, r.URL.Path))
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		s.mu.Unlock()
+		writeJSON(w, status, map[string]interface{}{"detail": http.StatusText(status)})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		s.mu.Unlock()
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{"detail": "Authentication credentials were not provided."})
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v3/"), "/")
+	if handler, ok := s.handlers[path]; ok {
+		s.mu.Unlock()
+		handler(w, r)
+		return
+	}
+	defer s.mu.Unlock()
+
+	if path == "flows/instances/import" && r.Method == http.MethodPost {
+		s.importBlueprint(w, r)
+		return
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+		return
+	}
+	collection := parts[0] + "/" + parts[1]
+	rest := parts[2:]
+	_, known := collections[collection]
+	_, view := allViews[collection]
+	if !known && !view {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+		return
+	}
+
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodGet:
+		s.list(w, r, collection)
+	case len(rest) == 0 && r.Method == http.MethodPost && known:
+		s.create(w, r, collection)
+	case len(rest) == 1:
+		s.object(w, r, collection, rest[0])
+	case len(rest) == 2 && r.Method == http.MethodPost:
+		// actions like apply or set_password just acknowledge with the object they act on
+		_, obj := s.find(collection, rest[0])
+		if obj == nil {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+			return
+		}
+		writeJSON(w, http.StatusOK, obj)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"detail": "Method not allowed."})
+	}
+}
+
+// importBlueprint accepts any non empty blueprint upload, it does not apply its entries
+func (s *Server) importBlueprint(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"file": []string{"No file was submitted."}})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil || len(strings.TrimSpace(string(content))) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"logs":    []map[string]interface{}{{"log_level": "warning", "event": "Blueprint is empty"}},
+			"success": false,
+		})
+		return
+	}
+	s.imports = append(s.imports, string(content))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"logs":    []map[string]interface{}{{"log_level": "info", "event": "Blueprint imported"}},
+		"success": true,
+	})
+}
+
+// list writes a page of objects whose fields equal the query values
+func (s *Server) list(w http.ResponseWriter, r *http.Request, collection string) {
+	matches := []map[string]interface{}{}
+	for _, obj := range s.collection(collection) {
+		if matchesQuery(obj, r.URL.Query()) {
+			matches = append(matches, obj)
+		}
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pages := (len(matches) + s.PageSize - 1) / s.PageSize
+	start := (page - 1) * s.PageSize
+	end := start + s.PageSize
+	if start > len(matches) {
+		start = len(matches)
+	}
+	if end > len(matches) {
+		end = len(matches)
+	}
+	next := 0
+	if page < pages {
+		next = page + 1
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pagination": map[string]interface{}{
+			"next":        next,
+			"current":     page,
+			"count":       len(matches),
+			"total_pages": pages,
+		},
+		"results": matches[start:end],
+	})
+}
+
+// create stores a new object rejecting duplicates of the lookup field like authentik does
+func (s *Server) create(w http.ResponseWriter, r *http.Request, collection string) {
+	obj := map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&obj); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"non_field_errors": []string{err.Error()}})
+		return
+	}
+	spec := collections[collection]
+	if spec.lookup != "pk" {
+		if fmt.Sprint(obj[spec.lookup]) == "" || obj[spec.lookup] == nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{spec.lookup: []string{"This field is required."}})
+			return
+		}
+		if _, existing := s.find(collection, fmt.Sprint(obj[spec.lookup])); existing != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{spec.lookup: []string{fmt.Sprintf("Object with this %v already exists.", spec.lookup)}})
+			return
+		}
+	}
+	delete(obj, "pk")
+	writeJSON(w, http.StatusCreated, s.store(collection, obj))
+}
+
+// object handles requests for a single object
+func (s *Server) object(w http.ResponseWriter, r *http.Request, collection string, id string) {
+	owner, obj := s.find(collection, id)
+	if obj == nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not found."})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodPut, http.MethodPatch:
+		if _, view := allViews[collection]; view {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"detail": "Method not allowed."})
+			return
+		}
+		in := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"non_field_errors": []string{err.Error()}})
+			return
+		}
+		pk := obj["pk"]
+		if r.Method == http.MethodPut {
+			for k := range obj {
+				delete(obj, k)
+			}
+		}
+		for k, v := range in {
+			obj[k] = v
+		}
+		obj["pk"] = pk
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodDelete:
+		objs := s.objects[owner]
+		for i := range objs {
+			if fmt.Sprint(objs[i]["pk"]) == fmt.Sprint(obj["pk"]) {
+				s.objects[owner] = append(objs[:i], objs[i+1:]...)
+				break
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"detail": "Method not allowed."})
+	}
+}
+
+// collection returns the objects of a collection, combining every type for views like providers/all
+func (s *Server) collection(collection string) []map[string]interface{} {
+	prefix, view := allViews[collection]
+	if !view {
+		return s.objects[collection]
+	}
+	names := []string{}
+	for name := range collections {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	all := []map[string]interface{}{}
+	for _, name := range names {
+		all = append(all, s.objects[name]...)
+	}
+	return all
+}
+
+// find returns the collection owning the object with the given lookup value and the object itself
+func (s *Server) find(collection string, id string) (string, map[string]interface{}) {
+	names := []string{collection}
+	if prefix, view := allViews[collection]; view {
+		names = []string{}
+		for name := range collections {
+			if strings.HasPrefix(name, prefix) {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range names {
+		lookup := "pk"
+		if spec, ok := collections[name]; ok {
+			lookup = spec.lookup
+		}
+		for _, obj := range s.objects[name] {
+			if fmt.Sprint(obj[lookup]) == id {
+				return name, obj
+			}
+		}
+	}
+	return "", nil
+}
+
+// store assigns a primary key if needed and appends the object to its collection
+func (s *Server) store(collection string, obj map[string]interface{}) map[string]interface{} {
+	stored := copyObject(obj)
+	if _, ok := stored["pk"]; !ok {
+		if collections[collection].intPK {
+			stored["pk"] = s.nextPK
+			s.nextPK++
+		} else {
+			stored["pk"] = uuid.Must(uuid.NewV4()).String()
+		}
+	}
+	s.objects[collection] = append(s.objects[collection], stored)
+	return stored
+}
+
+// matchesQuery checks every filter in the query against the fields of the object,
+// supporting the __iexact lookup as well as exact matches
+func matchesQuery(obj map[string]interface{}, query url.Values) bool {
+	for k, v := range query {
+		switch k {
+		case "page", "page_size", "ordering", "search":
+			continue
+		}
+		if len(v) == 0 {
+			return false
+		}
+		if field, ok := strings.CutSuffix(k, "__iexact"); ok {
+			if !strings.EqualFold(fmt.Sprint(obj[field]), v[0]) {
+				return false
+			}
+			continue
+		}
+		if fmt.Sprint(obj[k]) != v[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func copyObject(obj map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		out[k] = v
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
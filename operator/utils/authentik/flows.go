@@ -0,0 +1,57 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"context"
+	"net/url"
+)
+
+const flowsPath = "flows/instances"
+
+// Flow is an authentik flow, identified by its slug
+// https://docs.goauthentik.io/docs/flow/
+type Flow struct {
+	PK               string `json:"pk,omitempty"`
+	Slug             string `json:"slug"`
+	Name             string `json:"name"`
+	Title            string `json:"title"`
+	Designation      string `json:"designation"`
+	Authentication   string `json:"authentication,omitempty"`
+	Layout           string `json:"layout,omitempty"`
+	PolicyEngineMode string `json:"policy_engine_mode,omitempty"`
+	DeniedAction     string `json:"denied_action,omitempty"`
+}
+
+// ListFlows lists flows matching the query e.g. url.Values{"designation": {"authentication"}}
+func (c *Client) ListFlows(ctx context.Context, query url.Values) ([]Flow, error) {
+	return list[Flow](ctx, c, flowsPath, query)
+}
+
+// GetFlow gets the flow with the given slug
+func (c *Client) GetFlow(ctx context.Context, slug string) (*Flow, error) {
+	return get[Flow](ctx, c, objectPath(flowsPath, slug))
+}
+
+// CreateFlow creates a new flow
+func (c *Client) CreateFlow(ctx context.Context, flow *Flow) (*Flow, error) {
+	return create(ctx, c, flowsPath, flow)
+}
+
+// UpdateFlow replaces the flow with the same slug
+func (c *Client) UpdateFlow(ctx context.Context, flow *Flow) (*Flow, error) {
+	return update(ctx, c, objectPath(flowsPath, flow.Slug), flow)
+}
+
+// DeleteFlow deletes the flow with the given slug
+func (c *Client) DeleteFlow(ctx context.Context, slug string) error {
+	return remove(ctx, c, objectPath(flowsPath, slug))
+}
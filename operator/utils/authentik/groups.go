@@ -0,0 +1,54 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"context"
+	"net/url"
+)
+
+const groupsPath = "core/groups"
+
+// Group is an authentik group, identified by its uuid
+// https://docs.goauthentik.io/docs/user-group-role/groups/
+type Group struct {
+	PK          string                 `json:"pk,omitempty"`
+	Name        string                 `json:"name"`
+	IsSuperuser bool                   `json:"is_superuser"`
+	Parent      *string                `json:"parent"`
+	Users       []int                  `json:"users,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// ListGroups lists groups matching the query e.g. url.Values{"name": {"admins"}}
+func (c *Client) ListGroups(ctx context.Context, query url.Values) ([]Group, error) {
+	return list[Group](ctx, c, groupsPath, query)
+}
+
+// GetGroup gets the group with the given uuid
+func (c *Client) GetGroup(ctx context.Context, pk string) (*Group, error) {
+	return get[Group](ctx, c, objectPath(groupsPath, pk))
+}
+
+// CreateGroup creates a new group
+func (c *Client) CreateGroup(ctx context.Context, group *Group) (*Group, error) {
+	return create(ctx, c, groupsPath, group)
+}
+
+// UpdateGroup replaces the group with the same uuid
+func (c *Client) UpdateGroup(ctx context.Context, group *Group) (*Group, error) {
+	return update(ctx, c, objectPath(groupsPath, group.PK), group)
+}
+
+// DeleteGroup deletes the group with the given uuid
+func (c *Client) DeleteGroup(ctx context.Context, pk string) error {
+	return remove(ctx, c, objectPath(groupsPath, pk))
+}
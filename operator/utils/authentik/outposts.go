@@ -0,0 +1,55 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"context"
+	"net/url"
+)
+
+const outpostsPath = "outposts/instances"
+
+// Outpost is an authentik outpost, identified by its uuid
+// https://docs.goauthentik.io/docs/outposts/
+type Outpost struct {
+	PK                string                 `json:"pk,omitempty"`
+	Name              string                 `json:"name"`
+	Type              string                 `json:"type"`
+	Providers         []int                  `json:"providers"`
+	ServiceConnection *string                `json:"service_connection"`
+	Config            map[string]interface{} `json:"config"`
+	Managed           *string                `json:"managed,omitempty"`
+}
+
+// ListOutposts lists outposts matching the query e.g. url.Values{"name__iexact": {"authentik Embedded Outpost"}}
+func (c *Client) ListOutposts(ctx context.Context, query url.Values) ([]Outpost, error) {
+	return list[Outpost](ctx, c, outpostsPath, query)
+}
+
+// GetOutpost gets the outpost with the given uuid
+func (c *Client) GetOutpost(ctx context.Context, pk string) (*Outpost, error) {
+	return get[Outpost](ctx, c, objectPath(outpostsPath, pk))
+}
+
+// CreateOutpost creates a new outpost
+func (c *Client) CreateOutpost(ctx context.Context, outpost *Outpost) (*Outpost, error) {
+	return create(ctx, c, outpostsPath, outpost)
+}
+
+// UpdateOutpost replaces the outpost with the same uuid
+func (c *Client) UpdateOutpost(ctx context.Context, outpost *Outpost) (*Outpost, error) {
+	return update(ctx, c, objectPath(outpostsPath, outpost.PK), outpost)
+}
+
+// DeleteOutpost deletes the outpost with the given uuid
+func (c *Client) DeleteOutpost(ctx context.Context, pk string) error {
+	return remove(ctx, c, objectPath(outpostsPath, pk))
+}
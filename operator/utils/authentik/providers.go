@@ -0,0 +1,134 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"context"
+	"net/url"
+)
+
+const (
+	providersPath       = "providers/all"
+	oauth2ProvidersPath = "providers/oauth2"
+	proxyProvidersPath  = "providers/proxy"
+)
+
+// Provider is the common view authentik gives of every provider regardless of type
+type Provider struct {
+	PK                      int    `json:"pk"`
+	Name                    string `json:"name"`
+	AuthorizationFlow       string `json:"authorization_flow"`
+	Component               string `json:"component"`
+	AssignedApplicationSlug string `json:"assigned_application_slug"`
+	AssignedApplicationName string `json:"assigned_application_name"`
+	VerboseName             string `json:"verbose_name"`
+	MetaModelName           string `json:"meta_model_name"`
+}
+
+// ListProviders lists providers of all types matching the query
+func (c *Client) ListProviders(ctx context.Context, query url.Values) ([]Provider, error) {
+	return list[Provider](ctx, c, providersPath, query)
+}
+
+// DeleteProvider deletes the provider with the given primary key whatever its type
+func (c *Client) DeleteProvider(ctx context.Context, pk int) error {
+	return remove(ctx, c, objectPath(providersPath, pk))
+}
+
+// OAuth2Provider is an OAuth2 / OpenID Connect provider
+// https://docs.goauthentik.io/docs/providers/oauth2/
+type OAuth2Provider struct {
+	PK                     int      `json:"pk,omitempty"`
+	Name                   string   `json:"name"`
+	AuthenticationFlow     *string  `json:"authentication_flow,omitempty"`
+	AuthorizationFlow      string   `json:"authorization_flow"`
+	PropertyMappings       []string `json:"property_mappings,omitempty"`
+	ClientType             string   `json:"client_type,omitempty"`
+	ClientID               string   `json:"client_id,omitempty"`
+	ClientSecret           string   `json:"client_secret,omitempty"`
+	AccessCodeValidity     string   `json:"access_code_validity,omitempty"`
+	AccessTokenValidity    string   `json:"access_token_validity,omitempty"`
+	RefreshTokenValidity   string   `json:"refresh_token_validity,omitempty"`
+	IncludeClaimsInIDToken bool     `json:"include_claims_in_id_token"`
+	SigningKey             *string  `json:"signing_key,omitempty"`
+	RedirectURIs           string   `json:"redirect_uris"`
+	SubMode                string   `json:"sub_mode,omitempty"`
+	IssuerMode             string   `json:"issuer_mode,omitempty"`
+	JwksSources            []string `json:"jwks_sources,omitempty"`
+}
+
+// ListOAuth2Providers lists OAuth2 providers matching the query e.g. url.Values{"name": {"grafana"}}
+func (c *Client) ListOAuth2Providers(ctx context.Context, query url.Values) ([]OAuth2Provider, error) {
+	return list[OAuth2Provider](ctx, c, oauth2ProvidersPath, query)
+}
+
+// GetOAuth2Provider gets the OAuth2 provider with the given primary key
+func (c *Client) GetOAuth2Provider(ctx context.Context, pk int) (*OAuth2Provider, error) {
+	return get[OAuth2Provider](ctx, c, objectPath(oauth2ProvidersPath, pk))
+}
+
+// CreateOAuth2Provider creates a new OAuth2 provider
+func (c *Client) CreateOAuth2Provider(ctx context.Context, provider *OAuth2Provider) (*OAuth2Provider, error) {
+	return create(ctx, c, oauth2ProvidersPath, provider)
+}
+
+// UpdateOAuth2Provider replaces the OAuth2 provider with the same primary key
+func (c *Client) UpdateOAuth2Provider(ctx context.Context, provider *OAuth2Provider) (*OAuth2Provider, error) {
+	return update(ctx, c, objectPath(oauth2ProvidersPath, provider.PK), provider)
+}
+
+// DeleteOAuth2Provider deletes the OAuth2 provider with the given primary key
+func (c *Client) DeleteOAuth2Provider(ctx context.Context, pk int) error {
+	return remove(ctx, c, objectPath(oauth2ProvidersPath, pk))
+}
+
+// ProxyProvider is a proxy or forward auth provider served by an outpost
+// https://docs.goauthentik.io/docs/providers/proxy/
+type ProxyProvider struct {
+	PK                         int     `json:"pk,omitempty"`
+	Name                       string  `json:"name"`
+	AuthenticationFlow         *string `json:"authentication_flow,omitempty"`
+	AuthorizationFlow          string  `json:"authorization_flow"`
+	Mode                       string  `json:"mode,omitempty"`
+	ExternalHost               string  `json:"external_host"`
+	InternalHost               string  `json:"internal_host,omitempty"`
+	InternalHostSSLValidation  bool    `json:"internal_host_ssl_validation"`
+	CookieDomain               string  `json:"cookie_domain,omitempty"`
+	SkipPathRegex              string  `json:"skip_path_regex,omitempty"`
+	BasicAuthEnabled           bool    `json:"basic_auth_enabled"`
+	BasicAuthUserAttribute     string  `json:"basic_auth_user_attribute,omitempty"`
+	BasicAuthPasswordAttribute string  `json:"basic_auth_password_attribute,omitempty"`
+}
+
+// ListProxyProviders lists proxy providers matching the query e.g. url.Values{"name__iexact": {"grafana"}}
+func (c *Client) ListProxyProviders(ctx context.Context, query url.Values) ([]ProxyProvider, error) {
+	return list[ProxyProvider](ctx, c, proxyProvidersPath, query)
+}
+
+// GetProxyProvider gets the proxy provider with the given primary key
+func (c *Client) GetProxyProvider(ctx context.Context, pk int) (*ProxyProvider, error) {
+	return get[ProxyProvider](ctx, c, objectPath(proxyProvidersPath, pk))
+}
+
+// CreateProxyProvider creates a new proxy provider
+func (c *Client) CreateProxyProvider(ctx context.Context, provider *ProxyProvider) (*ProxyProvider, error) {
+	return create(ctx, c, proxyProvidersPath, provider)
+}
+
+// UpdateProxyProvider replaces the proxy provider with the same primary key
+func (c *Client) UpdateProxyProvider(ctx context.Context, provider *ProxyProvider) (*ProxyProvider, error) {
+	return update(ctx, c, objectPath(proxyProvidersPath, provider.PK), provider)
+}
+
+// DeleteProxyProvider deletes the proxy provider with the given primary key
+func (c *Client) DeleteProxyProvider(ctx context.Context, pk int) error {
+	return remove(ctx, c, objectPath(proxyProvidersPath, pk))
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+const (
+	sourcesPath      = "sources/all"
+	oauthSourcesPath = "sources/oauth"
+)
+
+// OAuthSource is an OAuth / OpenID Connect federation source, identified by its slug
+// https://docs.goauthentik.io/integrations/sources/
+type OAuthSource struct {
+	PK                 string  `json:"pk,omitempty"`
+	Name               string  `json:"name,omitempty"`
+	Slug               string  `json:"slug"`
+	ProviderType       string  `json:"provider_type,omitempty"`
+	ConsumerKey        string  `json:"consumer_key,omitempty"`
+	ConsumerSecret     string  `json:"consumer_secret,omitempty"`
+	AuthenticationFlow *string `json:"authentication_flow,omitempty"`
+	EnrollmentFlow     *string `json:"enrollment_flow,omitempty"`
+	UserMatchingMode   string  `json:"user_matching_mode,omitempty"`
+	AdditionalScopes   string  `json:"additional_scopes,omitempty"`
+	AuthorizationURL   string  `json:"authorization_url,omitempty"`
+	AccessTokenURL     string  `json:"access_token_url,omitempty"`
+	ProfileURL         string  `json:"profile_url,omitempty"`
+	OIDCWellKnownURL   string  `json:"oidc_well_known_url,omitempty"`
+	OIDCJWKSURL        string  `json:"oidc_jwks_url,omitempty"`
+}
+
+// ListOAuthSources lists OAuth sources matching the query e.g. url.Values{"provider_type": {"github"}}
+func (c *Client) ListOAuthSources(ctx context.Context, query url.Values) ([]OAuthSource, error) {
+	return list[OAuthSource](ctx, c, oauthSourcesPath, query)
+}
+
+// GetOAuthSource gets the OAuth source with the given slug
+func (c *Client) GetOAuthSource(ctx context.Context, slug string) (*OAuthSource, error) {
+	return get[OAuthSource](ctx, c, objectPath(oauthSourcesPath, slug))
+}
+
+// CreateOAuthSource creates a new OAuth source
+func (c *Client) CreateOAuthSource(ctx context.Context, source *OAuthSource) (*OAuthSource, error) {
+	return create(ctx, c, oauthSourcesPath, source)
+}
+
+// UpdateOAuthSource replaces the OAuth source with the same slug
+func (c *Client) UpdateOAuthSource(ctx context.Context, source *OAuthSource) (*OAuthSource, error) {
+	return update(ctx, c, objectPath(oauthSourcesPath, source.Slug), source)
+}
+
+// DeleteOAuthSource deletes the OAuth source with the given slug
+func (c *Client) DeleteOAuthSource(ctx context.Context, slug string) error {
+	return remove(ctx, c, objectPath(oauthSourcesPath, slug))
+}
+
+// PatchOAuthSource updates only the fields set on the OAuth source with the same slug, such as its credentials
+// without touching its flows
+func (c *Client) PatchOAuthSource(ctx context.Context, source *OAuthSource) (*OAuthSource, error) {
+	return patch(ctx, c, objectPath(oauthSourcesPath, source.Slug), source)
+}
+
+// SetSourceIconURL sets the icon of the source of any type with the given slug to an image url
+func (c *Client) SetSourceIconURL(ctx context.Context, slug string, iconURL string) error {
+	in := map[string]string{"url": iconURL}
+	return c.Do(ctx, http.MethodPost, objectPath(sourcesPath, slug)+"/set_icon_url", nil, in, nil)
+}
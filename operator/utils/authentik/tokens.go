@@ -0,0 +1,37 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"context"
+	"net/http"
+)
+
+const tokensPath = "core/tokens"
+
+// ViewTokenKey gets the secret key of the token with the given identifier, such as ak-outpost-<uuid>-api
+// which authentik creates for each outpost to connect with
+func (c *Client) ViewTokenKey(ctx context.Context, identifier string) (string, error) {
+	out := &struct {
+		Key string `json:"key"`
+	}{}
+	err := c.Do(ctx, http.MethodGet, objectPath(tokensPath, identifier)+"/view_key", nil, nil, out)
+	if err != nil {
+		return "", err
+	}
+	return out.Key, nil
+}
+
+// SetTokenKey sets the secret key of the token with the given identifier, so a key generated elsewhere can be
+// given to authentik without it appearing in a blueprint
+func (c *Client) SetTokenKey(ctx context.Context, identifier string, key string) error {
+	return c.Do(ctx, http.MethodPost, objectPath(tokensPath, identifier)+"/set_key", nil, map[string]string{"key": key}, nil)
+}
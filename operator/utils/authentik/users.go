@@ -0,0 +1,63 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package authentik
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+const usersPath = "core/users"
+
+// User is an authentik user, identified by its integer primary key
+// https://docs.goauthentik.io/docs/user-group-role/user/
+type User struct {
+	PK         int                    `json:"pk,omitempty"`
+	Username   string                 `json:"username"`
+	Name       string                 `json:"name"`
+	Email      string                 `json:"email,omitempty"`
+	IsActive   bool                   `json:"is_active"`
+	Groups     []string               `json:"groups,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Path       string                 `json:"path,omitempty"`
+	Type       string                 `json:"type,omitempty"`
+}
+
+// ListUsers lists users matching the query e.g. url.Values{"username": {"akadmin"}}
+func (c *Client) ListUsers(ctx context.Context, query url.Values) ([]User, error) {
+	return list[User](ctx, c, usersPath, query)
+}
+
+// GetUser gets the user with the given primary key
+func (c *Client) GetUser(ctx context.Context, pk int) (*User, error) {
+	return get[User](ctx, c, objectPath(usersPath, pk))
+}
+
+// CreateUser creates a new user
+func (c *Client) CreateUser(ctx context.Context, user *User) (*User, error) {
+	return create(ctx, c, usersPath, user)
+}
+
+// UpdateUser replaces the user with the same primary key
+func (c *Client) UpdateUser(ctx context.Context, user *User) (*User, error) {
+	return update(ctx, c, objectPath(usersPath, user.PK), user)
+}
+
+// DeleteUser deletes the user with the given primary key
+func (c *Client) DeleteUser(ctx context.Context, pk int) error {
+	return remove(ctx, c, objectPath(usersPath, pk))
+}
+
+// SetUserPassword sets the password of the user with the given primary key
+func (c *Client) SetUserPassword(ctx context.Context, pk int, password string) error {
+	return c.Do(ctx, http.MethodPost, objectPath(usersPath, pk)+"/set_password", nil, map[string]string{"password": password}, nil)
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+// Package blueprint builds authentik blueprints whose custom yaml tags, such as !Find and !KeyOf, are emitted
+// as real yaml tags. Building tags as yaml nodes rather than formatted strings means their arguments are
+// quoted by the yaml encoder where needed, and nothing has to strip quotes from the rendered blueprint.
+// https://docs.goauthentik.io/developer-docs/blueprints/v1/tags
+package blueprint
+
+import (
+	"fmt"
+	"strings"
+
+	yaml_v3 "gopkg.in/yaml.v3"
+)
+
+// Blueprint is the top level of an authentik blueprint
+type Blueprint struct {
+	Version  int                    `yaml:"version"`
+	Metadata Metadata               `yaml:"metadata"`
+	Context  map[string]interface{} `yaml:"context,omitempty"`
+	Entries  []Entry                `yaml:"entries"`
+}
+
+// Metadata names a blueprint and labels it
+type Metadata struct {
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// Entry is a single model instance of a blueprint. Attribute values may be any value yaml can encode,
+// including the tags built by this package.
+type Entry struct {
+	Model       string                 `yaml:"model"`
+	State       string                 `yaml:"state,omitempty"`
+	ID          string                 `yaml:"id,omitempty"`
+	Identifiers map[string]interface{} `yaml:"identifiers"`
+	Attrs       map[string]interface{} `yaml:"attrs,omitempty"`
+	Conditions  []interface{}          `yaml:"conditions,omitempty"`
+}
+
+// New is a version 1 blueprint of the given name and entries
+func New(name string, entries ...Entry) *Blueprint {
+	return &Blueprint{
+		Version:  1,
+		Metadata: Metadata{Name: name},
+		Entries:  entries,
+	}
+}
+
+// Marshal renders the blueprint into the yaml authentik reads. Only tags built by this package are rendered
+// as tags, strings are always strings even if they start with a tag.
+func (b *Blueprint) Marshal() (string, error) {
+	return Marshal(b)
+}
+
+// Mode is how !Condition combines its arguments
+type Mode string
+
+const (
+	And  Mode = "AND"
+	Nand Mode = "NAND"
+	Or   Mode = "OR"
+	Nor  Mode = "NOR"
+	Xor  Mode = "XOR"
+	Xnor Mode = "XNOR"
+)
+
+// tags are the custom yaml tags authentik understands
+var tags = []string{"!Find", "!FindObject", "!KeyOf", "!Context", "!Env", "!Format", "!If", "!Condition", "!Enumerate", "!Index", "!Value", "!AtIndex", "!ParseJSON", "!File"}
+
+// Find looks up the primary key of the instance of model whose field has the given value,
+// !Find [model, [field, value]]
+func Find(model string, field string, value interface{}) *yaml_v3.Node {
+	return FindBy(model, Field{Name: field, Value: value})
+}
+
+// Field is a field of a model and the value !Find matches it against
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// FindBy looks up the primary key of the instance of model matching all of the fields,
+// !Find [model, [field, value], [field, value]...]
+func FindBy(model string, fields ...Field) *yaml_v3.Node {
+	args := []interface{}{model}
+	for _, f := range fields {
+		args = append(args, flow(f.Name, f.Value))
+	}
+	return tagged("!Find", args...)
+}
+
+// KeyOf is the primary key of the entry of this blueprint with the given id, !KeyOf id
+func KeyOf(id string) *yaml_v3.Node {
+	return &yaml_v3.Node{Kind: yaml_v3.ScalarNode, Tag: "!KeyOf", Value: id}
+}
+
+// Context is a value of the blueprint context, or the default when given and the key is not set,
+// !Context key or !Context [key, default]
+func Context(key string, def ...interface{}) *yaml_v3.Node {
+	return withDefault("!Context", key, def)
+}
+
+// Env is an environment variable of authentik, or the default when given and the variable is not set,
+// !Env name or !Env [name, default]
+func Env(name string, def ...interface{}) *yaml_v3.Node {
+	return withDefault("!Env", name, def)
+}
+
+// Format is the python %-style formatting of the arguments into format, !Format [format, args...]
+func Format(format string, args ...interface{}) *yaml_v3.Node {
+	return tagged("!Format", append([]interface{}{format}, args...)...)
+}
+
+// If is then when condition is true and otherwise when it is not, !If [condition, then, otherwise]
+func If(condition interface{}, then interface{}, otherwise interface{}) *yaml_v3.Node {
+	return tagged("!If", condition, then, otherwise)
+}
+
+// Condition combines the truth of the arguments with mode, !Condition [mode, args...]
+func Condition(mode Mode, args ...interface{}) *yaml_v3.Node {
+	return tagged("!Condition", append([]interface{}{string(mode)}, args...)...)
+}
+
+// Marshal renders v as yaml
+func Marshal(v interface{}) (string, error) {
+	out, err := yaml_v3.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// MarshalRetagged renders v as yaml with tags held as strings rendered as the tags they spell. This is only for
+// blueprints written by users as a blueprintSpec, which cannot keep yaml tags through the api server so quote them.
+func MarshalRetagged(v interface{}) (string, error) {
+	n := &yaml_v3.Node{}
+	if err := n.Encode(v); err != nil {
+		return "", err
+	}
+	if err := Retag(n); err != nil {
+		return "", err
+	}
+	return Marshal(n)
+}
+
+// Retag replaces every plain string under the node that starts with an authentik tag with the tagged node it spells
+func Retag(n *yaml_v3.Node) error {
+	if n.Kind == yaml_v3.ScalarNode && n.ShortTag() == "!!str" && isTag(n.Value) {
+		doc := &yaml_v3.Node{}
+		if err := yaml_v3.Unmarshal([]byte(n.Value), doc); err != nil {
+			return fmt.Errorf("`%v` is not a valid tag: %w", n.Value, err)
+		}
+		*n = *doc.Content[0]
+		return nil
+	}
+	for _, child := range n.Content {
+		if err := Retag(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTag checks whether a string starts with one of the authentik tags
+func isTag(s string) bool {
+	for _, tag := range tags {
+		if rest, ok := strings.CutPrefix(s, tag); ok && (rest == "" || strings.HasPrefix(rest, " ")) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagged is a flow sequence of the arguments with the given tag
+func tagged(tag string, args ...interface{}) *yaml_v3.Node {
+	n := flow(args...)
+	n.Tag = tag
+	return n
+}
+
+// withDefault is a tagged scalar of the value, or a tagged sequence of the value and its default when one is given
+func withDefault(tag string, value string, def []interface{}) *yaml_v3.Node {
+	if len(def) == 0 {
+		return &yaml_v3.Node{Kind: yaml_v3.ScalarNode, Tag: tag, Value: value}
+	}
+	return tagged(tag, value, def[0])
+}
+
+// flow is a flow style sequence of the values
+func flow(values ...interface{}) *yaml_v3.Node {
+	n := &yaml_v3.Node{Kind: yaml_v3.SequenceNode, Style: yaml_v3.FlowStyle}
+	for _, v := range values {
+		n.Content = append(n.Content, node(v))
+	}
+	return n
+}
+
+// node is the yaml node of a value, values that yaml cannot encode are given as their string form
+func node(v interface{}) *yaml_v3.Node {
+	if n, ok := v.(*yaml_v3.Node); ok {
+		return n
+	}
+	n := &yaml_v3.Node{}
+	if err := n.Encode(v); err != nil {
+		return &yaml_v3.Node{Kind: yaml_v3.ScalarNode, Tag: "!!str", Value: fmt.Sprint(v)}
+	}
+	return n
+}
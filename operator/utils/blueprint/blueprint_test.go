@@ -0,0 +1,140 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package blueprint
+
+import (
+	"strings"
+	"testing"
+
+	yaml_v3 "gopkg.in/yaml.v3"
+)
+
+func TestTags(t *testing.T) {
+	tests := map[string]struct {
+		tag  *yaml_v3.Node
+		want string
+	}{
+		"find":        {Find("authentik_flows.flow", "slug", "default-authentication-flow"), "!Find [authentik_flows.flow, [slug, default-authentication-flow]]\n"},
+		"find quoted": {Find("authentik_core.group", "name", "admins, editors"), "!Find [authentik_core.group, [name, 'admins, editors']]\n"},
+		"find by": {
+			FindBy("authentik_flows.flowstagebinding", Field{"target", Find("authentik_flows.flow", "slug", "login")}, Field{"order", 10}),
+			"!Find [authentik_flows.flowstagebinding, [target, !Find [authentik_flows.flow, [slug, login]]], [order, 10]]\n",
+		},
+		"keyof":           {KeyOf("application"), "!KeyOf application\n"},
+		"context":         {Context("goauthentik.io/enterprise/licensed"), "!Context goauthentik.io/enterprise/licensed\n"},
+		"context default": {Context("domain", "example.org"), "!Context [domain, example.org]\n"},
+		"env":             {Env("AUTHENTIK_HOST"), "!Env AUTHENTIK_HOST\n"},
+		"env default":     {Env("REPLICAS", 2), "!Env [REPLICAS, 2]\n"},
+		"format":          {Format("%s-%s", "app", KeyOf("provider")), "!Format ['%s-%s', app, !KeyOf provider]\n"},
+		"if":              {If(Context("enabled", true), "on", "off"), "!If [!Context [enabled, true], \"on\", \"off\"]\n"},
+		"condition":       {Condition(And, Context("a"), true), "!Condition [AND, !Context a, true]\n"},
+	}
+	for name, test := range tests {
+		out, err := yaml_v3.Marshal(test.tag)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if string(out) != test.want {
+			t.Errorf("%v = %q, want %q", name, out, test.want)
+		}
+		// authentik must read back the same tag
+		doc := &yaml_v3.Node{}
+		if err := yaml_v3.Unmarshal(out, doc); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if doc.Content[0].Tag != test.tag.Tag {
+			t.Errorf("%v read back as %v, want %v", name, doc.Content[0].Tag, test.tag.Tag)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	bp := New("sample",
+		Entry{
+			Model:       "authentik_core.application",
+			State:       "present",
+			ID:          "application",
+			Identifiers: map[string]interface{}{"slug": "sample"},
+			Attrs: map[string]interface{}{
+				"provider":        Find("authentik_providers_oauth2.oauth2provider", "name", "sample"),
+				"open_in_new_tab": true,
+			},
+		},
+		Entry{
+			Model:       "authentik_policies.policybinding",
+			Identifiers: map[string]interface{}{"target": KeyOf("application"), "order": 0},
+		},
+	)
+	got, err := bp.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `version: 1
+metadata:
+    name: sample
+entries:
+    - model: authentik_core.application
+      state: present
+      id: application
+      identifiers:
+        slug: sample
+      attrs:
+        open_in_new_tab: true
+        provider: !Find [authentik_providers_oauth2.oauth2provider, [name, sample]]
+    - model: authentik_policies.policybinding
+      identifiers:
+        order: 0
+        target: !KeyOf application
+`
+	if got != want {
+		t.Errorf("Marshal() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestMarshalKeepsStrings(t *testing.T) {
+	// strings from users, such as a description, must never become tags
+	bp := New("sample", Entry{
+		Model:       "authentik_core.application",
+		Identifiers: map[string]interface{}{"slug": "sample"},
+		Attrs:       map[string]interface{}{"meta_description": "!Env AUTHENTIK_SECRET_KEY"},
+	})
+	got, err := bp.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "meta_description: '!Env AUTHENTIK_SECRET_KEY'\n") {
+		t.Errorf("Marshal() =\n%v\nwant the description kept as a quoted string", got)
+	}
+}
+
+func TestMarshalRetagged(t *testing.T) {
+	// tags kept as strings, as they come back from the api server, are rendered as tags
+	got, err := MarshalRetagged(map[string]interface{}{
+		"provider": "!KeyOf provider",
+		"flow":     "!Find [authentik_flows.flow, [slug, my-flow]]",
+		"text":     "!Findings are not tags",
+		"quoted":   "it's a 'quote'",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `flow: !Find [authentik_flows.flow, [slug, my-flow]]
+provider: !KeyOf provider
+quoted: it's a 'quote'
+text: '!Findings are not tags'
+`
+	if got != want {
+		t.Errorf("MarshalRetagged() =\n%v\nwant\n%v", got, want)
+	}
+	if _, err := MarshalRetagged(map[string]interface{}{"broken": "!Find [unclosed"}); err == nil {
+		t.Error("expected an error for an invalid tag")
+	}
+}
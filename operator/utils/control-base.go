@@ -11,16 +11,22 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/alexflint/go-arg"
 	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/authentik"
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/blueprint"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
@@ -66,6 +72,158 @@ func (c *ControlBase) ListAk(namespace string) ([]*akmv1a1.Ak, error) {
 	return resources, nil
 }
 
+// BLUEPRINT routines
+
+// ReconcileAkBlueprint creates the given AkBlueprint or updates the spec and labels of the existing one.
+// On return bp holds the state from the cluster, including its status.
+func (c *ControlBase) ReconcileAkBlueprint(ctx context.Context, bp *akmv1a1.AkBlueprint) error {
+	existing := &akmv1a1.AkBlueprint{}
+	err := c.Get(ctx, types.NamespacedName{Name: bp.Name, Namespace: bp.Namespace}, existing)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return c.Create(ctx, bp)
+		}
+		return err
+	}
+	existing.Spec = bp.Spec
+	if existing.Labels == nil {
+		existing.Labels = map[string]string{}
+	}
+	for k, v := range bp.Labels {
+		existing.Labels[k] = v
+	}
+	err = c.Update(ctx, existing)
+	if err != nil {
+		return err
+	}
+	*bp = *existing
+	return nil
+}
+
+// GetConfigMapKey gets the value of the selected key of a ConfigMap in the given namespace, from either its data
+// or its binaryData.
+func (c *ControlBase) GetConfigMapKey(ctx context.Context, namespace string, selector *corev1.ConfigMapKeySelector) ([]byte, error) {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, types.NamespacedName{Name: selector.Name, Namespace: namespace}, cm)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := cm.Data[selector.Key]; ok {
+		return []byte(value), nil
+	}
+	if value, ok := cm.BinaryData[selector.Key]; ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("ConfigMap `%v` in `%v` has no key `%v`", selector.Name, namespace, selector.Key)
+}
+
+// ReconcileGeneratedBlueprint renders a blueprint into an AkBlueprint of the given name in the authentik
+// namespace, labelled so it can be traced back to the resource that generated it, and ensures it exists.
+// The blueprint is given the same name as the AkBlueprint.
+func (c *ControlBase) ReconcileGeneratedBlueprint(ctx context.Context, namespace string, name string, labels map[string]string, generated *blueprint.Blueprint) (*akmv1a1.AkBlueprint, error) {
+	generated.Metadata.Name = name
+	contentStr, err := generated.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	bp := &akmv1a1.AkBlueprint{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: akmv1a1.AkBlueprintSpec{
+			StorageType: "file",
+			File:        fmt.Sprintf("%v/%v.yaml", akmv1a1.OperatorBlueprintDir, name),
+			Blueprint:   contentStr,
+		},
+	}
+	err = c.ReconcileAkBlueprint(ctx, bp)
+	if err != nil {
+		return nil, err
+	}
+	return bp, nil
+}
+
+// DeleteGeneratedBlueprints deletes the AkBlueprints in the authentik namespace that carry all of the given labels,
+// other than those named in keep. Generated AkBlueprints are not in the namespace of the resource that generated
+// them so are not garbage collected with it, this prunes those it no longer generates or, given none to keep,
+// all of them once it is deleted.
+func (c *ControlBase) DeleteGeneratedBlueprints(ctx context.Context, namespace string, labels map[string]string, keep ...string) error {
+	bps := &akmv1a1.AkBlueprintList{}
+	err := c.List(ctx, bps, client.InNamespace(namespace), client.MatchingLabels(labels))
+	if err != nil {
+		return err
+	}
+	kept := map[string]bool{}
+	for _, name := range keep {
+		kept[name] = true
+	}
+	for i := range bps.Items {
+		if kept[bps.Items[i].Name] {
+			continue
+		}
+		err = c.Delete(ctx, &bps.Items[i])
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReconcileGeneratorFinalizer holds the deletion of a resource that generates AkBlueprints until the AkBlueprints
+// with the given labels are deleted from the authentik namespace. It returns true once the resource is being
+// deleted, in which case there is nothing left to reconcile.
+func (c *ControlBase) ReconcileGeneratorFinalizer(ctx context.Context, obj client.Object, finalizer string, namespace string, labels map[string]string) (bool, error) {
+	if obj.GetDeletionTimestamp().IsZero() {
+		if controllerutil.AddFinalizer(obj, finalizer) {
+			return false, c.Update(ctx, obj)
+		}
+		return false, nil
+	}
+	if !controllerutil.ContainsFinalizer(obj, finalizer) {
+		return true, nil
+	}
+	err := c.DeleteGeneratedBlueprints(ctx, namespace, labels)
+	if err != nil {
+		return true, err
+	}
+	controllerutil.RemoveFinalizer(obj, finalizer)
+	return true, c.Update(ctx, obj)
+}
+
+// BlueprintsReadyCondition is the Ready condition of a resource that generates AkBlueprints,
+// which is only true once authentik has applied all of them so none are pending.
+func BlueprintsReadyCondition(pending []string, generation int64) metav1.Condition {
+	ready := metav1.Condition{
+		Type:               akmv1a1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "BlueprintsApplied",
+		Message:            "All generated blueprints have been applied by authentik.",
+		ObservedGeneration: generation,
+	}
+	if len(pending) > 0 {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "BlueprintsPending"
+		ready.Message = fmt.Sprintf("Waiting for authentik to apply blueprints %v.", pending)
+	}
+	return ready
+}
+
+// GeneratedBlueprintRequests maps a generated AkBlueprint back to the resource that generated it using the
+// name and namespace labels placed on it. Owner references cannot be used since generated AkBlueprints
+// live in the authentik namespace not the namespace of the resource.
+func GeneratedBlueprintRequests(obj client.Object, nameLabel string, namespaceLabel string) []reconcile.Request {
+	labels := obj.GetLabels()
+	name, ok := labels[nameLabel]
+	if !ok || labels[namespaceLabel] == "" {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: name, Namespace: labels[namespaceLabel]}},
+	}
+}
+
 // HELM routines
 
 // GetReleasedValues finds the actual values used by helm to generate some manifests. This
@@ -216,6 +374,65 @@ func (c *ControlBase) LoadHelmChart(u *url.URL) (*chart.Chart, error) {
 	return chartLoader.Load(path)
 }
 
+// AUTHENTIK routines
+
+// authentikTokenKey is the key in the release secret holding the authentik bootstrap token
+const authentikTokenKey = "authBootstrapToken"
+
+// AuthentikEndpoint works out the release secret name and in cluster url of the authentik server
+// from the released values of an Ak, falling back to the chart defaults where values are missing.
+func AuthentikEndpoint(values map[string]interface{}, namespace string) (secretName string, baseURL string) {
+	secretName = "auth"
+	if section, ok := values["secret"].(map[string]interface{}); ok {
+		if name, ok := section["name"].(string); ok && name != "" {
+			secretName = name
+		}
+	}
+	service := "authentik"
+	port := 80
+	if section, ok := values["authentik"].(map[string]interface{}); ok {
+		if svc, ok := section["service"].(map[string]interface{}); ok {
+			if name, ok := svc["name"].(string); ok && name != "" {
+				service = name
+			}
+		}
+		// the server is exposed on the service port of the first listed port
+		if ports, ok := section["ports"].([]interface{}); ok && len(ports) > 0 {
+			if first, ok := ports[0].(map[string]interface{}); ok {
+				switch p := first["servicePort"].(type) {
+				case int:
+					port = p
+				case int64:
+					port = int(p)
+				case float64:
+					port = int(p)
+				}
+			}
+		}
+	}
+	return secretName, fmt.Sprintf("http://%v-server.%v.svc:%v", service, namespace, port)
+}
+
+// NewAuthentikClient creates an authentik API client for the given Ak, authenticating with the
+// bootstrap token from its release secret.
+func (c *ControlBase) NewAuthentikClient(ctx context.Context, ak *akmv1a1.Ak) (*authentik.Client, error) {
+	values, err := c.GetReleasedValues(ak.Namespace, ak.Name)
+	if err != nil {
+		return nil, err
+	}
+	secretName, baseURL := AuthentikEndpoint(values, ak.Namespace)
+	secret := &corev1.Secret{}
+	err = c.Get(ctx, types.NamespacedName{Name: secretName, Namespace: ak.Namespace}, secret)
+	if err != nil {
+		return nil, err
+	}
+	token, ok := secret.Data[authentikTokenKey]
+	if !ok || len(token) == 0 {
+		return nil, fmt.Errorf("secret `%v` in `%v` has no `%v` key for authentik API access", secretName, ak.Namespace, authentikTokenKey)
+	}
+	return authentik.NewClient(baseURL, string(token))
+}
+
 // NewSQLConfig best effort to generate a connection config based on env variables and system
 func (c *ControlBase) NewSQLConfig() *SQLConfig {
 	// TODO populate with real values from go-arg
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	akmv1a1 "gitlab.com/GeorgeRaven/authentik-manager/operator/api/v1alpha1"
+)
+
+func TestAuthentikEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     map[string]interface{}
+		wantSecret string
+		wantURL    string
+	}{
+		{
+			name:       "defaults",
+			values:     map[string]interface{}{},
+			wantSecret: "auth",
+			wantURL:    "http://authentik-server.auth.svc:80",
+		},
+		{
+			name: "released values",
+			values: map[string]interface{}{
+				"secret": map[string]interface{}{"name": "ak-secret"},
+				"authentik": map[string]interface{}{
+					"service": map[string]interface{}{"name": "sso"},
+					"ports":   []interface{}{map[string]interface{}{"name": "http", "servicePort": float64(8080)}},
+				},
+			},
+			wantSecret: "ak-secret",
+			wantURL:    "http://sso-server.auth.svc:8080",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, u := AuthentikEndpoint(tt.values, "auth")
+			if secret != tt.wantSecret || u != tt.wantURL {
+				t.Fatalf("got (%v, %v), want (%v, %v)", secret, u, tt.wantSecret, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestReconcileGeneratorFinalizer(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := akmv1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{"akm.goauthentik.io/akgroup": "editors", "akm.goauthentik.io/akgroup-namespace": "wiki"}
+	group := &akmv1a1.AkGroup{ObjectMeta: metav1.ObjectMeta{Name: "editors", Namespace: "wiki"}}
+	generated := &akmv1a1.AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "wiki-group-editors", Namespace: "auth", Labels: labels}}
+	other := &akmv1a1.AkBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "wiki-group-admins", Namespace: "auth", Labels: map[string]string{
+		"akm.goauthentik.io/akgroup": "admins", "akm.goauthentik.io/akgroup-namespace": "wiki",
+	}}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(group, generated, other).Build()
+	cb := &ControlBase{Client: c, Scheme: scheme}
+
+	deleted, err := cb.ReconcileGeneratorFinalizer(ctx, group, "akm.goauthentik.io/finalizer", "auth", labels)
+	if err != nil || deleted {
+		t.Fatalf("expected the finalizer to be added, got %v, %v", deleted, err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "editors", Namespace: "wiki"}, group); err != nil {
+		t.Fatal(err)
+	}
+	if !controllerutil.ContainsFinalizer(group, "akm.goauthentik.io/finalizer") {
+		t.Fatalf("finalizers = %v", group.Finalizers)
+	}
+
+	// deleting only marks the group while our finalizer is on it
+	if err := c.Delete(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "editors", Namespace: "wiki"}, group); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err = cb.ReconcileGeneratorFinalizer(ctx, group, "akm.goauthentik.io/finalizer", "auth", labels)
+	if err != nil || !deleted {
+		t.Fatalf("expected the group to be finalized, got %v, %v", deleted, err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "wiki-group-editors", Namespace: "auth"}, generated); !errors.IsNotFound(err) {
+		t.Errorf("expected the generated blueprint to be deleted, got %v", err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "wiki-group-admins", Namespace: "auth"}, other); err != nil {
+		t.Errorf("expected blueprints of other groups to be kept, got %v", err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "editors", Namespace: "wiki"}, group); !errors.IsNotFound(err) {
+		t.Errorf("expected the group to be gone once finalized, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+// Package fake implements an in-memory stand in for an OCI registry serving authentik blueprints,
+// like a local registry:2 with blueprints pushed to it, for use in tests.
+package fake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"gitlab.com/GeorgeRaven/authentik-manager/operator/utils/oci"
+)
+
+// Registry is a fake https OCI registry. Use Client to get an http client that trusts it.
+type Registry struct {
+	*httptest.Server
+	// Username and Password if set are required as basic auth for every request
+	Username string
+	Password string
+
+	mu        sync.Mutex
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+// NewRegistry starts a fake OCI registry. Callers should Close the registry when done.
+func NewRegistry() *Registry {
+	r := &Registry{
+		manifests: map[string][]byte{},
+		blobs:     map[string][]byte{},
+	}
+	r.Server = httptest.NewTLSServer(http.HandlerFunc(r.serveHTTP))
+	return r
+}
+
+// Host is the registry host to use in references e.g. 127.0.0.1:34567
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.URL, "https://")
+}
+
+// Push stores a blueprint under repository:tag the way authentik expects it, returning the manifest digest.
+// The manifest can then be pulled by tag or digest.
+func (r *Registry) Push(repository string, tag string, blueprint string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	layer := digest([]byte(blueprint))
+	r.blobs[repository+"@"+layer] = []byte(blueprint)
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers": []map[string]interface{}{
+			{"mediaType": oci.BlueprintMediaType, "digest": layer, "size": len(blueprint)},
+		},
+	})
+	d := digest(manifest)
+	r.manifests[repository+":"+tag] = manifest
+	r.manifests[repository+"@"+d] = manifest
+	return d
+}
+
+func (r *Registry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.Username != "" {
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != r.Username || pass != r.Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if i := strings.LastIndex(path, "/manifests/"); i >= 0 {
+		ref := path[i+len("/manifests/"):]
+		sep := ":"
+		if strings.HasPrefix(ref, "sha256:") {
+			sep = "@"
+		}
+		manifest, ok := r.manifests[path[:i]+sep+ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w.Header().Set("Docker-Content-Digest", digest(manifest))
+		_, _ = w.Write(manifest)
+		return
+	}
+	if i := strings.LastIndex(path, "/blobs/"); i >= 0 {
+		blob, ok := r.blobs[path[:i]+"@"+path[i+len("/blobs/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(blob)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2023 George Onoufriou.
+
+Licensed under the Open Software Licence, Version 3.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License in the project root (LICENSE) or at
+
+    https://opensource.org/license/osl-3-0-php/
+*/
+
+// Package oci pulls authentik blueprints from OCI registries, just far enough to check they exist and read them.
+// authentik fetches oci:// blueprints itself, but checking first lets us report bad references and credentials.
+// https://docs.goauthentik.io/developer-docs/blueprints/#storage---oci
+package oci
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Prefix is how authentik recognises blueprint paths that live in an OCI registry
+	Prefix = "oci://"
+	// BlueprintMediaType is the layer media type authentik reads blueprints from
+	BlueprintMediaType = "application/vnd.goauthentik.blueprint.v1+yaml"
+	// manifestMediaType is the only manifest type authentik understands
+	manifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// Reference is a parsed registry/repository:tag or registry/repository@digest
+type Reference struct {
+	Registry   string
+	Repository string
+	// Tag or digest to pull
+	Reference string
+}
+
+// ParseReference parses a reference like ghcr.io/org/blueprints:v1 or ghcr.io/org/blueprints@sha256:...
+// An oci:// prefix is ignored. The registry must be given explicitly since authentik has no default registry.
+func ParseReference(ref string) (*Reference, error) {
+	ref = strings.TrimPrefix(ref, Prefix)
+	registry, rest, found := strings.Cut(ref, "/")
+	if !found || registry == "" || rest == "" {
+		return nil, fmt.Errorf("oci reference `%v` must be of the form registry/repository:tag or registry/repository@digest", ref)
+	}
+	if !strings.ContainsAny(registry, ".:") && registry != "localhost" {
+		return nil, fmt.Errorf("oci reference `%v` must start with a registry host", ref)
+	}
+	r := &Reference{Registry: registry}
+	if repo, digest, ok := strings.Cut(rest, "@"); ok {
+		r.Repository, r.Reference = repo, digest
+	} else if i := strings.LastIndex(rest, ":"); i >= 0 {
+		r.Repository, r.Reference = rest[:i], rest[i+1:]
+	} else {
+		r.Repository, r.Reference = rest, "latest"
+	}
+	if r.Repository == "" || r.Reference == "" {
+		return nil, fmt.Errorf("oci reference `%v` has an empty repository or tag", ref)
+	}
+	return r, nil
+}
+
+// String formats the reference the way it was parsed, with tags or digests as appropriate
+func (r *Reference) String() string {
+	if strings.Contains(r.Reference, ":") {
+		return fmt.Sprintf("%v/%v@%v", r.Registry, r.Repository, r.Reference)
+	}
+	return fmt.Sprintf("%v/%v:%v", r.Registry, r.Repository, r.Reference)
+}
+
+// Path is the blueprint instance path authentik expects for this reference. authentik has no other
+// way to be given registry credentials so they are embedded in the url when present, which makes them
+// visible to anyone who can view the blueprint in authentik. Only pass credentials the user allowed to embed.
+func (r *Reference) Path(creds *Credentials) string {
+	if creds == nil || creds.Username == "" {
+		return Prefix + r.String()
+	}
+	user := url.UserPassword(creds.Username, creds.Password)
+	return fmt.Sprintf("%v%v@%v", Prefix, user.String(), r.String())
+}
+
+// Credentials are basic auth credentials for a registry
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// dockerConfig is the structure of kubernetes.io/dockerconfigjson secrets
+type dockerConfig struct {
+	Auths map[string]struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	} `json:"auths"`
+}
+
+// CredentialsFromDockerConfig finds the credentials for a registry in the contents of a .dockerconfigjson
+// returning nil if there are none for it.
+func CredentialsFromDockerConfig(data []byte, registry string) (*Credentials, error) {
+	cfg := &dockerConfig{}
+	err := json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for host, auth := range cfg.Auths {
+		// entries may be bare hosts or urls like https://index.docker.io/v1/
+		host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+		host, _, _ = strings.Cut(host, "/")
+		if host != registry {
+			continue
+		}
+		if auth.Username != "" {
+			return &Credentials{Username: auth.Username, Password: auth.Password}, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return nil, err
+		}
+		user, pass, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return nil, fmt.Errorf("dockerconfig auth for `%v` is not username:password", registry)
+		}
+		return &Credentials{Username: user, Password: pass}, nil
+	}
+	return nil, nil
+}
+
+// Client pulls blueprints from registries over the distribution API.
+// Like authentik it only talks to registries over https.
+type Client struct {
+	HTTPClient *http.Client
+}
+
+// manifest is the part of an OCI image manifest we need
+type manifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"layers"`
+}
+
+// Pull fetches the blueprint stored at the reference, returning its content and the digest of the manifest.
+func (c *Client) Pull(ctx context.Context, ref *Reference, creds *Credentials) (string, string, error) {
+	body, header, err := c.get(ctx, ref, creds, "manifests/"+ref.Reference, manifestMediaType)
+	if err != nil {
+		return "", "", err
+	}
+	m := &manifest{}
+	err = json.Unmarshal(body, m)
+	if err != nil {
+		return "", "", fmt.Errorf("oci manifest for `%v` is invalid: %w", ref, err)
+	}
+	for _, layer := range m.Layers {
+		if layer.MediaType != BlueprintMediaType {
+			continue
+		}
+		blob, _, err := c.get(ctx, ref, creds, "blobs/"+layer.Digest, "")
+		if err != nil {
+			return "", "", err
+		}
+		return string(blob), header.Get("Docker-Content-Digest"), nil
+	}
+	return "", "", fmt.Errorf("oci artifact `%v` has no layer of media type `%v`", ref, BlueprintMediaType)
+}
+
+// get requests a path under the repository, authenticating with the registries token service if challenged
+func (c *Client) get(ctx context.Context, ref *Reference, creds *Credentials, path string, accept string) ([]byte, http.Header, error) {
+	u := fmt.Sprintf("https://%v/v2/%v/%v", ref.Registry, ref.Repository, path)
+	resp, err := c.send(ctx, u, accept, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		authorization, err := c.authorize(ctx, challenge, ref, creds)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, err = c.send(ctx, u, accept, authorization)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("oci registry responded %v to GET %v", resp.Status, u)
+	}
+	return body, resp.Header, nil
+}
+
+func (c *Client) send(ctx context.Context, u string, accept string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(req)
+}
+
+// authorize answers a WWW-Authenticate challenge with either basic auth or a bearer token from the token service
+// https://distribution.github.io/distribution/spec/auth/token/
+func (c *Client) authorize(ctx context.Context, challenge string, ref *Reference, creds *Credentials) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds == nil {
+			return "", fmt.Errorf("oci registry `%v` requires credentials, set a pull secret", ref.Registry)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("oci registry `%v` uses unsupported auth `%v`", ref.Registry, challenge)
+	}
+	values := parseChallenge(params)
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return "", fmt.Errorf("oci registry `%v` gave no token realm", ref.Registry)
+	}
+	q := realm.Query()
+	if values["service"] != "" {
+		q.Set("service", values["service"])
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%v:pull", ref.Repository)
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if creds != nil {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oci token service for `%v` responded %v", ref.Registry, resp.Status)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge splits key="value" pairs from a WWW-Authenticate header
+func parseChallenge(params string) map[string]string {
+	values := map[string]string{}
+	for params != "" {
+		var value string
+		// values may contain commas inside quotes e.g. scope="repository:a:pull,push"
+		key, rest, ok := strings.Cut(params, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(key)
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			value = rest[1 : end+1]
+			rest = rest[min(end+2, len(rest)):]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		values[key] = value
+		params = strings.TrimLeft(rest, ", ")
+	}
+	return values
+}